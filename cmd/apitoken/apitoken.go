@@ -0,0 +1,123 @@
+// Управление токенами API для cmd/webserver и cmd/tcpserver.
+//
+//	apitoken [-file tokens.json] issue -name <имя> -scopes <права через запятую> [-role auditor] [-ttl 2160h]
+//	apitoken [-file tokens.json] list
+//	apitoken [-file tokens.json] revoke <id>
+//
+// issue печатает токен, он показывается один раз: в файле хранится только хеш.
+// Права: read:operations, write:transfers, admin:cards. Права ограничивают маршруты и команды серверов,
+// роль сотрудника (operator, auditor, admin) - операции сервиса карт. Серверы с флагом -tokens
+// перечитывают файл по сигналу SIGHUP, после чего выпущенные и отозванные токены начинают действовать.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/auth"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	if err := execute(os.Args[1:], os.Stdout); err != nil {
+		if err == errUsage {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func execute(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("apitoken", flag.ContinueOnError)
+	file := flags.String("file", "tokens.json", "tokens file")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() == 0 {
+		log.Println("command required: issue, list or revoke")
+		return errUsage
+	}
+
+	tokens, err := auth.LoadTokens(*file)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "issue":
+		err = issue(tokens, args, out)
+	case "list":
+		err = list(tokens, out)
+	case "revoke":
+		if len(args) != 1 {
+			log.Println("usage: revoke <id>")
+			return errUsage
+		}
+		err = tokens.Revoke(args[0])
+	default:
+		log.Printf("unknown command %s", command)
+		return errUsage
+	}
+	if err != nil && err != errUsage {
+		log.Println(err)
+	}
+	return err
+}
+
+func issue(tokens *auth.Tokens, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("issue", flag.ContinueOnError)
+	name := flags.String("name", "", "token purpose, e.g. script name")
+	scopes := flags.String("scopes", "", "comma separated scopes: read:operations, write:transfers, admin:cards")
+	role := flags.String("role", string(card.RoleAuditor), "staff role: operator, auditor or admin")
+	ttl := flags.Duration("ttl", auth.DefaultTokenTTL, "validity period")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if *name == "" {
+		log.Println("-name must not be empty")
+		return errUsage
+	}
+	parsed, err := auth.ParseScopes(*scopes)
+	if err != nil {
+		return err
+	}
+
+	token, issued, err := tokens.Issue(*name, card.Role(*role), parsed, *ttl)
+	if err != nil {
+		return err
+	}
+	log.Printf("token %s issued, expires %s", issued.Id, issued.Expires.Format(time.RFC3339))
+	_, err = fmt.Fprintln(out, token)
+	return err
+}
+
+func list(tokens *auth.Tokens, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tROLE\tSCOPES\tEXPIRES\tSTATUS")
+	now := time.Now()
+	for _, t := range tokens.List() {
+		status := "active"
+		switch {
+		case t.Revoked:
+			status = "revoked"
+		case !now.Before(t.Expires):
+			status = "expired"
+		}
+		scopes := make([]string, len(t.Scopes))
+		for i, scope := range t.Scopes {
+			scopes[i] = string(scope)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Id, t.Name, t.Role, strings.Join(scopes, ","), t.Expires.Format(time.RFC3339), status)
+	}
+	return w.Flush()
+}
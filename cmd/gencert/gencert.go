@@ -0,0 +1,119 @@
+// Выпуск самоподписанного удостоверяющего центра и сертификатов для локальной проверки TLS.
+//
+// В каталог -dir записываются ca.pem, server.pem и файлы клиентов -clients (<имя>.pem),
+// к каждому сертификату - закрытый ключ <имя>-key.pem. Существующий удостоверяющий центр
+// не перезаписывается, если задан -reuse-ca, чтобы перевыпустить сертификаты без замены ca.pem у клиентов.
+package main
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"github.com/ArtDark/bgo_network/pkg/tlsutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	if err := execute(os.Args[1:]); err != nil {
+		if err == errUsage {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func execute(args []string) error {
+	flags := flag.NewFlagSet("gencert", flag.ContinueOnError)
+	dir := flags.String("dir", "certs", "output directory")
+	hosts := flags.String("hosts", "localhost,127.0.0.1,::1", "comma separated server names and ip addresses")
+	clients := flags.String("clients", "client", "comma separated names of client certificates, empty for none")
+	validFor := flags.Duration("valid", 365*24*time.Hour, "validity period")
+	reuseCA := flags.Bool("reuse-ca", false, "sign with existing ca.pem and ca-key.pem from -dir")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if *validFor <= 0 || strings.TrimSpace(*hosts) == "" {
+		log.Println("-valid must be positive and -hosts must not be empty")
+		return errUsage
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		log.Println(err)
+		return err
+	}
+	path := func(name string) string {
+		return filepath.Join(*dir, name)
+	}
+
+	var ca *tlsutil.Pair
+	var err error
+	if *reuseCA {
+		ca, err = loadPair(path("ca.pem"), path("ca-key.pem"))
+	} else {
+		ca, err = tlsutil.GenerateCA("Bank dev CA", *validFor)
+		if err == nil {
+			err = ca.WriteFiles(path("ca.pem"), path("ca-key.pem"))
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	server, err := ca.Issue("server", splitList(*hosts), tlsutil.UsageServer, *validFor)
+	if err == nil {
+		err = server.WriteFiles(path("server.pem"), path("server-key.pem"))
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	for _, name := range splitList(*clients) {
+		client, err := ca.Issue(name, nil, tlsutil.UsageClient, *validFor)
+		if err == nil {
+			err = client.WriteFiles(path(name+".pem"), path(name+"-key.pem"))
+		}
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	log.Printf("certificates written to %s", *dir)
+	return nil
+}
+
+// Функция чтения сертификата с ключом из файлов .pem
+func loadPair(certFile, keyFile string) (*tlsutil.Pair, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+	return &tlsutil.Pair{Cert: cert, Key: key}, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,25 +1,110 @@
+// Клиент сервисов банка на основе пакета client.
+//
+// Команды:
+//
+//	BALANCE <card>                   баланс карты
+//	HISTORY <card> [from] [to]       операции карты, даты в формате 2006-01-02, to не включается
+//	TRANSFER <from> <to> <amount>    перевод в копейках между картами
+//	EXPORT <card> <csv|json|xml>     выгрузка всех операций карты
+//	QUIT                             завершение работы
+//
+// По умолчанию клиент работает с cmd/tcpserver по адресу -addr, с флагом -http - с cmd/webserver.
+// Без -batch клиент работает в интерактивном режиме: команды читаются со стандартного ввода,
+// результаты печатаются по мере получения. С -batch команды читаются из файла, пустые строки
+// и строки, начинающиеся с #, пропускаются; при ошибке команды клиент завершается с кодом 1.
+// Флаг -slow отправляет каждый байт с задержкой, чтобы проверять таймауты сервера.
+// Флаг -tls включает TLS, -tls-ca задает удостоверяющий центр сервера, -tls-cert и -tls-key -
+// сертификат клиента для серверов, которые его требуют. Для HTTP TLS выбирается схемой https в -http.
+// Веб-сервер выдает данные только вошедшему пользователю: с -http нужно указать -user и -password
+// или токен API -token. С -token клиент TCP входит командой AUTH на каждом соединении.
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"github.com/ArtDark/bgo_network/pkg/client"
+	"github.com/ArtDark/bgo_network/pkg/tlsutil"
 	"io"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var (
+	errCommandFailed = errors.New("command failed")
+	errQuit          = errors.New("quit")
+)
+
+// Формат дат в аргументах HISTORY
+const dateLayout = "2006-01-02"
+
 func main() {
-	if err := execute(); err != nil {
+	if err := execute(os.Args[1:]); err != nil {
 		os.Exit(1)
 	}
 }
 
-func execute() (err error) {
-	conn, err := net.Dial("tcp", "localhost:9999")
-	if err != nil {
-		log.Println(err)
+func execute(args []string) (err error) {
+	flags := flag.NewFlagSet("tcpclient", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:9999", "tcp server address")
+	httpURL := flags.String("http", "", "web server url, e.g. http://localhost:9999, instead of tcp server")
+	batch := flags.String("batch", "", "file with commands, - for stdin without prompts")
+	slow := flags.Duration("slow", 0, "delay before every written byte, e.g. 1s")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of every command, 0 to wait forever")
+	retries := flags.Int("retries", 3, "retries of idempotent commands, 0 to disable")
+	useTLS := flags.Bool("tls", false, "connect to tcp server over tls")
+	caFile := flags.String("tls-ca", "", "ca file of server certificate, empty for system roots")
+	certFile := flags.String("tls-cert", "", "client certificate file for mutual tls")
+	keyFile := flags.String("tls-key", "", "private key file of -tls-cert")
+	user := flags.String("user", "", "login of web server user")
+	password := flags.String("password", "", "password of -user")
+	token := flags.String("token", "", "api token of cmd/apitoken")
+	if err = flags.Parse(args); err != nil {
 		return err
 	}
+
+	options := client.Options{Timeout: *timeout, MaxConns: 1, MaxRetries: *retries, Token: *token}
+	if *timeout == 0 {
+		options.Timeout = -1
+	}
+	if *retries == 0 {
+		options.MaxRetries = -1
+	}
+	if *slow > 0 {
+		options.Dial = slowDialer(*slow)
+	}
+	if *useTLS || *caFile != "" || *certFile != "" {
+		options.TLSConfig, err = tlsutil.ClientConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+
+	var bank client.Bank
+	if *httpURL != "" {
+		httpClient, err := client.NewHTTPClient(*httpURL, options)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		if *user != "" {
+			if err = httpClient.Login(context.Background(), *user, *password); err != nil {
+				log.Println(err)
+				return err
+			}
+		}
+		bank = httpClient
+	} else {
+		bank = client.NewTCPClient(*addr, options)
+	}
 	defer func(c io.Closer) {
 		if cerr := c.Close(); cerr != nil {
 			log.Println(cerr)
@@ -27,20 +112,194 @@ func execute() (err error) {
 				err = cerr
 			}
 		}
-	}(conn)
+	}(bank)
+
+	if *batch == "" {
+		return run(bank, os.Stdin, os.Stdout, true)
+	}
 
-	request := []byte("GET / HTTP/1.1\r\n")
-	for i := range request {
-		time.Sleep(time.Second)
-		part := request[i : i+1]
-		log.Printf("write: %s", part)
-		_, err = conn.Write(part)
+	var in io.Reader = os.Stdin
+	if *batch != "-" {
+		file, err := os.Open(*batch)
 		if err != nil {
 			log.Println(err)
 			return err
 		}
+		defer func(c io.Closer) {
+			if cerr := c.Close(); cerr != nil {
+				log.Println(cerr)
+			}
+		}(file)
+		in = file
+	}
+	return run(bank, in, os.Stdout, false)
+}
+
+// Функция выполнения команд из in с выводом результатов в out.
+// В интерактивном режиме печатается приглашение, ошибки команд не прерывают работу
+func run(bank client.Bank, in io.Reader, out io.Writer, interactive bool) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		if interactive {
+			_, _ = fmt.Fprint(out, "> ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		command := strings.TrimSpace(scanner.Text())
+		if command == "" || strings.HasPrefix(command, "#") {
+			continue
+		}
+		if !interactive {
+			_, _ = fmt.Fprintf(out, "> %s\n", command)
+		}
+
+		err := do(context.Background(), bank, command, out)
+		if err == errQuit {
+			return nil
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "error: %v\n", err)
+			if !interactive {
+				return errCommandFailed
+			}
+		}
+	}
+}
+
+// Функция выполнения одной команды
+func do(ctx context.Context, bank client.Bank, command string, out io.Writer) error {
+	fields := strings.Fields(command)
+	args := fields[1:]
+	switch strings.ToUpper(fields[0]) {
+	case "BALANCE":
+		if len(args) != 1 {
+			return errors.New("usage: BALANCE <card>")
+		}
+		id, err := parseCardId(args[0])
+		if err != nil {
+			return err
+		}
+		balance, err := bank.Balance(ctx, id)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%d %s\n", balance.Amount, balance.Currency)
+		return err
+	case "HISTORY":
+		if len(args) < 1 || len(args) > 3 {
+			return errors.New("usage: HISTORY <card> [from] [to]")
+		}
+		id, err := parseCardId(args[0])
+		if err != nil {
+			return err
+		}
+		var from, to time.Time
+		if len(args) > 1 {
+			if from, err = time.Parse(dateLayout, args[1]); err != nil {
+				return errors.New("invalid from date")
+			}
+		}
+		if len(args) > 2 {
+			if to, err = time.Parse(dateLayout, args[2]); err != nil {
+				return errors.New("invalid to date")
+			}
+		}
+		transactions, err := bank.History(ctx, id, from, to)
+		if err != nil {
+			return err
+		}
+		for _, t := range transactions {
+			if _, err = fmt.Fprintln(out, formatTransaction(t)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "TRANSFER":
+		if len(args) != 3 {
+			return errors.New("usage: TRANSFER <from> <to> <amount>")
+		}
+		from, err := parseCardId(args[0])
+		if err != nil {
+			return err
+		}
+		to, err := parseCardId(args[1])
+		if err != nil {
+			return err
+		}
+		amount, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errors.New("invalid amount")
+		}
+		id, err := bank.Transfer(ctx, from, to, amount)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, id)
+		return err
+	case "EXPORT":
+		if len(args) != 2 {
+			return errors.New("usage: EXPORT <card> <csv|json|xml>")
+		}
+		id, err := parseCardId(args[0])
+		if err != nil {
+			return err
+		}
+		if err = bank.Export(ctx, id, client.Format(strings.ToLower(args[1])), out); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out)
+		return err
+	case "QUIT":
+		return errQuit
+	}
+	return fmt.Errorf("unknown command %s", fields[0])
+}
+
+func parseCardId(value string) (card.CardId, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid card id")
+	}
+	return card.CardId(id), nil
+}
+
+// Функция представления транзакции строкой "<id> <сумма> <время> <mcc> <статус> <тип>"
+func formatTransaction(t card.Transaction) string {
+	txType := t.Type
+	if txType == "" {
+		txType = "-"
+	}
+	at := time.Unix(t.Time, 0).UTC().Format(time.RFC3339)
+	return fmt.Sprintf("%s %d %s %s %s %s", t.Id, t.Bill, at, t.MCC, t.Status, txType)
+}
+
+// Функция создания соединений, которые отправляют данные по одному байту с задержкой
+func slowDialer(delay time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &slowConn{Conn: conn, delay: delay}, nil
 	}
-	log.Println("request finished")
+}
+
+// Соединение с замедленной отправкой
+type slowConn struct {
+	net.Conn
+	delay time.Duration
+}
 
-	return nil
+func (c *slowConn) Write(data []byte) (int, error) {
+	for i := range data {
+		time.Sleep(c.delay)
+		log.Printf("write: %q", data[i:i+1])
+		if _, err := c.Conn.Write(data[i : i+1]); err != nil {
+			return i, err
+		}
+	}
+	return len(data), nil
 }
@@ -1,25 +1,255 @@
+// Сервер текстового протокола для работы с картами.
+//
+// Клиент отправляет команды строками, завершенными \n (\r\n тоже допускается):
+//
+//	BALANCE <card>                   баланс карты
+//	HISTORY <card> [from] [to]       операции карты по времени, даты в формате 2006-01-02, to не включается
+//	TRANSFER <from> <to> <amount>    перевод в копейках между картами
+//	AUTH <token>                     вход с токеном API
+//	QUIT                             завершение сеанса
+//
+// Строка команды вместе с \r\n не длиннее 1024 байт, на более длинную сервер отвечает 400 и закрывает соединение.
+//
+// Ответ начинается строкой статуса "<код> <текст>", за ней идут строки данных и строка ".".
+// Строки данных, начинающиеся с точки, передаются с дополнительной точкой в начале.
+// Коды статуса:
+//
+//	200 OK               команда выполнена
+//	221 BYE              сеанс завершен, сервер закрывает соединение
+//	400 <ошибка>         неизвестная команда или неверные аргументы
+//	401 <ошибка>         нужен вход командой AUTH или токен неверен
+//	403 <ошибка>         у токена нет права на команду или роль токена не допускает операцию
+//	404 <ошибка>         карта не найдена
+//	409 <ошибка>         операция невозможна, например, недостаточно средств
+//	429 <ошибка>         превышена частота команд, текст содержит "retry after <секунд>"
+//	500 <ошибка>         внутренняя ошибка
+//	503 <ошибка>         сервер перегружен, соединение закрывается после ответа на первую команду
+//
+// Данные BALANCE: "<баланс в копейках> <валюта>".
+// Данные HISTORY: по строке на операцию "<id> <сумма> <время unix> <mcc> <статус> <тип> <валюта> <исходная>",
+// где исходная - идентификатор транзакции, к которой относится возврат или отмена. Пустые поля передаются как "-".
+// Операции отправляются по мере чтения страниц; если чтение прервалось ошибкой, соединение закрывается
+// без строки ".".
+// Данные TRANSFER: идентификатор исходящей транзакции.
+// Данные AUTH: права токена через пробел.
+//
+// С флагом -tokens сервер требует вход: до успешной команды AUTH выполняются только AUTH и QUIT.
+// BALANCE и HISTORY требуют права read:operations, TRANSFER - write:transfers. Токен проверяется
+// при каждой команде, поэтому истекший или отозванный токен перестает действовать в открытом соединении.
+// Команды выполняются от имени токена: сервис карт проверяет роль, например, auditor не может переводить.
+// Токены выпускает cmd/apitoken, файл перечитывается по сигналу SIGHUP. Без -tokens AUTH отвечает 400.
+//
+// Если первый байт соединения равен frame.Magic, соединение работает в двоичном режиме пакета frame:
+// данные запроса - строка команды, данные ответа - строка статуса и строки данных через \n
+// без завершающей точки и экранирования. Запросы в двоичном режиме выполняются параллельно,
+// QUIT отвечает 221 BYE, а соединение закрывает клиент. При перегрузке сервер отвечает на первый
+// кадр кадром TypeError с текстом "503 <ошибка>".
+//
+// С флагами -tls-cert и -tls-key сервер принимает соединения TLS, с -tls-client-ca дополнительно
+// требует сертификат клиента, подписанный указанным удостоверяющим центром. Файлы сертификатов
+// перечитываются по сигналу SIGHUP. Сертификаты для разработки выпускает cmd/gencert.
+//
+// С флагом -mcc-overrides записи встроенного справочника MCC при запуске переопределяются строками
+// файла csv в формате code,group,name_ru,name_en.
 package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/auth"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"github.com/ArtDark/bgo_network/pkg/frame"
+	"github.com/ArtDark/bgo_network/pkg/netutil"
+	"github.com/ArtDark/bgo_network/pkg/ratelimit"
+	"github.com/ArtDark/bgo_network/pkg/tlsutil"
 	"io"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
+var (
+	errInvalidCard  = errors.New("invalid card id")
+	errAuthRequired = errors.New("authentication required")
+	errAuthDisabled = errors.New("authentication is disabled")
+)
+
+// Формат дат в аргументах HISTORY
+const dateLayout = "2006-01-02"
+
+// Ограничение длины строки команды вместе с \r\n
+const maxLineLength = 1024
+
+// Коды статуса ответа
+const (
+	statusOK           = 200
+	statusBye          = 221
+	statusBadRequest   = 400
+	statusUnauthorized = 401
+	statusForbidden    = 403
+	statusNotFound     = 404
+	statusConflict     = 409
+	statusTooMany      = 429
+	statusError        = 500
+	statusUnavailable  = 503
+)
+
+// Ограничения соединений: при занятых местах новое соединение ждет секунду, затем получает 503,
+// ждать могут не больше 1000 соединений; соединение без команд дольше 5 минут закрывается
+var connLimits = netutil.Limits{MaxConns: 1000, MaxConnsPerIP: 100, QueueTimeout: time.Second, MaxQueued: 1000, IdleTimeout: 5 * time.Minute}
+
+// Ограничения частоты команд с одного адреса или, после входа, одного токена. QUIT не ограничивается,
+// AUTH ограничен сильнее, чтобы токены нельзя было подбирать
+var commandLimits = map[string]ratelimit.Limit{
+	"BALANCE":  {Rate: 10, Burst: 20},
+	"HISTORY":  {Rate: 1, Burst: 5},
+	"TRANSFER": {Rate: 2, Burst: 5},
+	"AUTH":     {Rate: 0.2, Burst: 5},
+}
+
+// Права, нужные для команд при включенной проверке токенов
+var commandScopes = map[string]auth.Scope{
+	"BALANCE":  auth.ScopeReadOperations,
+	"HISTORY":  auth.ScopeReadOperations,
+	"TRANSFER": auth.ScopeWriteTransfers,
+}
+
+// Ограничение частоты неизвестных команд
+var defaultCommandLimit = ratelimit.Limit{Rate: 5, Burst: 10}
+
+// Маршрут ограничителя, общий для всех команд без своего ограничения
+const otherCommands = "*"
+
+// Время, за которое отклоненный клиент должен прислать первую команду и принять ответ
+const rejectTimeout = time.Second
+
+type server struct {
+	svc     *card.Service
+	limiter *ratelimit.Limiter // Без ограничителя частота команд не ограничивается
+	tokens  *auth.Tokens       // Без хранилища вход не требуется
+}
+
+func newServer(svc *card.Service, limiter *ratelimit.Limiter) *server {
+	return &server{svc: svc, limiter: limiter}
+}
+
+// Клиент соединения: адрес и токен, с которым он вошел. В двоичном режиме команды
+// одного соединения выполняются параллельно, поэтому токен защищен мьютексом
+type peer struct {
+	ip string
+
+	mu    sync.Mutex
+	token string
+	id    string // Идентификатор токена для ограничения частоты
+}
+
+// Метод получения ключа ограничения частоты: после входа - токен, до входа - адрес
+func (p *peer) key() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.id != "" {
+		return "token:" + p.id
+	}
+	return p.ip
+}
+
+// Ответ на команду
+type response struct {
+	status int
+	text   string
+	lines  []string
+	// Получение следующей порции строк данных после lines, пустая порция - конец данных.
+	// Порции пишутся клиенту по мере получения, поэтому длинный ответ не собирается в памяти
+	more func() ([]string, error)
+}
+
 func main() {
-	if err := execute(); err != nil {
+	if err := execute(os.Args[1:]); err != nil {
 		os.Exit(1)
 	}
 }
 
-func execute() (err error) {
-	listener, err := net.Listen("tcp", "0.0.0.0:9999")
+// Функция создания сервиса с демонстрационными данными
+func newDemoService() (*card.Service, error) {
+	svc := card.New("Bank")
+	ivan := card.Owner{CustomerId: 1, FirstName: "Ivan", LastName: "Ivanov"}
+	if _, err := svc.CardIssueContext(context.Background(), 1, ivan, "Visa", 103242_00, "RUB", "5106 2100 0000 0001"); err != nil {
+		return nil, err
+	}
+	petr := card.Owner{CustomerId: 2, FirstName: "Petr", LastName: "Petrov"}
+	if _, err := svc.CardIssueContext(context.Background(), 2, petr, "MasterCard", 5000_00, "RUB", "5106 2100 0000 0002"); err != nil {
+		return nil, err
+	}
+	if err := svc.MakeTransactions(1, 10); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+func execute(args []string) (err error) {
+	flags := flag.NewFlagSet("tcpserver", flag.ContinueOnError)
+	addr := flags.String("addr", "0.0.0.0:9999", "listen address")
+	certFile := flags.String("tls-cert", "", "certificate file, enables tls")
+	keyFile := flags.String("tls-key", "", "private key file of -tls-cert")
+	clientCAFile := flags.String("tls-client-ca", "", "ca file of client certificates, enables mutual tls")
+	tokensFile := flags.String("tokens", "", "api tokens file of cmd/apitoken, enables AUTH, reloaded on SIGHUP")
+	mccFile := flags.String("mcc-overrides", "", "csv file overriding entries of the built-in mcc directory")
+	if err = flags.Parse(args); err != nil {
+		return err
+	}
+	files := tlsutil.Files{CertFile: *certFile, KeyFile: *keyFile, ClientCAFile: *clientCAFile}
+	if err = files.Validate(); err != nil {
+		log.Println(err)
+		return err
+	}
+	if *mccFile != "" {
+		if err = card.LoadMCCOverrides(*mccFile); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+
+	svc, err := newDemoService()
 	if err != nil {
 		log.Println(err)
 		return err
 	}
+	s := newServer(svc, ratelimit.New(commandLimits, defaultCommandLimit))
+	if *tokensFile != "" {
+		s.tokens, err = auth.LoadTokens(*tokensFile)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		go s.tokens.ReloadOnSignal(context.Background(), syscall.SIGHUP)
+		svc.RequirePrincipal = true
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if files.CertFile != "" {
+		reloader, err := tlsutil.NewReloader(files)
+		if err != nil {
+			_ = listener.Close()
+			log.Println(err)
+			return err
+		}
+		go reloader.ReloadOnSignal(context.Background(), syscall.SIGHUP)
+		listener = tls.NewListener(listener, reloader.Config())
+	}
 	defer func() {
 		if cerr := listener.Close(); cerr != nil {
 			log.Println(cerr)
@@ -28,56 +258,403 @@ func execute() (err error) {
 			}
 		}
 	}()
-	for {
-		conn, err := listener.Accept() // для клиентов
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-		go handle(conn)
+	if err = netutil.Serve(listener, connLimits, s.handle, s.reject); err != nil {
+		log.Println(err)
 	}
+	return err
 }
 
-func handle(conn net.Conn) {
+// Метод обслуживания соединения: команды выполняются по очереди до QUIT или разрыва соединения
+func (s *server) handle(conn net.Conn) {
 	defer func() {
 		if cerr := conn.Close(); cerr != nil {
 			log.Println(cerr)
 		}
 	}()
 
-	reader := bufio.NewReader(conn)
-	const delim = '\n'
-	line, err := reader.ReadString(delim)
+	p := &peer{ip: netutil.RemoteIP(conn)}
+	reader := bufio.NewReaderSize(conn, maxLineLength)
+	first, err := reader.Peek(1)
 	if err != nil {
 		if err != io.EOF {
 			log.Println(err)
 		}
-		log.Printf("received: %s\n", line)
 		return
 	}
-	log.Printf("received: %s\n", line)
-
-	//writer := bufio.NewWriter(conn)
-	//_, err = writer.WriteString("Hello from Go!")
-	//if err != nil {
-	//	log.Println(err)
-	//	return
-	//}
-	//err = writer.Flush()
-	//if err != nil {
-	//	log.Println(err)
-	//	return
-	//}
+	if first[0] == frame.Magic {
+		err = frame.Serve(reader, conn, func(request frame.Frame) (frame.Type, []byte) {
+			return s.handleFrame(p, request)
+		})
+		if err != io.EOF {
+			log.Println(err)
+		}
+		return
+	}
 
+	writer := bufio.NewWriter(conn)
+	const delim = '\n'
 	for {
-		line, err := reader.ReadString(delim)
+		data, err := reader.ReadSlice(delim)
+		if err == bufio.ErrBufferFull {
+			// Остаток слишком длинной строки нельзя отличить от следующей команды, поэтому соединение закрывается
+			if err = writeResponse(writer, response{status: statusBadRequest, text: "line too long"}); err != nil {
+				log.Println(err)
+			}
+			return
+		}
 		if err != nil {
 			if err != io.EOF {
 				log.Println(err)
 			}
-			log.Printf("received: %s\n", line)
 			return
 		}
-		log.Printf("received: %s\n", line)
+		line := string(data)
+		log.Printf("received: %s", redact(line))
+
+		resp := s.dispatch(p, strings.TrimRight(line, "\r\n"))
+		if err = writeResponse(writer, resp); err != nil {
+			log.Println(err)
+			return
+		}
+		if resp.status == statusBye {
+			return
+		}
+	}
+}
+
+// Функция скрытия секретов в строке команды для журнала: токен команды AUTH не записывается
+func redact(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) > 1 && strings.ToUpper(fields[0]) == "AUTH" {
+		return fields[0] + " [redacted]"
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// Метод отказа в обслуживании: на первую команду или первый кадр отправляется ответ 503 с причиной
+func (s *server) reject(conn net.Conn, cause error) {
+	if err := conn.SetDeadline(time.Now().Add(rejectTimeout)); err != nil {
+		log.Println(err)
+		return
+	}
+	resp := response{status: statusUnavailable, text: cause.Error()}
+
+	// Команду клиента нужно прочитать, иначе закрытие соединения с непрочитанными данными
+	// может сбросить его вместе с ответом
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err == nil && first[0] == frame.Magic {
+		request, err := frame.Read(reader)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		payload := []byte(fmt.Sprintf("%d %s", resp.status, resp.text))
+		if err = frame.Write(conn, frame.Frame{Type: frame.TypeError, RequestId: request.RequestId, Payload: payload}); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	if err == nil {
+		_, _ = reader.ReadString('\n')
+	}
+	if err = writeResponse(bufio.NewWriter(conn), resp); err != nil {
+		log.Println(err)
+	}
+}
+
+// Метод выполнения команды из кадра двоичного режима
+func (s *server) handleFrame(p *peer, request frame.Frame) (frame.Type, []byte) {
+	resp := s.dispatch(p, strings.TrimRight(string(request.Payload), "\r\n"))
+
+	// Ответ в двоичном режиме - один кадр, поэтому порции собираются целиком, но не больше frame.MaxPayload
+	var payload bytes.Buffer
+	payload.WriteString(fmt.Sprintf("%d %s", resp.status, resp.text))
+	lines := resp.lines
+	for {
+		for _, line := range lines {
+			payload.WriteString("\n" + line)
+		}
+		if payload.Len() > frame.MaxPayload {
+			return frame.TypeResponse, []byte(fmt.Sprintf("%d response too large, narrow the date range", statusBadRequest))
+		}
+		if resp.more == nil {
+			break
+		}
+		var err error
+		if lines, err = resp.more(); err != nil {
+			resp = errorResponse(err)
+			return frame.TypeResponse, []byte(fmt.Sprintf("%d %s", resp.status, resp.text))
+		}
+		if len(lines) == 0 {
+			break
+		}
+	}
+	return frame.TypeResponse, payload.Bytes()
+}
+
+// Метод выполнения одной команды клиента p
+func (s *server) dispatch(p *peer, line string) response {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return response{status: statusBadRequest, text: "empty command"}
+	}
+
+	command := strings.ToUpper(fields[0])
+	if command != "QUIT" && s.limiter != nil {
+		if ok, wait := s.limiter.Allow(commandRoute(command), p.key()); !ok {
+			text := fmt.Sprintf("too many requests, retry after %d", ratelimit.RetryAfterSeconds(wait))
+			return response{status: statusTooMany, text: text}
+		}
+	}
+
+	args := fields[1:]
+	switch command {
+	case "AUTH":
+		return s.auth(p, args)
+	case "QUIT":
+		return response{status: statusBye, text: "BYE"}
+	}
+	ctx, resp, ok := s.authorize(p, command)
+	if !ok {
+		return resp
+	}
+
+	switch command {
+	case "BALANCE":
+		return s.balance(ctx, args)
+	case "HISTORY":
+		return s.history(ctx, args)
+	case "TRANSFER":
+		return s.transfer(ctx, args)
+	}
+	return response{status: statusBadRequest, text: "unknown command " + fields[0]}
+}
+
+// Функция выбора маршрута ограничителя для команды. Команды, которых нет в commandLimits,
+// делят один маршрут, иначе каждая выдуманная команда заводила бы в ограничителе новую корзину
+func commandRoute(command string) string {
+	if _, ok := commandLimits[command]; ok {
+		return command
+	}
+	return otherCommands
+}
+
+// Метод входа с токеном API. Неверный токен сбрасывает прежний вход
+func (s *server) auth(p *peer, args []string) response {
+	if len(args) != 1 {
+		return response{status: statusBadRequest, text: "usage: AUTH <token>"}
+	}
+	if s.tokens == nil {
+		return response{status: statusBadRequest, text: errAuthDisabled.Error()}
+	}
+
+	token, err := s.tokens.Verify(args[0])
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.token, p.id = "", ""
+		return response{status: statusUnauthorized, text: err.Error()}
+	}
+	p.token, p.id = args[0], token.Id
+	log.Printf("auth: token %s (%s)", token.Id, token.Name)
+
+	scopes := make([]string, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		scopes[i] = string(scope)
+	}
+	return okResponse(strings.Join(scopes, " "))
+}
+
+// Метод проверки входа и права на команду. Возвращает контекст с Principal токена для вызовов сервиса.
+// Без хранилища токенов разрешено все, неизвестные команды проверяются после входа, чтобы не раскрывать их до него
+func (s *server) authorize(p *peer, command string) (context.Context, response, bool) {
+	ctx := context.Background()
+	if s.tokens == nil {
+		return ctx, response{}, true
+	}
+	p.mu.Lock()
+	value := p.token
+	p.mu.Unlock()
+	if value == "" {
+		return nil, response{status: statusUnauthorized, text: errAuthRequired.Error()}, false
+	}
+
+	token, err := s.tokens.Verify(value)
+	if err != nil {
+		return nil, response{status: statusUnauthorized, text: err.Error()}, false
+	}
+	if scope, ok := commandScopes[command]; ok && !token.Allows(scope) {
+		return nil, response{status: statusForbidden, text: auth.ErrInsufficientScope.Error()}, false
+	}
+	return card.WithPrincipal(ctx, token.Principal()), response{}, true
+}
+
+func (s *server) balance(ctx context.Context, args []string) response {
+	if len(args) != 1 {
+		return response{status: statusBadRequest, text: "usage: BALANCE <card>"}
+	}
+	id, err := parseCardId(args[0])
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	balance, currency, err := s.svc.BalanceContext(ctx, id)
+	if err != nil {
+		return errorResponse(err)
+	}
+	return okResponse(fmt.Sprintf("%d %s", balance, currency))
+}
+
+func (s *server) history(ctx context.Context, args []string) response {
+	if len(args) < 1 || len(args) > 3 {
+		return response{status: statusBadRequest, text: "usage: HISTORY <card> [from] [to]"}
+	}
+	id, err := parseCardId(args[0])
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	query := card.SearchQuery{Limit: card.MaxSearchLimit}
+	if len(args) > 1 {
+		if query.From, err = time.Parse(dateLayout, args[1]); err != nil {
+			return response{status: statusBadRequest, text: "invalid from date"}
+		}
+	}
+	if len(args) > 2 {
+		if query.To, err = time.Parse(dateLayout, args[2]); err != nil {
+			return response{status: statusBadRequest, text: "invalid to date"}
+		}
+	}
+
+	// Первая страница запрашивается до ответа, чтобы ошибки карты и прав вернулись статусом,
+	// следующие - по мере записи ответа
+	page, err := s.svc.SearchTransactionsContext(ctx, id, query)
+	if err != nil {
+		return errorResponse(err)
+	}
+	resp := okResponse(formatTransactions(page.Transactions)...)
+	resp.more = func() ([]string, error) {
+		if page.NextCursor == "" {
+			return nil, nil
+		}
+		query.Cursor = page.NextCursor
+		if page, err = s.svc.SearchTransactionsContext(ctx, id, query); err != nil {
+			return nil, err
+		}
+		return formatTransactions(page.Transactions), nil
+	}
+	return resp
+}
+
+// Функция представления страницы транзакций строками данных HISTORY
+func formatTransactions(transactions []card.Transaction) []string {
+	lines := make([]string, len(transactions))
+	for i, t := range transactions {
+		lines[i] = formatTransaction(t)
+	}
+	return lines
+}
+
+func (s *server) transfer(ctx context.Context, args []string) response {
+	if len(args) != 3 {
+		return response{status: statusBadRequest, text: "usage: TRANSFER <from> <to> <amount>"}
+	}
+	from, err := parseCardId(args[0])
+	if err != nil {
+		return errorResponse(err)
+	}
+	to, err := parseCardId(args[1])
+	if err != nil {
+		return errorResponse(err)
+	}
+	amount, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return response{status: statusBadRequest, text: "invalid amount"}
+	}
+
+	transaction, err := s.svc.TransferContext(ctx, from, to, amount)
+	if err != nil {
+		return errorResponse(err)
+	}
+	return okResponse(transaction.Id)
+}
+
+func parseCardId(value string) (card.CardId, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errInvalidCard
+	}
+	return card.CardId(id), nil
+}
+
+// Функция представления транзакции строкой данных HISTORY
+func formatTransaction(t card.Transaction) string {
+	return fmt.Sprintf("%s %d %d %s %s %s %s %s",
+		t.Id, t.Bill, t.Time, t.MCC, t.Status, orDash(t.Type), orDash(t.Currency), orDash(t.OriginalId))
+}
+
+// Функция замены пустого поля строки HISTORY на "-"
+func orDash(field string) string {
+	if field == "" {
+		return "-"
+	}
+	return field
+}
+
+func okResponse(lines ...string) response {
+	return response{status: statusOK, text: "OK", lines: lines}
+}
+
+// Функция выбора кода статуса по ошибке сервиса
+func errorResponse(err error) response {
+	if errors.Is(err, card.ErrForbidden) {
+		return response{status: statusForbidden, text: err.Error()}
+	}
+	switch err {
+	case errInvalidCard, card.ErrInvalidAmount, card.ErrInvalidTransfer, card.ErrInvalidRange:
+		return response{status: statusBadRequest, text: err.Error()}
+	case card.ErrCardNotFound:
+		return response{status: statusNotFound, text: err.Error()}
+	case card.ErrInsufficientFunds:
+		return response{status: statusConflict, text: err.Error()}
+	}
+	log.Println(err)
+	return response{status: statusError, text: "internal error"}
+}
+
+// Функция записи ответа: строка статуса, строки данных и завершающая точка
+func writeResponse(writer *bufio.Writer, resp response) error {
+	_, err := fmt.Fprintf(writer, "%d %s\r\n", resp.status, resp.text)
+	if err != nil {
+		return err
+	}
+	lines := resp.lines
+	for {
+		for _, line := range lines {
+			if strings.HasPrefix(line, ".") {
+				line = "." + line
+			}
+			if _, err = writer.WriteString(line + "\r\n"); err != nil {
+				return err
+			}
+		}
+		if resp.more == nil {
+			break
+		}
+		if err = writer.Flush(); err != nil {
+			return err
+		}
+		// Статус уже отправлен: при ошибке ответ обрывается без завершающей точки, и клиент не примет его за полный
+		if lines, err = resp.more(); err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			break
+		}
+	}
+	if _, err = writer.WriteString(".\r\n"); err != nil {
+		return err
 	}
+	return writer.Flush()
 }
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/auth"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"github.com/ArtDark/bgo_network/pkg/frame"
+	"github.com/ArtDark/bgo_network/pkg/netutil"
+	"github.com/ArtDark/bgo_network/pkg/ratelimit"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Клиент протокола поверх одного конца net.Pipe
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	done   chan struct{}
+}
+
+func newTestService() *card.Service {
+	svc := card.New("Test Bank")
+	svc.IDs = card.NewSequenceGenerator("tx")
+	svc.Clock = card.NewManualClock(time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC))
+	svc.CardIssue(1, "User", "User", "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+	svc.CardIssue(2, "User", "User", "Visa", 0, "RUB", "5106 2100 0000 0002")
+	_ = svc.AddTransactions(1,
+		card.Transaction{Id: "0001", Bill: 100_00, Time: 1599652800, MCC: "5411", Status: card.StatusDone},
+		card.Transaction{Id: "0002", Bill: 200_00, Time: 1599739200, MCC: "5812", Status: card.StatusDone, Type: card.TypePurchase},
+	)
+	return svc
+}
+
+func newTestClient(t *testing.T) *testClient {
+	return newTestServerClient(t, newServer(newTestService(), nil))
+}
+
+// Функция подключения клиента к серверу s
+func newTestServerClient(t *testing.T, s *server) *testClient {
+	serverConn, clientConn := net.Pipe()
+	client := &testClient{t: t, conn: clientConn, reader: bufio.NewReader(clientConn), done: make(chan struct{})}
+	go func() {
+		s.handle(serverConn)
+		close(client.done)
+	}()
+	t.Cleanup(func() {
+		_ = clientConn.Close()
+		<-client.done
+	})
+	return client
+}
+
+// Метод отправки команды и чтения ответа до строки "."
+func (c *testClient) do(command string) (string, []string) {
+	c.t.Helper()
+
+	if err := c.conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.conn, "%s\r\n", command); err != nil {
+		c.t.Fatal(err)
+	}
+
+	status, err := c.reader.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var lines []string
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "." {
+			break
+		}
+		lines = append(lines, strings.TrimPrefix(line, "."))
+	}
+	return strings.TrimRight(status, "\r\n"), lines
+}
+
+func TestServer_Commands(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    string
+		wantStatus string
+		wantLines  []string
+	}{
+		{
+			name:       "Balance",
+			command:    "BALANCE 1",
+			wantStatus: "200 OK",
+			wantLines:  []string{"100000 RUB"},
+		},
+		{
+			name:       "Balance lower case",
+			command:    "balance 2",
+			wantStatus: "200 OK",
+			wantLines:  []string{"0 RUB"},
+		},
+		{
+			name:       "Balance of unknown card",
+			command:    "BALANCE 3",
+			wantStatus: "404 card not found",
+		},
+		{
+			name:       "Balance with invalid card",
+			command:    "BALANCE x",
+			wantStatus: "400 invalid card id",
+		},
+		{
+			name:       "History",
+			command:    "HISTORY 1",
+			wantStatus: "200 OK",
+			wantLines:  []string{"0001 10000 1599652800 5411 Done - - -", "0002 20000 1599739200 5812 Done purchase - -"},
+		},
+		{
+			name:       "History in range",
+			command:    "HISTORY 1 2020-09-10 2020-09-11",
+			wantStatus: "200 OK",
+			wantLines:  []string{"0002 20000 1599739200 5812 Done purchase - -"},
+		},
+		{
+			name:       "History with invalid date",
+			command:    "HISTORY 1 10.09.2020",
+			wantStatus: "400 invalid from date",
+		},
+		{
+			name:       "Transfer",
+			command:    "TRANSFER 1 2 300",
+			wantStatus: "200 OK",
+			wantLines:  []string{"tx-000000000001"},
+		},
+		{
+			name:       "Transfer without funds",
+			command:    "TRANSFER 2 1 300",
+			wantStatus: "409 insufficient funds",
+		},
+		{
+			name:       "Transfer with negative amount",
+			command:    "TRANSFER 1 2 -300",
+			wantStatus: "400 invalid amount",
+		},
+		{
+			name:       "Transfer usage",
+			command:    "TRANSFER 1 2",
+			wantStatus: "400 usage: TRANSFER <from> <to> <amount>",
+		},
+		{
+			name:       "Unknown command",
+			command:    "DEPOSIT 1 100",
+			wantStatus: "400 unknown command DEPOSIT",
+		},
+		{
+			name:       "Empty command",
+			command:    "",
+			wantStatus: "400 empty command",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, lines := newTestClient(t).do(tt.command)
+			if status != tt.wantStatus {
+				t.Errorf("status got = %q, want %q", status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("lines got = %q, want %q", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestServer_Session(t *testing.T) {
+	client := newTestClient(t)
+
+	if status, _ := client.do("TRANSFER 1 2 250"); status != "200 OK" {
+		t.Fatalf("TRANSFER status got = %q", status)
+	}
+	if _, lines := client.do("BALANCE 2"); !reflect.DeepEqual(lines, []string{"250 RUB"}) {
+		t.Errorf("BALANCE got = %q, want 250 RUB", lines)
+	}
+	if _, lines := client.do("HISTORY 2"); len(lines) != 1 || !strings.HasSuffix(lines[0], "4829 Done transfer - tx-000000000001") {
+		t.Errorf("HISTORY got = %q, want incoming transfer", lines)
+	}
+
+	if status, lines := client.do("QUIT"); status != "221 BYE" || len(lines) != 0 {
+		t.Errorf("QUIT got = %q %q", status, lines)
+	}
+	// После QUIT сервер закрывает соединение
+	if _, err := client.reader.ReadByte(); err != io.EOF {
+		t.Errorf("read after QUIT error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestWriteResponse_DotStuffing(t *testing.T) {
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	if err := writeResponse(w, okResponse(".hidden", "plain")); err != nil {
+		t.Fatal(err)
+	}
+	want := "200 OK\r\n..hidden\r\nplain\r\n.\r\n"
+	if b.String() != want {
+		t.Errorf("writeResponse() got = %q, want %q", b.String(), want)
+	}
+}
+
+func TestServer_Frames(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		newServer(newTestService(), nil).handle(serverConn)
+		close(done)
+	}()
+	client := frame.NewClient(clientConn)
+	defer func() {
+		_ = client.Close()
+		<-done
+	}()
+
+	// Несколько переводов по одному соединению одновременно
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := client.Do(context.Background(), []byte("TRANSFER 1 2 100"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !strings.HasPrefix(string(got), "200 OK\ntx-") {
+				t.Errorf("TRANSFER got = %q", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "BALANCE 2", want: "200 OK\n1000 RUB"},
+		{command: "HISTORY 1 2020-09-09 2020-09-10", want: "200 OK\n0001 10000 1599652800 5411 Done - - -"},
+		{command: "BALANCE 3", want: "404 card not found"},
+		{command: "QUIT", want: "221 BYE"},
+	}
+	for _, tt := range tests {
+		got, err := client.Do(context.Background(), []byte(tt.command))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s got = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestServer_HistoryPages(t *testing.T) {
+	svc := newTestService()
+	// Больше card.MaxSearchLimit операций: ответ собирается из нескольких страниц поиска
+	if err := svc.MakeTransactions(1, card.MaxSearchLimit); err != nil {
+		t.Fatal(err)
+	}
+	want := 2*card.MaxSearchLimit + 2
+
+	client := newTestServerClient(t, newServer(svc, nil))
+	status, lines := client.do("HISTORY 1")
+	if status != "200 OK" || len(lines) != want {
+		t.Fatalf("HISTORY got = %q, %v lines, want %v", status, len(lines), want)
+	}
+	// Страницы идут друг за другом без повторов
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		seen[strings.Fields(line)[0]] = true
+	}
+	if len(seen) != want {
+		t.Errorf("HISTORY got %v distinct operations, want %v", len(seen), want)
+	}
+
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		newServer(svc, nil).handle(serverConn)
+		close(done)
+	}()
+	frames := frame.NewClient(clientConn)
+	defer func() {
+		_ = frames.Close()
+		<-done
+	}()
+	got, err := frames.Do(context.Background(), []byte("HISTORY 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frameLines := strings.Split(string(got), "\n"); frameLines[0] != "200 OK" || len(frameLines) != want+1 {
+		t.Errorf("HISTORY frame got %q, %v lines", frameLines[0], len(frameLines)-1)
+	}
+}
+
+func TestServer_LineTooLong(t *testing.T) {
+	client := newTestClient(t)
+	if err := client.conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	// Сервер не дочитывает длинную строку, поэтому запись завершится ошибкой после закрытия соединения
+	go func() {
+		_, _ = fmt.Fprintf(client.conn, "BALANCE %s\r\n", strings.Repeat("1", 2*maxLineLength))
+	}()
+
+	status, err := client.reader.ReadString('\n')
+	if err != nil || status != "400 line too long\r\n" {
+		t.Fatalf("long line status got = %q, %v", status, err)
+	}
+	if line, err := client.reader.ReadString('\n'); err != nil || line != ".\r\n" {
+		t.Errorf("long line end got = %q, %v", line, err)
+	}
+	if _, err = client.reader.ReadString('\n'); err != io.EOF {
+		t.Errorf("read after long line error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestServer_Reject(t *testing.T) {
+	s := newServer(newTestService(), nil)
+	tests := []struct {
+		name   string
+		framed bool
+		want   string
+	}{
+		{name: "Text", want: "503 too many connections"},
+		{name: "Frame", framed: true, want: "remote error: 503 too many connections"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConn, clientConn := net.Pipe()
+			done := make(chan struct{})
+			go func() {
+				s.reject(serverConn, netutil.ErrTooManyConnections)
+				_ = serverConn.Close()
+				close(done)
+			}()
+			defer func() {
+				_ = clientConn.Close()
+				<-done
+			}()
+
+			if tt.framed {
+				client := frame.NewClient(clientConn)
+				defer client.Close()
+				_, err := client.Do(context.Background(), []byte("BALANCE 1"))
+				if !errors.Is(err, frame.ErrRemote) || err.Error() != tt.want {
+					t.Errorf("Do() error = %v, want %q", err, tt.want)
+				}
+				return
+			}
+
+			client := &testClient{t: t, conn: clientConn, reader: bufio.NewReader(clientConn)}
+			if status, lines := client.do("BALANCE 1"); status != tt.want || len(lines) != 0 {
+				t.Errorf("reject got = %q %q, want %q", status, lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	clock := card.NewManualClock(time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC))
+	limiter := ratelimit.New(map[string]ratelimit.Limit{"BALANCE": {Rate: 0.5, Burst: 2}}, ratelimit.Limit{Rate: 0.5, Burst: 1})
+	limiter.Clock = clock
+	client := newTestServerClient(t, newServer(newTestService(), limiter))
+
+	for i := 0; i < 2; i++ {
+		if status, _ := client.do("BALANCE 1"); status != "200 OK" {
+			t.Fatalf("BALANCE %d status got = %q", i, status)
+		}
+	}
+	if status, _ := client.do("balance 1"); status != "429 too many requests, retry after 2" {
+		t.Errorf("BALANCE over limit status got = %q", status)
+	}
+	// Другие команды ограничиваются отдельно
+	if status, _ := client.do("HISTORY 1"); status != "200 OK" {
+		t.Errorf("HISTORY status got = %q", status)
+	}
+
+	// Неизвестные команды делят одно ограничение
+	if status, _ := client.do("FOO"); status != "400 unknown command FOO" {
+		t.Errorf("FOO status got = %q", status)
+	}
+	if status, _ := client.do("BAR"); status != "429 too many requests, retry after 2" {
+		t.Errorf("BAR over limit status got = %q", status)
+	}
+
+	clock.Advance(2 * time.Second)
+	if status, _ := client.do("BALANCE 1"); status != "200 OK" {
+		t.Errorf("BALANCE after refill status got = %q", status)
+	}
+	if status, _ := client.do("QUIT"); status != "221 BYE" {
+		t.Errorf("QUIT status got = %q", status)
+	}
+}
+
+func TestServer_Auth(t *testing.T) {
+	clock := card.NewManualClock(time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC))
+	tokens := auth.NewTokens()
+	tokens.Clock = clock
+	reader, _, err := tokens.Issue("report", card.RoleAuditor, []auth.Scope{auth.ScopeReadOperations}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditor, _, err := tokens.Issue("audit", card.RoleAuditor, []auth.Scope{auth.ScopeWriteTransfers}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedToken, err := tokens.Issue("old", card.RoleAdmin, []auth.Scope{auth.ScopeWriteTransfers}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tokens.Revoke(revokedToken.Id); err != nil {
+		t.Fatal(err)
+	}
+	svc := newTestService()
+	svc.RequirePrincipal = true
+	s := newServer(svc, nil)
+	s.tokens = tokens
+
+	client := newTestServerClient(t, s)
+	tests := []struct {
+		name       string
+		command    string
+		wantStatus string
+		wantLines  []string
+	}{
+		{name: "Before AUTH", command: "BALANCE 1", wantStatus: "401 authentication required"},
+		{name: "Unknown command before AUTH", command: "DEPOSIT 1 100", wantStatus: "401 authentication required"},
+		{name: "Invalid token", command: "AUTH bgo_0000000000000000_secret", wantStatus: "401 invalid token"},
+		{name: "Revoked token", command: "AUTH " + revoked, wantStatus: "401 token revoked"},
+		{name: "AUTH usage", command: "AUTH", wantStatus: "400 usage: AUTH <token>"},
+		{name: "AUTH", command: "auth " + reader, wantStatus: "200 OK", wantLines: []string{"read:operations"}},
+		{name: "Allowed command", command: "BALANCE 1", wantStatus: "200 OK", wantLines: []string{"100000 RUB"}},
+		{name: "Command without scope", command: "TRANSFER 1 2 100", wantStatus: "403 insufficient scope"},
+		{name: "Unknown command", command: "DEPOSIT 1 100", wantStatus: "400 unknown command DEPOSIT"},
+		{name: "AUTH with other token", command: "AUTH " + auditor, wantStatus: "200 OK", wantLines: []string{"write:transfers"}},
+		{name: "Command forbidden for role", command: "TRANSFER 1 2 100", wantStatus: "403 forbidden: token:audit (auditor) can not transfer card 1"},
+	}
+	for _, tt := range tests {
+		status, lines := client.do(tt.command)
+		if status != tt.wantStatus || !reflect.DeepEqual(lines, tt.wantLines) {
+			t.Errorf("%s: got = %q %q, want %q %q", tt.name, status, lines, tt.wantStatus, tt.wantLines)
+		}
+	}
+
+	// Токен проверяется при каждой команде
+	clock.Advance(time.Hour)
+	if status, _ := client.do("BALANCE 1"); status != "401 token expired" {
+		t.Errorf("BALANCE after expiry status got = %q", status)
+	}
+	if status, _ := client.do("QUIT"); status != "221 BYE" {
+		t.Errorf("QUIT status got = %q", status)
+	}
+
+	// Без хранилища токенов вход не нужен и не поддерживается
+	open := newTestClient(t)
+	if status, _ := open.do("AUTH " + reader); status != "400 authentication is disabled" {
+		t.Errorf("AUTH without tokens status got = %q", status)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{name: "AUTH", line: "AUTH bgo_0000000000000001_secret\r\n", want: "AUTH [redacted]"},
+		{name: "Lower case AUTH", line: "auth bgo_0000000000000001_secret\n", want: "auth [redacted]"},
+		{name: "AUTH without token", line: "AUTH\r\n", want: "AUTH"},
+		{name: "Other command", line: "BALANCE 1\r\n", want: "BALANCE 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redact(tt.line); got != tt.want {
+				t.Errorf("redact() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Формат дат в параметрах командной строки
+const dateLayout = "2006-01-02"
+
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	if err := execute(os.Args[1:]); err != nil {
+		if err == errUsage {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func execute(args []string) (err error) {
+	flags := flag.NewFlagSet("txgen", flag.ContinueOnError)
+	count := flags.Int("n", 1000, "number of transactions")
+	format := flags.String("format", "csv", "output format: csv, json or xml")
+	output := flags.String("o", "-", "output file, - for stdout")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "random seed")
+	from := flags.String("from", "", "first day, "+dateLayout+" (default 90 days before -to)")
+	to := flags.String("to", "", "day after the last one, "+dateLayout+" (default today)")
+	mcc := flags.String("mcc", "", "category weights, e.g. 5411:30,5812:10 (default realistic mix)")
+	declined := flags.Float64("declined", 3, "percent of declined transactions")
+	ids := flags.String("ids", "sequence", "transaction ids: sequence, ulid or uuid")
+	if err = flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	config, err := buildConfig(*seed, *from, *to, *mcc, *declined, *ids)
+	if err != nil {
+		log.Println(err)
+		return errUsage
+	}
+	generator, err := card.NewGenerator(config)
+	if err != nil {
+		log.Println(err)
+		return errUsage
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		var file *os.File
+		file, err = os.Create(*output)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		defer func(c io.Closer) {
+			if cerr := c.Close(); cerr != nil {
+				log.Println(cerr)
+				if err == nil {
+					err = cerr
+				}
+			}
+		}(file)
+		out = file
+	}
+
+	var writer card.TransactionWriter
+	switch *format {
+	case "csv":
+		writer = card.NewCsvTransactionWriter(out)
+	case "json":
+		writer = card.NewJsonTransactionWriter(out)
+	case "xml":
+		writer = card.NewXmlTransactionWriter(out)
+	default:
+		log.Printf("unknown format: %s", *format)
+		return errUsage
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err = generator.Generate(ctx, *count, writer); err != nil {
+		log.Println(err)
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// Функция сборки настроек генератора из параметров командной строки
+func buildConfig(seed int64, from, to, mcc string, declined float64, ids string) (card.GeneratorConfig, error) {
+	end := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if to != "" {
+		var err error
+		if end, err = time.Parse(dateLayout, to); err != nil {
+			return card.GeneratorConfig{}, err
+		}
+	}
+
+	config := card.DefaultGeneratorConfig(seed, end)
+	if from != "" {
+		start, err := time.Parse(dateLayout, from)
+		if err != nil {
+			return card.GeneratorConfig{}, err
+		}
+		config.From = start
+	}
+
+	if mcc != "" {
+		weights, err := parseWeights(mcc)
+		if err != nil {
+			return card.GeneratorConfig{}, err
+		}
+		// Для известных категорий сохраняются реалистичные суммы
+		amounts := make(map[string]card.AmountDistribution)
+		for _, m := range config.MCC {
+			amounts[m.Code] = m.Amount
+		}
+		for i := range weights {
+			weights[i].Amount = amounts[weights[i].Code]
+		}
+		config.MCC = weights
+	}
+
+	if declined < 0 || declined > 100 {
+		return card.GeneratorConfig{}, fmt.Errorf("declined percent out of range: %v", declined)
+	}
+	config.Statuses = []card.WeightedStatus{
+		{Status: card.StatusDone, Weight: 100 - declined},
+		{Status: card.StatusDeclined, Weight: declined},
+	}
+
+	switch ids {
+	case "sequence":
+		config.IDs = card.NewSequenceGenerator("gen")
+	case "ulid":
+		config.IDs = card.NewULIDGenerator(card.SystemClock{})
+	case "uuid":
+		config.IDs = card.NewUUIDGenerator(card.SystemClock{})
+	default:
+		return card.GeneratorConfig{}, fmt.Errorf("unknown id generator: %s", ids)
+	}
+
+	return config, nil
+}
+
+// Функция разбора весов категорий вида 5411:30,5812:10
+func parseWeights(value string) ([]card.WeightedMCC, error) {
+	var result []card.WeightedMCC
+	for _, part := range strings.Split(value, ",") {
+		pair := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid category weight: %s", part)
+		}
+		weight, err := strconv.ParseFloat(pair[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid category weight: %s", part)
+		}
+		result = append(result, card.WeightedMCC{Code: pair[0], Weight: weight})
+	}
+	return result, nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/auth"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errMissingCard  = errors.New("missing card")
+)
+
+// Имя cookie с токеном сессии
+const sessionCookie = "session"
+
+// Максимальный размер тела запроса
+const maxBodySize = 64 << 10
+
+// Пользователь запроса и его карты. Клиент видит только свои карты, сотрудник банка
+// и запрос с токеном API - карты всех клиентов. Операции над картами ограничивает роль principal в сервисе
+type viewer struct {
+	user      auth.User
+	session   auth.Session
+	cards     []card.CardId
+	token     *auth.Token // Токен API, nil для сессии
+	principal card.Principal
+}
+
+// Метод проверки права на маршрут. Токену нужно право маршрута, сессии клиента - все права, кроме выпуска карт.
+// Сессии сотрудника открыты все маршруты, запрещенные роли операции отклоняет сервис
+func (v *viewer) allows(scope auth.Scope) bool {
+	if v.token != nil {
+		return v.token.Allows(scope)
+	}
+	return scope != auth.ScopeAdminCards || v.principal.Role != card.RoleCustomer
+}
+
+// Ключ ограничения частоты запросов
+func (v *viewer) key() string {
+	if v.token != nil {
+		return "token:" + v.token.Id
+	}
+	return "user:" + v.user.Login
+}
+
+// Метод проверки, видна ли карта пользователю. Токену API доступны все карты
+func (v *viewer) owns(id card.CardId) bool {
+	if v.token != nil {
+		return true
+	}
+	for _, own := range v.cards {
+		if own == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Метод выбора карты по значению параметра: пустое значение - первая карта пользователя,
+// с токеном API карту нужно указать. Чужая карта считается ненайденной, чтобы не раскрывать
+// существование карт других клиентов
+func (v *viewer) card(value string) (card.CardId, error) {
+	if value == "" && v.token != nil {
+		return 0, errMissingCard
+	}
+	if value == "" {
+		if len(v.cards) == 0 {
+			return 0, card.ErrCardNotFound
+		}
+		return v.cards[0], nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if !v.owns(card.CardId(id)) {
+		return 0, card.ErrCardNotFound
+	}
+	return card.CardId(id), nil
+}
+
+// Метод определения пользователя по заголовку Authorization с токеном API или по cookie сессии.
+// Без действующей сессии возвращает nil, ошибка возвращается только для неверного токена
+func (s *server) authenticate(header textproto.MIMEHeader) (*viewer, error) {
+	if authorization := header.Get("Authorization"); authorization != "" {
+		return s.authenticateToken(authorization)
+	}
+
+	token := readCookie(header, sessionCookie)
+	if token == "" {
+		return nil, nil
+	}
+	session, err := s.sessions.Verify(token)
+	if err != nil {
+		return nil, nil
+	}
+	user, err := s.users.User(session.Login)
+	if err != nil {
+		return nil, nil
+	}
+	principal := user.Principal()
+	cards := s.svc.CardsContext(card.WithPrincipal(context.Background(), principal))
+	return &viewer{user: user, session: session, cards: cards, principal: principal}, nil
+}
+
+// Метод проверки заголовка "Authorization: Bearer <токен>". Без хранилища токенов любой токен неверен
+func (s *server) authenticateToken(authorization string) (*viewer, error) {
+	parts := strings.Fields(authorization)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || s.tokens == nil {
+		return nil, auth.ErrInvalidToken
+	}
+	token, err := s.tokens.Verify(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return &viewer{token: &token, principal: token.Principal()}, nil
+}
+
+// Данные страницы входа
+type loginPage struct {
+	Login string
+	Error string
+}
+
+// Метод обработки /login: GET показывает форму, POST проверяет логин и пароль и выдает cookie сессии
+func (s *server) handleLogin(writer io.Writer, method string, body []byte) error {
+	switch method {
+	case http.MethodGet:
+		return writeLoginPage(writer, http.StatusOK, loginPage{})
+	case http.MethodPost:
+	default:
+		return writeError(writer, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+	login := form.Get("login")
+	user, err := s.users.Authenticate(login, form.Get("password"))
+	if err == auth.ErrInvalidCredentials {
+		return writeLoginPage(writer, http.StatusUnauthorized, loginPage{Login: login, Error: "Неверный логин или пароль"})
+	}
+	if err != nil {
+		return err
+	}
+
+	token, session, err := s.sessions.Issue(user.Login)
+	if err != nil {
+		return err
+	}
+	log.Printf("login: %s", user.Login)
+	return writeRedirect(writer, "/", s.sessionCookie(token, session.Expires))
+}
+
+// Метод обработки /logout: отзывает сессию и удаляет cookie
+func (s *server) handleLogout(writer io.Writer, method string, v *viewer) error {
+	if method != http.MethodPost {
+		return writeError(writer, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+	if v != nil && v.token == nil {
+		s.sessions.Revoke(v.session)
+		log.Printf("logout: %s", v.user.Login)
+	}
+	return writeRedirect(writer, "/login", s.sessionCookie("", time.Unix(0, 0)))
+}
+
+// Метод формирования заголовка Set-Cookie. Прошедший срок expires удаляет cookie
+func (s *server) sessionCookie(token string, expires time.Time) string {
+	maxAge := int(time.Until(expires).Seconds())
+	if maxAge < 0 {
+		maxAge = -1
+	}
+	cookie := fmt.Sprintf("Set-Cookie: %s=%s; Path=/; Expires=%s; Max-Age=%d; HttpOnly; SameSite=Lax",
+		sessionCookie, token, expires.UTC().Format(http.TimeFormat), maxAge)
+	if s.secure {
+		cookie += "; Secure"
+	}
+	return cookie
+}
+
+// Функция ответа на запрос без сессии: страницы перенаправляют на вход, данные получают 401
+func writeUnauthorized(writer io.Writer, path string) error {
+	if path == "/" || strings.HasPrefix(path, "/cards/") && filepath.Ext(path) == "" {
+		return writeRedirect(writer, "/login")
+	}
+	return writeError(writer, http.StatusUnauthorized, errUnauthorized)
+}
+
+// Функция ответа на запрос с неверным токеном API по RFC 6750
+func writeInvalidToken(writer io.Writer, cause error) error {
+	page := []byte(cause.Error())
+	return writeResponse(writer, http.StatusUnauthorized, []string{
+		`WWW-Authenticate: Bearer error="invalid_token"`,
+		"Content-Type: text/plain;charset=utf-8",
+		fmt.Sprintf("Content-Length: %d", len(page)),
+		"Connection: close",
+	}, page)
+}
+
+// Функция ответа на запрос без права scope. Токену API сообщается недостающее право
+func writeForbidden(writer io.Writer, v *viewer, scope auth.Scope) error {
+	page := []byte(auth.ErrInsufficientScope.Error())
+	headers := []string{
+		"Content-Type: text/plain;charset=utf-8",
+		fmt.Sprintf("Content-Length: %d", len(page)),
+		"Connection: close",
+	}
+	if v.token != nil {
+		headers = append([]string{fmt.Sprintf(`WWW-Authenticate: Bearer error="insufficient_scope", scope="%s"`, scope)}, headers...)
+	}
+	return writeResponse(writer, http.StatusForbidden, headers, page)
+}
+
+func writeLoginPage(writer io.Writer, status int, data loginPage) error {
+	tmpl, err := template.ParseFiles(filepath.Join(templateDir, "login.html"))
+	if err != nil {
+		return err
+	}
+	var page bytes.Buffer
+	if err = tmpl.Execute(&page, data); err != nil {
+		return err
+	}
+	return writeResponse(writer, status, []string{
+		"Content-Type: text/html;charset=utf-8",
+		fmt.Sprintf("Content-Length: %d", page.Len()),
+		"Connection: close",
+	}, page.Bytes())
+}
+
+// Функция перенаправления запросом GET на location с дополнительными заголовками
+func writeRedirect(writer io.Writer, location string, headers ...string) error {
+	return writeResponse(writer, http.StatusSeeOther, append([]string{
+		"Location: " + location,
+		"Content-Length: 0",
+		"Connection: close",
+	}, headers...), nil)
+}
+
+// Функция получения значения cookie из заголовков запроса
+func readCookie(header textproto.MIMEHeader, name string) string {
+	for _, line := range header.Values("Cookie") {
+		for _, pair := range strings.Split(line, ";") {
+			parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(parts) == 2 && parts[0] == name {
+				return parts[1]
+			}
+		}
+	}
+	return ""
+}
+
+// Функция чтения тела запроса по Content-Length
+func readBody(r io.Reader, header textproto.MIMEHeader) ([]byte, error) {
+	value := header.Get("Content-Length")
+	if value == "" {
+		return nil, nil
+	}
+	length, err := strconv.Atoi(value)
+	if err != nil || length < 0 || length > maxBodySize {
+		return nil, errors.New("invalid content length")
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return body, err
+}
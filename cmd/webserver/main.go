@@ -3,32 +3,242 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
-	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/auth"
 	"github.com/ArtDark/bgo_network/pkg/card"
+	"github.com/ArtDark/bgo_network/pkg/netutil"
+	"github.com/ArtDark/bgo_network/pkg/ratelimit"
+	"github.com/ArtDark/bgo_network/pkg/tlsutil"
+	"html"
+	"html/template"
 	"io"
 	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
+var (
+	errMethodNotAllowed = errors.New("method not allowed")
+	errInternal         = errors.New("internal error")
+)
+
+// Максимальное время обработки запроса
+const requestTimeout = time.Second * 30
+
+// Ограничения соединений: при занятых местах новое соединение ждет секунду, затем получает 503,
+// ждать могут не больше 1000 соединений; соединение, не присылающее данных минуту, закрывается
+var connLimits = netutil.Limits{MaxConns: 1000, MaxConnsPerIP: 100, QueueTimeout: time.Second, MaxQueued: 1000, IdleTimeout: time.Minute}
+
+// Время, за которое отклоненный клиент должен прислать строку запроса и принять ответ
+const rejectTimeout = time.Second
+
+// Ограничения частоты запросов пользователя или, до входа, адреса по маршрутам из routeOf.
+// Выгрузки операций и выписок ограничены сильнее главной страницы
+var routeLimits = map[string]ratelimit.Limit{
+	"/":              {Rate: 10, Burst: 20},
+	"/operations":    {Rate: 1, Burst: 5},
+	"/cards":         {Rate: 1, Burst: 5},
+	"/transfer.json": {Rate: 2, Burst: 5},
+}
+
+// Ограничение частоты запросов к остальным маршрутам
+var defaultRouteLimit = ratelimit.Limit{Rate: 5, Burst: 10}
+
+// Маршрут ограничителя, общий для всех путей без своего ограничения
+const otherRoutes = "*"
+
+// Права, нужные для маршрутов из routeOf. Маршруты без права доступны только по сессии пользователя
+var routeScopes = map[string]auth.Scope{
+	"/operations":     auth.ScopeReadOperations,
+	"/cards":          auth.ScopeReadOperations,
+	"/budgets.json":   auth.ScopeReadOperations,
+	"/analytics.json": auth.ScopeReadOperations,
+	"/alerts.json":    auth.ScopeReadOperations,
+	"/balance.json":   auth.ScopeReadOperations,
+	"/transfer.json":  auth.ScopeWriteTransfers,
+	"/cards.json":     auth.ScopeAdminCards,
+}
+
+// Каталог с html шаблонами относительно корня репозитория
+const templateDir = "web/template"
+
+// Карта демонстрационного пользователя ivan
+const demoCardId card.CardId = 1
+
+// Демонстрационные клиенты, с ними связаны карты и пользователи ivan и petr
+var (
+	demoIvan = card.Owner{CustomerId: 1, FirstName: "Ivan", LastName: "Ivanov"}
+	demoPetr = card.Owner{CustomerId: 2, FirstName: "Petr", LastName: "Petrov"}
+)
+
+type server struct {
+	svc      *card.Service
+	limiter  *ratelimit.Limiter
+	users    *auth.Store
+	sessions *auth.Sessions
+	tokens   *auth.Tokens  // Без хранилища токены API не принимаются
+	secure   bool          // Соединения защищены TLS, cookie передаются только по TLS
+	delay    time.Duration // Обычно responseDelay, в тестах задержки нет
+}
+
+// Задержка перед выполнением запроса, имитирующая медленный сервер
+const responseDelay = 10 * time.Second
+
+func newServer(svc *card.Service, limiter *ratelimit.Limiter, users *auth.Store, sessions *auth.Sessions) *server {
+	return &server{svc: svc, limiter: limiter, users: users, sessions: sessions, delay: responseDelay}
+}
+
 func main() {
 
-	if err := execute(); err != nil {
+	if err := execute(os.Args[1:]); err != nil {
 		os.Exit(1)
 	}
 }
 
-func execute() (err error) {
-	listener, err := net.Listen("tcp", "0.0.0.0:9999")
+// Функция создания сервиса с демонстрационными данными
+func newDemoService() (*card.Service, error) {
+	svc := card.New("Bank")
+	_, err := svc.CardIssueContext(context.Background(), demoCardId, demoIvan, "Visa", 103242_00, "RUB", "5106 2100 0000 0001")
+	if err != nil {
+		return nil, err
+	}
+	_, err = svc.CardIssueContext(context.Background(), 2, demoPetr, "MasterCard", 5000_00, "RUB", "5106 2100 0000 0002")
+	if err != nil {
+		return nil, err
+	}
+	err = svc.MakeTransactions(demoCardId, 10)
+	if err != nil {
+		return nil, err
+	}
+	err = svc.MakeTransactions(2, 3)
+	if err != nil {
+		return nil, err
+	}
+	err = svc.SetBudget(demoCardId, "5812", 20000_00, card.BudgetFlag)
+	if err != nil {
+		return nil, err
+	}
+	// Ежемесячная подписка на онлайн-кинотеатр
+	subscription, err := card.ParseCron("0 12 1 * *")
+	if err != nil {
+		return nil, err
+	}
+	_, err = svc.AddRecurringCharge(demoCardId, 299_00, "5815", subscription)
+	if err != nil {
+		return nil, err
+	}
+	// Запросы выполняются только от имени пользователя или токена
+	svc.RequirePrincipal = true
+	return svc, nil
+}
+
+// Функция создания демонстрационных пользователей: клиентов ivan и petr и сотрудников operator, auditor и admin
+// с паролями, совпадающими с логином
+func newDemoUsers() (*auth.Store, error) {
+	users := auth.NewStore()
+	if err := users.Add("ivan", "ivan", demoIvan); err != nil {
+		return nil, err
+	}
+	if err := users.Add("petr", "petr", demoPetr); err != nil {
+		return nil, err
+	}
+	for _, role := range []card.Role{card.RoleOperator, card.RoleAuditor, card.RoleAdmin} {
+		if err := users.AddStaff(string(role), string(role), role); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func execute(args []string) (err error) {
+	flags := flag.NewFlagSet("webserver", flag.ContinueOnError)
+	addr := flags.String("addr", "0.0.0.0:9999", "listen address")
+	certFile := flags.String("tls-cert", "", "certificate file, enables tls")
+	keyFile := flags.String("tls-key", "", "private key file of -tls-cert")
+	sessionKey := flags.String("session-key", "", "hex key of session cookies signature, random if empty")
+	tokensFile := flags.String("tokens", "", "api tokens file of cmd/apitoken, reloaded on SIGHUP")
+	mccFile := flags.String("mcc-overrides", "", "csv file overriding entries of the built-in mcc directory")
+	if err = flags.Parse(args); err != nil {
+		return err
+	}
+	files := tlsutil.Files{CertFile: *certFile, KeyFile: *keyFile}
+	if err = files.Validate(); err != nil {
+		log.Println(err)
+		return err
+	}
+	if *mccFile != "" {
+		if err = card.LoadMCCOverrides(*mccFile); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+
+	svc, err := newDemoService()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	users, err := newDemoUsers()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	// Со случайным ключом сессии действуют до перезапуска сервера
+	key, err := hex.DecodeString(*sessionKey)
+	if err == nil && len(key) == 0 {
+		key, err = auth.NewSessionKey()
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	s := newServer(svc, ratelimit.New(routeLimits, defaultRouteLimit), users, auth.NewSessions(key, auth.DefaultSessionTTL))
+	s.secure = files.CertFile != ""
+	if *tokensFile != "" {
+		s.tokens, err = auth.LoadTokens(*tokensFile)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		go s.tokens.ReloadOnSignal(context.Background(), syscall.SIGHUP)
+	}
+
+	go func() {
+		if err := card.NewScheduler(svc, card.SystemClock{}).Run(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	listener, err := net.Listen("tcp", *addr)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
+	if files.CertFile != "" {
+		reloader, err := tlsutil.NewReloader(files)
+		if err != nil {
+			_ = listener.Close()
+			log.Println(err)
+			return err
+		}
+		go reloader.ReloadOnSignal(context.Background(), syscall.SIGHUP)
+		listener = tls.NewListener(listener, reloader.Config())
+	}
 	defer func() {
 		if cerr := listener.Close(); cerr != nil {
 			log.Println(cerr)
@@ -37,17 +247,35 @@ func execute() (err error) {
 			}
 		}
 	}()
-	for {
-		conn, err := listener.Accept() // для клиентов
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-		go handle(conn)
+	if err = netutil.Serve(listener, connLimits, s.handle, s.reject); err != nil {
+		log.Println(err)
 	}
+	return err
 }
 
-func handle(conn net.Conn) {
+// Метод отказа в обслуживании: после строки запроса отправляется ответ 503 с Retry-After
+func (s *server) reject(conn net.Conn, cause error) {
+	if err := conn.SetDeadline(time.Now().Add(rejectTimeout)); err != nil {
+		log.Println(err)
+		return
+	}
+	// Строку запроса нужно прочитать, иначе закрытие соединения с непрочитанными данными
+	// может сбросить его вместе с ответом
+	_, _ = bufio.NewReader(conn).ReadString('\n')
+
+	page := []byte(cause.Error())
+	err := writeResponse(conn, http.StatusServiceUnavailable, []string{
+		"Content-Type: text/plain;charset=utf-8",
+		fmt.Sprintf("Content-Length: %d", len(page)),
+		"Retry-After: 1",
+		"Connection: close",
+	}, page)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
 	defer func() {
 		if cerr := conn.Close(); cerr != nil {
 			log.Println(cerr)
@@ -71,21 +299,61 @@ func handle(conn net.Conn) {
 		log.Printf("invalid request line: %s", line)
 		return
 	}
+	method := parts[0]
 
-	time.Sleep(time.Second * 10)
-	path := parts[1]
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	body, err := readBody(r, header)
+	if err != nil {
+		log.Println(err)
+		return
+	}
 
-	switch path {
-	case "/":
-		err = writeIndex(conn)
-	case "/operations.csv":
-		err = writeOperationsToCsv(conn)
-	case "/operations.json":
-		err = writeOperationsToJson(conn)
-	case "/operations.xml":
-		err = writeOperationsToXml(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	go watchConnection(r, cancel)
+
+	uri, err := url.ParseRequestURI(parts[1])
+	if err != nil {
+		log.Printf("invalid request uri: %s", parts[1])
+		return
+	}
+
+	// Частота запросов вошедшего пользователя ограничивается по логину, с токеном API - по токену,
+	// остальных, в том числе с неверным токеном, - по адресу
+	v, authErr := s.authenticate(header)
+	key := netutil.RemoteIP(conn)
+	if v != nil {
+		key = v.key()
+	}
+	if ok, wait := s.limiter.Allow(limitRoute(uri.Path), key); !ok {
+		if err = writeTooManyRequests(conn, wait); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		log.Println(ctx.Err())
+		return
+	}
+
+	switch {
+	case authErr != nil:
+		err = writeInvalidToken(conn, authErr)
+	case uri.Path == "/login":
+		err = s.handleLogin(conn, method, body)
+	case uri.Path == "/logout":
+		err = s.handleLogout(conn, method, v)
+	case v == nil:
+		err = writeUnauthorized(conn, uri.Path)
 	default:
-		err = write404(conn)
+		err = s.route(ctx, conn, method, uri, v)
 	}
 	if err != nil {
 		log.Println(err)
@@ -93,17 +361,119 @@ func handle(conn net.Conn) {
 	}
 }
 
-func writeIndex(writer io.Writer) error {
-	username := "Ivan"
-	balance := "103242"
+// Метод выбора обработчика запроса вошедшего пользователя с проверкой права на маршрут.
+// Операции сервиса выполняются от имени пользователя или токена
+func (s *server) route(ctx context.Context, conn net.Conn, method string, uri *url.URL, v *viewer) error {
+	scope, ok := routeScopes[routeOf(uri.Path)]
+	if !ok && v.token != nil || ok && !v.allows(scope) {
+		return writeForbidden(conn, v, scope)
+	}
+	ctx = card.WithPrincipal(ctx, v.principal)
 
-	page, err := ioutil.ReadFile("webserver/template/index.html")
+	switch uri.Path {
+	case "/":
+		return s.writeIndex(ctx, conn, v)
+	case "/operations.csv", "/operations.json", "/operations.xml":
+		return s.writeOperations(ctx, conn, uri, v)
+	case "/budgets.json":
+		return s.writeBudgets(ctx, conn, uri.Query(), v)
+	case "/analytics.json":
+		return s.writeAnalytics(ctx, conn, uri.Query(), v)
+	case "/alerts.json":
+		return s.writeAlerts(ctx, conn, uri.Query(), v)
+	case "/balance.json":
+		return s.writeBalance(ctx, conn, uri.Query(), v)
+	case "/transfer.json":
+		if method != http.MethodPost {
+			return writeError(conn, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		}
+		return s.writeTransfer(ctx, conn, uri.Query(), v)
+	case "/cards.json":
+		if method != http.MethodPost {
+			return writeError(conn, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		}
+		return s.writeCard(ctx, conn, uri.Query())
+	}
+	if strings.HasPrefix(uri.Path, "/cards/") {
+		return s.writeStatement(ctx, conn, uri.Path, v)
+	}
+	return write404(conn)
+}
+
+// Функция выбора маршрута для ограничения частоты: форматы выгрузки операций
+// и выписки всех карт делят одно ограничение
+func routeOf(path string) string {
+	if strings.HasPrefix(path, "/cards/") {
+		return "/cards"
+	}
+	if strings.HasPrefix(path, "/operations.") {
+		return "/operations"
+	}
+	return path
+}
+
+// Функция выбора маршрута ограничителя для пути. Пути, которых нет в routeLimits, делят один
+// маршрут, иначе каждый выдуманный путь заводил бы в ограничителе новую корзину
+func limitRoute(path string) string {
+	route := routeOf(path)
+	if _, ok := routeLimits[route]; ok {
+		return route
+	}
+	return otherRoutes
+}
+
+// Функция ответа 429 с временем до следующей разрешенной попытки
+func writeTooManyRequests(writer io.Writer, wait time.Duration) error {
+	page := []byte("too many requests")
+
+	return writeResponse(writer, http.StatusTooManyRequests, []string{
+		"Content-Type: text/plain;charset=utf-8",
+		fmt.Sprintf("Content-Length: %d", len(page)),
+		fmt.Sprintf("Retry-After: %d", ratelimit.RetryAfterSeconds(wait)),
+		"Connection: close",
+	}, page)
+}
+
+// Функция отслеживания разрыва соединения клиентом.
+// Вычитывает остаток запроса и отменяет контекст, когда чтение завершается ошибкой. Конец данных
+// запрос не отменяет: клиент мог закрыть только свою сторону записи и ждать ответа
+func watchConnection(r io.Reader, cancel context.CancelFunc) {
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		cancel()
+	}
+}
+
+// Метод выдачи главной страницы пользователя с балансом и лимитами его первой карты
+func (s *server) writeIndex(ctx context.Context, writer io.Writer, v *viewer) error {
+	page, err := ioutil.ReadFile(filepath.Join(templateDir, "index.html"))
 
 	if err != nil {
 		return err
 	}
-	page = bytes.ReplaceAll(page, []byte("{username}"), []byte(username))
-	page = bytes.ReplaceAll(page, []byte("{balance}"), []byte(balance))
+
+	var cardId card.CardId
+	var balance int
+	var budgets []card.BudgetStatus
+	if len(v.cards) != 0 {
+		cardId = v.cards[0]
+		balance, _, err = s.svc.BalanceContext(ctx, cardId)
+		if err != nil {
+			return err
+		}
+		budgets, err = s.svc.BudgetReportContext(ctx, cardId, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	name := v.user.Owner.FirstName
+	if name == "" {
+		name = v.user.Login
+	}
+	page = bytes.ReplaceAll(page, []byte("{username}"), []byte(html.EscapeString(name)))
+	page = bytes.ReplaceAll(page, []byte("{balance}"), []byte(formatMoney(int64(balance))))
+	page = bytes.ReplaceAll(page, []byte("{card}"), []byte(strconv.FormatInt(int64(cardId), 10)))
+	page = bytes.ReplaceAll(page, []byte("{budgets}"), renderBudgets(budgets))
+	page = bytes.ReplaceAll(page, []byte("{month}"), []byte(time.Now().UTC().Format("2006-01")))
 
 	return writeResponse(writer, 200, []string{
 		"Content-Type: text/html;charset=utf-8",
@@ -112,27 +482,343 @@ func writeIndex(writer io.Writer) error {
 	}, page)
 }
 
-func writeOperationsToCsv(writer io.Writer) error {
-	// TODO: Generate CSV
-	page := []byte("xxxx,0001,0002,1592373247\n")
+// Функция формирования html блока с прогрессом трат по лимитам
+func renderBudgets(budgets []card.BudgetStatus) []byte {
+	var b bytes.Buffer
+	for _, budget := range budgets {
+		spent := budget.Spent
+		if spent < 0 {
+			spent = 0
+		}
+		if spent > budget.Limit {
+			spent = budget.Limit
+		}
+		_, _ = fmt.Fprintf(
+			&b,
+			"<p>%s: %d из %d р.<br><progress value=\"%d\" max=\"%d\"></progress></p>\n",
+			html.EscapeString(budget.Name),
+			budget.Spent/100,
+			budget.Limit/100,
+			spent,
+			budget.Limit,
+		)
+	}
+	return b.Bytes()
+}
 
+// Типы содержимого выгрузки операций
+var operationContentTypes = map[string]string{
+	"csv":  "text/csv",
+	"json": "application/json",
+	"xml":  "application/xml",
+}
+
+// Метод выгрузки операций карты в формате csv, json или xml.
+// Параметры запроса: card, from и to в формате 2006-01-02, min и max в копейках, mcc через запятую,
+// status, id (префикс идентификатора), q (слова для поиска), sort (time, bill, id), order (asc, desc),
+// limit и cursor. Ссылка на следующую страницу передается в заголовке Link с rel="next".
+// С total=true в заголовке X-Total-Count передается количество операций на всех страницах:
+// подсчет просматривает все подходящие операции, поэтому по умолчанию выключен
+func (s *server) writeOperations(ctx context.Context, writer io.Writer, uri *url.URL, v *viewer) error {
+	format := strings.TrimPrefix(filepath.Ext(uri.Path), ".")
+	params := uri.Query()
+
+	cardId, err := v.card(params.Get("card"))
+	if err == card.ErrCardNotFound {
+		return writeError(writer, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+
+	query, err := parseSearchQuery(params)
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+
+	result, err := s.svc.SearchTransactionsContext(ctx, cardId, query)
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+	if errors.Is(err, card.ErrForbidden) {
+		return writeError(writer, http.StatusForbidden, err)
+	}
+	if err == card.ErrCardNotFound {
+		return writeError(writer, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+
+	var page bytes.Buffer
+	var w card.TransactionWriter
+	switch format {
+	case "csv":
+		w = card.NewCsvTransactionWriter(&page)
+	case "json":
+		w = card.NewJsonTransactionWriter(&page)
+	default:
+		w = card.NewXmlTransactionWriter(&page)
+	}
+	for _, t := range result.Transactions {
+		if err = w.WriteTransaction(t); err != nil {
+			return err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	headers := []string{
+		"Content-Type: " + operationContentTypes[format],
+		fmt.Sprintf("Content-Length: %d", page.Len()),
+	}
+	if query.WithTotal {
+		headers = append(headers, fmt.Sprintf("X-Total-Count: %d", result.Total))
+	}
+	if result.NextCursor != "" {
+		params.Set("cursor", result.NextCursor)
+		next := url.URL{Path: uri.Path, RawQuery: params.Encode()}
+		headers = append(headers, fmt.Sprintf("Link: <%s>; rel=\"next\"", next.String()))
+	}
+	headers = append(headers, "Connection: close")
+
+	return writeResponse(writer, 200, headers, page.Bytes())
+}
+
+// Функция разбора параметров поиска операций
+func parseSearchQuery(params url.Values) (card.SearchQuery, error) {
+	query := card.SearchQuery{
+		Status:   params.Get("status"),
+		IdPrefix: params.Get("id"),
+		Text:     params.Get("q"),
+		Sort:     card.SortField(params.Get("sort")),
+		Cursor:   params.Get("cursor"),
+	}
+
+	var err error
+	if from := params.Get("from"); from != "" {
+		if query.From, err = time.Parse("2006-01-02", from); err != nil {
+			return card.SearchQuery{}, err
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		if query.To, err = time.Parse("2006-01-02", to); err != nil {
+			return card.SearchQuery{}, err
+		}
+	}
+	if min := params.Get("min"); min != "" {
+		value, err := strconv.ParseInt(min, 10, 64)
+		if err != nil {
+			return card.SearchQuery{}, err
+		}
+		query.MinBill = &value
+	}
+	if max := params.Get("max"); max != "" {
+		value, err := strconv.ParseInt(max, 10, 64)
+		if err != nil {
+			return card.SearchQuery{}, err
+		}
+		query.MaxBill = &value
+	}
+	if mcc := params.Get("mcc"); mcc != "" {
+		query.MCC = strings.Split(mcc, ",")
+	}
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return card.SearchQuery{}, fmt.Errorf("invalid order: %s", params.Get("order"))
+	}
+	if total := params.Get("total"); total != "" {
+		if query.WithTotal, err = strconv.ParseBool(total); err != nil {
+			return card.SearchQuery{}, err
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return card.SearchQuery{}, err
+		}
+	}
+
+	return query, nil
+}
+
+// Метод выдачи аналитики по операциям в формате json.
+// Параметры запроса: card, period (day, week, month, custom), group (mcc, category, status, issuer),
+// from и to в формате 2006-01-02. Без card аналитика строится по всем картам, доступным пользователю,
+// суммы в группах указаны в валюте карт из поля currency
+func (s *server) writeAnalytics(ctx context.Context, writer io.Writer, params url.Values, v *viewer) error {
+	query := card.AnalyticsQuery{
+		Period:  card.Period(params.Get("period")),
+		GroupBy: card.GroupBy(params.Get("group")),
+	}
+	if query.Period == "" {
+		query.Period = card.PeriodMonth
+	}
+	if query.GroupBy == "" {
+		query.GroupBy = card.GroupByMCC
+	}
+
+	var err error
+	if from := params.Get("from"); from != "" {
+		query.From, err = time.Parse("2006-01-02", from)
+		if err != nil {
+			return writeError(writer, http.StatusBadRequest, err)
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		query.To, err = time.Parse("2006-01-02", to)
+		if err != nil {
+			return writeError(writer, http.StatusBadRequest, err)
+		}
+	}
+
+	var buckets []card.AnalyticsBucket
+	if id := params.Get("card"); id != "" {
+		cardId, err := v.card(id)
+		if err == card.ErrCardNotFound {
+			return writeError(writer, http.StatusNotFound, err)
+		}
+		if err != nil {
+			return writeError(writer, http.StatusBadRequest, err)
+		}
+		buckets, err = s.svc.AnalyticsContext(ctx, cardId, query)
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return err
+		}
+		if errors.Is(err, card.ErrForbidden) {
+			return writeError(writer, http.StatusForbidden, err)
+		}
+		if err == card.ErrCardNotFound {
+			return writeError(writer, http.StatusNotFound, err)
+		}
+		if err != nil {
+			return writeError(writer, http.StatusBadRequest, err)
+		}
+	} else {
+		buckets, err = s.svc.AnalyticsAllContext(ctx, query)
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return err
+		}
+		if err != nil {
+			return writeError(writer, http.StatusBadRequest, err)
+		}
+	}
+
+	page, err := json.MarshalIndent(buckets, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeResponse(writer, 200, []string{
+		"Content-Type: application/json",
+		fmt.Sprintf("Content-Length: %d", len(page)),
+		"Connection: close",
+	}, page)
+}
+
+// Метод выдачи срабатываний правил поиска подозрительных операций в формате json.
+// Параметры запроса: card, rule, from и to в формате 2006-01-02. Без card выдаются срабатывания по всем картам,
+// доступным пользователю
+func (s *server) writeAlerts(ctx context.Context, writer io.Writer, params url.Values, v *viewer) error {
+	query := card.AlertQuery{Rule: params.Get("rule")}
+
+	var err error
+	if id := params.Get("card"); id != "" {
+		query.CardId, err = v.card(id)
+		if err == card.ErrCardNotFound {
+			return writeError(writer, http.StatusNotFound, err)
+		}
+		if err != nil {
+			return writeError(writer, http.StatusBadRequest, err)
+		}
+	}
+	if from := params.Get("from"); from != "" {
+		query.From, err = time.Parse("2006-01-02", from)
+		if err != nil {
+			return writeError(writer, http.StatusBadRequest, err)
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		query.To, err = time.Parse("2006-01-02", to)
+		if err != nil {
+			return writeError(writer, http.StatusBadRequest, err)
+		}
+	}
+
+	alerts, err := s.svc.AlertsContext(ctx, query)
+	if err != nil {
+		return writeServiceError(writer, err)
+	}
+
+	page, err := json.MarshalIndent(alerts, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeResponse(writer, 200, []string{
+		"Content-Type: application/json",
+		fmt.Sprintf("Content-Length: %d", len(page)),
+		"Connection: close",
+	}, page)
+}
+
+// Метод выдачи баланса карты в формате json. Параметр запроса: card, без него - первая карта пользователя
+func (s *server) writeBalance(ctx context.Context, writer io.Writer, params url.Values, v *viewer) error {
+	cardId, err := v.card(params.Get("card"))
+	if err == card.ErrCardNotFound {
+		return writeError(writer, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+
+	balance, currency, err := s.svc.BalanceContext(ctx, cardId)
+	if err != nil {
+		return writeServiceError(writer, err)
+	}
+
+	page, err := json.Marshal(struct {
+		CardId   card.CardId `json:"card_id"`
+		Balance  int         `json:"balance"`
+		Currency string      `json:"currency"`
+	}{cardId, balance, currency})
+	if err != nil {
+		return err
+	}
 	return writeResponse(writer, 200, []string{
-		"Content-Type: text/csv",
+		"Content-Type: application/json",
 		fmt.Sprintf("Content-Length: %d", len(page)),
 		"Connection: close",
 	}, page)
 }
 
-func writeOperationsToJson(writer io.Writer) error {
-	t := card.Transaction{
-		XMLName: "food",
-		Id:      "1",
-		Bill:    340,
-		Time:    1621975879,
-		MCC:     "5277",
-		Status:  "Ok",
+// Метод перевода между картами, выполняется только запросом POST.
+// Параметры запроса: from, to и amount в копейках. В ответе - исходящая транзакция в формате json.
+// Списывать можно с карты, на которую у пользователя есть право перевода, зачислять - на любую карту
+func (s *server) writeTransfer(ctx context.Context, writer io.Writer, params url.Values, v *viewer) error {
+	from, err := v.card(params.Get("from"))
+	if err == card.ErrCardNotFound {
+		return writeError(writer, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
 	}
-	page, err := json.MarshalIndent(t, "", " ")
+	to, err := strconv.ParseInt(params.Get("to"), 10, 64)
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+	amount, err := strconv.ParseInt(params.Get("amount"), 10, 64)
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+
+	transaction, err := s.svc.TransferContext(ctx, from, card.CardId(to), amount)
+	if err != nil {
+		return writeServiceError(writer, err)
+	}
+
+	page, err := json.Marshal(transaction)
 	if err != nil {
 		return err
 	}
@@ -143,28 +829,195 @@ func writeOperationsToJson(writer io.Writer) error {
 	}, page)
 }
 
-func writeOperationsToXml(writer io.Writer) error {
-	t := card.Transaction{
-		XMLName: "food",
-		Id:      "1",
-		Bill:    340,
-		Time:    1621975879,
-		MCC:     "5277",
-		Status:  "Ok",
+// Метод выпуска карты с нулевым балансом, выполняется только запросом POST.
+// Параметры запроса: id, customer (идентификатор клиента), first_name, last_name, issuer,
+// currency (по умолчанию RUB) и number
+func (s *server) writeCard(ctx context.Context, writer io.Writer, params url.Values) error {
+	id, err := strconv.ParseInt(params.Get("id"), 10, 64)
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+	customer, err := strconv.ParseInt(params.Get("customer"), 10, 64)
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+	currency := params.Get("currency")
+	if currency == "" {
+		currency = "RUB"
+	}
+	owner := card.Owner{CustomerId: card.CustomerId(customer), FirstName: params.Get("first_name"), LastName: params.Get("last_name")}
+
+	c, err := s.svc.OpenCardContext(ctx, card.CardId(id), owner, params.Get("issuer"), currency, params.Get("number"))
+	if err != nil {
+		return writeServiceError(writer, err)
 	}
-	page, err := xml.MarshalIndent(t, "", " ")
+	log.Printf("card issued: %d", c.Id)
+
+	page, err := json.Marshal(struct {
+		CardId     card.CardId     `json:"card_id"`
+		CustomerId card.CustomerId `json:"customer_id"`
+		FirstName  string          `json:"first_name"`
+		LastName   string          `json:"last_name"`
+		Issuer     string          `json:"issuer"`
+		Currency   string          `json:"currency"`
+		Number     string          `json:"number"`
+	}{c.Id, c.CustomerId, c.FirstName, c.LastName, c.Issuer, c.Currency, c.Number})
+	if err != nil {
+		return err
+	}
+	return writeResponse(writer, http.StatusCreated, []string{
+		"Content-Type: application/json",
+		fmt.Sprintf("Content-Length: %d", len(page)),
+		"Connection: close",
+	}, page)
+}
+
+// Функции, доступные в шаблоне выписки
+var statementFuncs = template.FuncMap{
+	"money":    formatMoney,
+	"category": card.TranslateMCC,
+	"date": func(unix int64) string {
+		return time.Unix(unix, 0).UTC().Format("02.01.2006 15:04")
+	},
+}
+
+// Метод выдачи выписки по адресу /cards/{id}/statements/{yyyy-mm}.
+// Без расширения выписка выдается в html, с расширением .csv или .json - в соответствующем формате.
+// Выписка по чужой карте не выдается
+func (s *server) writeStatement(ctx context.Context, writer io.Writer, path string, v *viewer) error {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 4 || parts[2] != "statements" {
+		return write404(writer)
+	}
+	cardId, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return write404(writer)
+	}
+	if !v.owns(card.CardId(cardId)) {
+		return writeError(writer, http.StatusNotFound, card.ErrCardNotFound)
+	}
+
+	format := filepath.Ext(parts[3])
+	month, err := time.Parse("2006-01", strings.TrimSuffix(parts[3], format))
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+
+	statement, err := s.svc.StatementContext(ctx, card.CardId(cardId), month)
+	if err == card.ErrCardNotFound {
+		return writeError(writer, http.StatusNotFound, err)
+	}
+	if errors.Is(err, card.ErrForbidden) {
+		return writeError(writer, http.StatusForbidden, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	var page bytes.Buffer
+	var contentType string
+	switch format {
+	case "":
+		contentType = "text/html;charset=utf-8"
+		tmpl, err := template.New("statement.html").Funcs(statementFuncs).ParseFiles(filepath.Join(templateDir, "statement.html"))
+		if err != nil {
+			return err
+		}
+		err = tmpl.Execute(&page, statement)
+		if err != nil {
+			return err
+		}
+	case ".csv":
+		contentType = "text/csv;charset=utf-8"
+		err = card.WriteStatementCsv(&page, statement)
+	case ".json":
+		contentType = "application/json"
+		err = card.WriteStatementJson(&page, statement)
+	default:
+		return write404(writer)
+	}
+	if err != nil {
+		return err
+	}
+
+	headers := []string{
+		"Content-Type: " + contentType,
+		fmt.Sprintf("Content-Length: %d", page.Len()),
+	}
+	if format != "" {
+		headers = append(headers, fmt.Sprintf("Content-Disposition: attachment; filename=\"statement-%d-%s%s\"", cardId, statement.Period, format))
+	}
+	headers = append(headers, "Connection: close")
+
+	return writeResponse(writer, 200, headers, page.Bytes())
+}
+
+// Функция форматирования суммы в копейках: 155000 - "1550.00"
+func formatMoney(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// Метод выдачи состояния лимитов карты за текущий месяц в формате json.
+// Параметр запроса: card, без него - первая карта пользователя
+func (s *server) writeBudgets(ctx context.Context, writer io.Writer, params url.Values, v *viewer) error {
+	cardId, err := v.card(params.Get("card"))
+	if err == card.ErrCardNotFound {
+		return writeError(writer, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+
+	budgets, err := s.svc.BudgetReportContext(ctx, cardId, time.Now())
+	if err != nil {
+		return writeServiceError(writer, err)
+	}
+
+	page, err := json.MarshalIndent(budgets, "", " ")
 	if err != nil {
 		return err
 	}
 	return writeResponse(writer, 200, []string{
-		"Content-Type: application/xml",
+		"Content-Type: application/json",
 		fmt.Sprintf("Content-Length: %d", len(page)),
 		"Connection: close",
 	}, page)
 }
 
+func writeError(writer io.Writer, status int, cause error) error {
+	page := []byte(cause.Error())
+
+	return writeResponse(writer, status, []string{
+		"Content-Type: text/plain;charset=utf-8",
+		fmt.Sprintf("Content-Length: %d", len(page)),
+		"Connection: close",
+	}, page)
+}
+
+// Функция выбора кода статуса по ошибке сервиса. Отказ в доступе по роли - 403
+func writeServiceError(writer io.Writer, err error) error {
+	if errors.Is(err, card.ErrForbidden) {
+		return writeError(writer, http.StatusForbidden, err)
+	}
+	switch err {
+	case card.ErrCardNotFound:
+		return writeError(writer, http.StatusNotFound, err)
+	case card.ErrInsufficientFunds, card.ErrCardExists:
+		return writeError(writer, http.StatusConflict, err)
+	case card.ErrInvalidAmount, card.ErrInvalidTransfer, card.ErrInvalidCard:
+		return writeError(writer, http.StatusBadRequest, err)
+	}
+	log.Println(err)
+	return writeError(writer, http.StatusInternalServerError, errInternal)
+}
+
 func write404(writer io.Writer) error {
-	page, err := ioutil.ReadFile("webserver/template/404.html")
+	page, err := ioutil.ReadFile(filepath.Join(templateDir, "404.html"))
 	if err != nil {
 		return err
 	}
@@ -186,7 +1039,7 @@ func writeResponse(
 	var err error
 
 	w := bufio.NewWriter(writer)
-	_, err = w.WriteString(fmt.Sprintf("HTTP/1.1 %d OK%s", status, CRLF))
+	_, err = w.WriteString(fmt.Sprintf("HTTP/1.1 %d %s%s", status, http.StatusText(status), CRLF))
 	if err != nil {
 		return err
 	}
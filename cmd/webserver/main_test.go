@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/auth"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"github.com/ArtDark/bgo_network/pkg/ratelimit"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Функция создания сервера с демонстрационными данными и без задержки ответа
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+
+	svc, err := newDemoService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := newDemoUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := auth.NewSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(svc, ratelimit.New(routeLimits, defaultRouteLimit), users, auth.NewSessions(key, auth.DefaultSessionTTL))
+	s.tokens = auth.NewTokens()
+	s.delay = 0
+	return s
+}
+
+// Функция выполнения запроса к серверу по отдельному соединению net.Pipe. Заголовки передаются строками "Имя: значение"
+func doRequest(t *testing.T, s *server, method, target string, body string, headers ...string) (*http.Response, string) {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.handle(serverConn)
+		close(done)
+	}()
+	defer func() {
+		_ = clientConn.Close()
+		<-done
+	}()
+	if err := clientConn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	request := fmt.Sprintf("%s %s HTTP/1.1\r\nHost: localhost\r\n", method, target)
+	for _, header := range headers {
+		request += header + "\r\n"
+	}
+	if body != "" {
+		request += fmt.Sprintf("Content-Type: application/x-www-form-urlencoded\r\nContent-Length: %d\r\n", len(body))
+	}
+	if _, err := io.WriteString(clientConn, request+"\r\n"+body); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(clientConn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+// Функция входа пользователя, возвращает заголовок Cookie с сессией
+func login(t *testing.T, s *server, user string) string {
+	t.Helper()
+
+	resp, _ := doRequest(t, s, http.MethodPost, "/login", "login="+user+"&password="+user)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("login status got = %v, want %v", resp.StatusCode, http.StatusSeeOther)
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookie {
+			return fmt.Sprintf("Cookie: %s=%s", cookie.Name, cookie.Value)
+		}
+	}
+	t.Fatal("login got no session cookie")
+	return ""
+}
+
+func TestServer_CustomerCards(t *testing.T) {
+	s := newTestServer(t)
+	cookie := login(t, s, "ivan")
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{name: "Own card", target: "/balance.json?card=1", want: http.StatusOK},
+		{name: "Other customer card", target: "/balance.json?card=2", want: http.StatusNotFound},
+		{name: "Other customer operations", target: "/operations.json?card=2", want: http.StatusNotFound},
+		{name: "Unknown card", target: "/balance.json?card=3", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := doRequest(t, s, http.MethodGet, tt.target, "", cookie)
+			if resp.StatusCode != tt.want {
+				t.Errorf("GET %s status got = %v, want %v: %s", tt.target, resp.StatusCode, tt.want, body)
+			}
+		})
+	}
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name         string
+		target       string
+		headers      []string
+		want         int
+		wantLocation string
+	}{
+		{name: "Page without session", target: "/cards/1", want: http.StatusSeeOther, wantLocation: "/login"},
+		{name: "Data without session", target: "/balance.json?card=1", want: http.StatusUnauthorized},
+		{name: "Forged session", target: "/balance.json?card=1", headers: []string{"Cookie: session=forged"}, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := doRequest(t, s, http.MethodGet, tt.target, "", tt.headers...)
+			if resp.StatusCode != tt.want {
+				t.Errorf("GET %s status got = %v, want %v", tt.target, resp.StatusCode, tt.want)
+			}
+			if location := resp.Header.Get("Location"); location != tt.wantLocation {
+				t.Errorf("GET %s location got = %q, want %q", tt.target, location, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestServer_TokenScope(t *testing.T) {
+	s := newTestServer(t)
+	token, _, err := s.tokens.Issue("report", card.RoleAdmin, []auth.Scope{auth.ScopeReadOperations}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorization := "Authorization: Bearer " + token
+
+	resp, _ := doRequest(t, s, http.MethodGet, "/balance.json?card=2", "", authorization)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /balance.json status got = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	resp, _ = doRequest(t, s, http.MethodPost, "/transfer.json?from=1&to=2&amount=100", "", authorization)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("POST /transfer.json status got = %v, want %v", resp.StatusCode, http.StatusForbidden)
+	}
+	want := `Bearer error="insufficient_scope", scope="write:transfers"`
+	if got := resp.Header.Get("WWW-Authenticate"); got != want {
+		t.Errorf("POST /transfer.json WWW-Authenticate got = %q, want %q", got, want)
+	}
+}
+
+func TestServer_Logout(t *testing.T) {
+	s := newTestServer(t)
+	cookie := login(t, s, "ivan")
+
+	if resp, _ := doRequest(t, s, http.MethodGet, "/balance.json?card=1", "", cookie); resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET before logout status got = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	resp, _ := doRequest(t, s, http.MethodPost, "/logout", "", cookie)
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/login" {
+		t.Errorf("POST /logout got = %v %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	// Отозванная сессия не действует, даже если клиент сохранил cookie
+	if resp, _ = doRequest(t, s, http.MethodGet, "/balance.json?card=1", "", cookie); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET after logout status got = %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+// Чтение, которое после данных завершается ошибкой
+type failingReader struct {
+	data io.Reader
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func Test_watchConnection(t *testing.T) {
+	tests := []struct {
+		name       string
+		reader     io.Reader
+		wantCancel bool
+	}{
+		{name: "Client closed write side", reader: strings.NewReader("rest"), wantCancel: false},
+		{name: "Read error", reader: failingReader{data: strings.NewReader("rest")}, wantCancel: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			watchConnection(tt.reader, cancel)
+			if canceled := ctx.Err() != nil; canceled != tt.wantCancel {
+				t.Errorf("watchConnection() canceled = %v, want %v", canceled, tt.wantCancel)
+			}
+		})
+	}
+}
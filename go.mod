@@ -1,3 +1,3 @@
 module "github.com/ArtDark/bgo_network"
 
-go 1.15
\ No newline at end of file
+go 1.16
\ No newline at end of file
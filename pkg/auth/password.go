@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// Параметры хеширования паролей
+const (
+	DefaultIterations = 100_000
+	saltSize          = 16
+	keySize           = sha256.Size
+	hashScheme        = "pbkdf2-sha256"
+)
+
+// Функция хеширования пароля со случайной солью.
+// Результат "pbkdf2-sha256$<итераций>$<соль>$<хеш>" содержит все, что нужно для проверки
+func HashPassword(password string) (string, error) {
+	return hashPassword(password, DefaultIterations)
+}
+
+func hashPassword(password string, iterations int) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(password), salt, iterations, keySize)
+
+	return strings.Join([]string{
+		hashScheme,
+		strconv.Itoa(iterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// Функция проверки пароля по хешу из HashPassword. Хеши сравниваются за постоянное время
+func CheckPassword(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return false, ErrInvalidHash
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false, ErrInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false, ErrInvalidHash
+	}
+
+	got := pbkdf2([]byte(password), salt, iterations, len(want))
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+// Функция получения ключа из пароля по PBKDF2 (RFC 8018) с HMAC-SHA256
+func pbkdf2(password, salt []byte, iterations, size int) []byte {
+	prf := hmac.New(sha256.New, password)
+	blocks := (size + prf.Size() - 1) / prf.Size()
+
+	key := make([]byte, 0, blocks*prf.Size())
+	u := make([]byte, 0, prf.Size())
+	var counter [4]byte
+	for block := 1; block <= blocks; block++ {
+		// U1 = PRF(P, S || INT(i)), T = U1 ^ U2 ^ ... ^ Uc, Uj = PRF(P, Uj-1)
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		key = prf.Sum(key)
+		t := key[len(key)-prf.Size():]
+
+		u = append(u[:0], t...)
+		for n := 2; n <= iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return key[:size]
+}
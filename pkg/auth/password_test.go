@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func Test_pbkdf2(t *testing.T) {
+	type args struct {
+		password   string
+		salt       string
+		iterations int
+		size       int
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "One iteration",
+			args: args{password: "password", salt: "salt", iterations: 1, size: 32},
+			want: "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b",
+		},
+		{
+			name: "Two iterations",
+			args: args{password: "password", salt: "salt", iterations: 2, size: 32},
+			want: "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43",
+		},
+		{
+			name: "4096 iterations",
+			args: args{password: "password", salt: "salt", iterations: 4096, size: 32},
+			want: "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a",
+		},
+		{
+			name: "Two blocks",
+			args: args{password: "passwordPASSWORDpassword", salt: "saltSALTsaltSALTsaltSALTsaltSALTsalt", iterations: 4096, size: 40},
+			want: "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hex.EncodeToString(pbkdf2([]byte(tt.args.password), []byte(tt.args.salt), tt.args.iterations, tt.args.size))
+			if got != tt.want {
+				t.Errorf("pbkdf2() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("secret", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := hashPassword("secret", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == other {
+		t.Error("hashPassword() returned equal hashes for different salts")
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+		wantErr  error
+	}{
+		{name: "Correct password", hash: hash, password: "secret", want: true},
+		{name: "Wrong password", hash: hash, password: "Secret"},
+		{name: "Unknown scheme", hash: strings.Replace(hash, "sha256", "md5", 1), password: "secret", wantErr: ErrInvalidHash},
+		{name: "Invalid iterations", hash: strings.Replace(hash, "$1000$", "$0$", 1), password: "secret", wantErr: ErrInvalidHash},
+		{name: "Truncated", hash: hash[:strings.LastIndex(hash, "$")], password: "secret", wantErr: ErrInvalidHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckPassword(tt.hash, tt.password)
+			if err != tt.wantErr {
+				t.Errorf("CheckPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CheckPassword() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidSession = errors.New("invalid session")
+	ErrSessionExpired = errors.New("session expired")
+)
+
+// Длина ключа подписи сессий
+const SessionKeySize = 32
+
+// Время действия сессии по умолчанию
+const DefaultSessionTTL = 12 * time.Hour
+
+// Источник текущего времени, например, card.SystemClock
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Сессия вошедшего пользователя
+type Session struct {
+	Id      string
+	Login   string
+	Expires time.Time
+}
+
+// Выдача и проверка сессий. Сессия хранится у клиента в подписанном HMAC-SHA256 токене,
+// на сервере хранятся только идентификаторы отозванных сессий до истечения их срока
+type Sessions struct {
+	Clock Clock
+
+	key []byte
+	ttl time.Duration
+
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// Конструктор сессий с ключом подписи key и временем действия ttl
+func NewSessions(key []byte, ttl time.Duration) *Sessions {
+	return &Sessions{
+		Clock:   systemClock{},
+		key:     append([]byte(nil), key...),
+		ttl:     ttl,
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Функция создания случайного ключа подписи
+func NewSessionKey() ([]byte, error) {
+	key := make([]byte, SessionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Метод выдачи сессии пользователю login, возвращает токен для cookie
+func (s *Sessions) Issue(login string) (string, Session, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", Session{}, err
+	}
+	session := Session{
+		Id:      hex.EncodeToString(id),
+		Login:   login,
+		Expires: s.Clock.Now().Add(s.ttl).Truncate(time.Second),
+	}
+
+	payload := strings.Join([]string{session.Id, session.Login, strconv.FormatInt(session.Expires.Unix(), 10)}, "|")
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + s.sign(encoded), session, nil
+}
+
+// Метод проверки токена: подписи, срока действия и отзыва
+func (s *Sessions) Verify(token string) (Session, error) {
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return Session{}, ErrInvalidSession
+	}
+	encoded, signature := token[:dot], token[dot+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return Session{}, ErrInvalidSession
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Session{}, ErrInvalidSession
+	}
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 3 {
+		return Session{}, ErrInvalidSession
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Session{}, ErrInvalidSession
+	}
+	session := Session{Id: parts[0], Login: parts[1], Expires: time.Unix(expires, 0)}
+
+	now := s.Clock.Now()
+	if !now.Before(session.Expires) {
+		return Session{}, ErrSessionExpired
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.revoked[session.Id]; ok {
+		return Session{}, ErrInvalidSession
+	}
+	return session, nil
+}
+
+// Метод отзыва сессии при выходе. Отозванные сессии с истекшим сроком забываются
+func (s *Sessions) Revoke(session Session) {
+	now := s.Clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, expires := range s.revoked {
+		if !now.Before(expires) {
+			delete(s.revoked, id)
+		}
+	}
+	if now.Before(session.Expires) {
+		s.revoked[session.Id] = session.Expires
+	}
+}
+
+func (s *Sessions) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
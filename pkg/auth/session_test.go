@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSessions() (*Sessions, *card.ManualClock) {
+	clock := card.NewManualClock(time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC))
+	sessions := NewSessions([]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+	sessions.Clock = clock
+	return sessions, clock
+}
+
+func TestSessions_Verify(t *testing.T) {
+	sessions, _ := newTestSessions()
+	token, session, err := sessions.Issue("ivan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := NewSessions([]byte("another key"), time.Hour)
+	forged, _, err := other.Issue("petr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := token[:strings.IndexByte(token, '.')]
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "Valid", token: token},
+		{name: "Signed with another key", token: forged, wantErr: ErrInvalidSession},
+		{name: "Signature of another payload", token: forged[:strings.IndexByte(forged, '.')] + token[len(payload):], wantErr: ErrInvalidSession},
+		{name: "Without signature", token: payload, wantErr: ErrInvalidSession},
+		{name: "Empty", token: "", wantErr: ErrInvalidSession},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sessions.Verify(tt.token)
+			if err != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Login != "ivan" || got.Id != session.Id || !got.Expires.Equal(session.Expires)) {
+				t.Errorf("Verify() got = %v, want %v", got, session)
+			}
+		})
+	}
+}
+
+func TestSessions_Expiry(t *testing.T) {
+	sessions, clock := newTestSessions()
+	token, _, err := sessions.Issue("ivan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(59 * time.Minute)
+	if _, err = sessions.Verify(token); err != nil {
+		t.Errorf("Verify() before expiry error = %v", err)
+	}
+	clock.Advance(time.Minute)
+	if _, err = sessions.Verify(token); err != ErrSessionExpired {
+		t.Errorf("Verify() after expiry error = %v, want %v", err, ErrSessionExpired)
+	}
+}
+
+func TestSessions_Revoke(t *testing.T) {
+	sessions, clock := newTestSessions()
+	token, session, err := sessions.Issue("ivan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	another, _, err := sessions.Issue("ivan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions.Revoke(session)
+	if _, err = sessions.Verify(token); err != ErrInvalidSession {
+		t.Errorf("Verify() of revoked session error = %v, want %v", err, ErrInvalidSession)
+	}
+	if _, err = sessions.Verify(another); err != nil {
+		t.Errorf("Verify() of another session error = %v", err)
+	}
+
+	// Отозванная сессия забывается после истечения срока
+	clock.Advance(2 * time.Hour)
+	sessions.Revoke(Session{Id: "other", Expires: clock.Now().Add(time.Hour)})
+	if len(sessions.revoked) != 1 {
+		t.Errorf("revoked got = %d, want 1", len(sessions.revoked))
+	}
+}
@@ -0,0 +1,127 @@
+// Package auth содержит пользователей сервисов банка: хранилище с хешами паролей,
+// подписанные сессии для входа через веб-интерфейс и токены API с правами для программного доступа.
+package auth
+
+import (
+	"errors"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"sync"
+)
+
+var (
+	ErrInvalidLogin       = errors.New("invalid login")
+	ErrUserExists         = errors.New("user already exists")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	ErrInvalidCustomer    = errors.New("invalid customer id")
+)
+
+// Пользователь. Клиенту принадлежат карты с тем же идентификатором клиента, сотрудникам банка
+// доступны карты всех клиентов в пределах роли
+type User struct {
+	Login string
+	Role  card.Role
+	Owner card.Owner // Только для клиентов
+	hash  string
+}
+
+// Метод получения того, от чьего имени пользователь выполняет операции сервиса карт
+func (u User) Principal() card.Principal {
+	return card.Principal{Name: u.Login, Role: u.Role, Customer: u.Owner.CustomerId}
+}
+
+// Хранилище пользователей в памяти. Методы безопасны для вызова из нескольких горутин
+type Store struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	dummy string // Хеш для проверки пароля неизвестного пользователя за то же время
+}
+
+// Конструктор пустого хранилища
+func NewStore() *Store {
+	dummy, _ := HashPassword("")
+	return &Store{users: make(map[string]*User), dummy: dummy}
+}
+
+// Метод добавления клиента - владельца карт owner, у которого должен быть указан идентификатор клиента.
+// Логин - латинские буквы в нижнем регистре, цифры, точка, дефис и подчеркивание
+func (s *Store) Add(login, password string, owner card.Owner) error {
+	if owner.CustomerId <= 0 {
+		return ErrInvalidCustomer
+	}
+	return s.add(User{Login: login, Role: card.RoleCustomer, Owner: owner}, password)
+}
+
+// Метод добавления сотрудника банка с ролью role, кроме клиента
+func (s *Store) AddStaff(login, password string, role card.Role) error {
+	if _, err := card.ParseRole(string(role)); err != nil || role == card.RoleCustomer {
+		return card.ErrInvalidRole
+	}
+	return s.add(User{Login: login, Role: role}, password)
+}
+
+func (s *Store) add(user User, password string) error {
+	if !validLogin(user.Login) {
+		return ErrInvalidLogin
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.hash = hash
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.Login]; ok {
+		return ErrUserExists
+	}
+	s.users[user.Login] = &user
+	return nil
+}
+
+// Метод проверки логина и пароля. Для неизвестного логина и неверного пароля возвращается
+// одна ошибка, а проверка занимает одинаковое время, чтобы не раскрывать существующие логины
+func (s *Store) Authenticate(login, password string) (User, error) {
+	s.mu.RLock()
+	user, ok := s.users[login]
+	hash := s.dummy
+	if ok {
+		hash = user.hash
+	}
+	s.mu.RUnlock()
+
+	match, err := CheckPassword(hash, password)
+	if err != nil {
+		return User{}, err
+	}
+	if !ok || !match {
+		return User{}, ErrInvalidCredentials
+	}
+	return *user, nil
+}
+
+// Метод получения пользователя по логину
+func (s *Store) User(login string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[login]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return *user, nil
+}
+
+func validLogin(login string) bool {
+	if login == "" || len(login) > 64 {
+		return false
+	}
+	for _, r := range login {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
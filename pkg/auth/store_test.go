@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"testing"
+)
+
+func TestStore_Add(t *testing.T) {
+	store := NewStore()
+	if err := store.Add("ivan", "secret", card.Owner{CustomerId: 1, FirstName: "Ivan", LastName: "Ivanov"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		login    string
+		customer card.CustomerId
+		wantErr  error
+	}{
+		{name: "New user", login: "petr.petrov", customer: 2},
+		{name: "Same customer", login: "ivan.ivanov", customer: 1},
+		{name: "Without customer", login: "anna", wantErr: ErrInvalidCustomer},
+		{name: "Existing user", login: "ivan", customer: 1, wantErr: ErrUserExists},
+		{name: "Empty login", login: "", customer: 3, wantErr: ErrInvalidLogin},
+		{name: "Upper case login", login: "Ivan", customer: 3, wantErr: ErrInvalidLogin},
+		{name: "Separator in login", login: "ivan|admin", customer: 3, wantErr: ErrInvalidLogin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.Add(tt.login, "secret", card.Owner{CustomerId: tt.customer}); err != tt.wantErr {
+				t.Errorf("Add() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStore_Authenticate(t *testing.T) {
+	owner := card.Owner{CustomerId: 1, FirstName: "Ivan", LastName: "Ivanov"}
+	store := NewStore()
+	if err := store.Add("ivan", "secret", owner); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		login    string
+		password string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    User
+		wantErr error
+	}{
+		{
+			name: "Correct password",
+			args: args{login: "ivan", password: "secret"},
+			want: User{Login: "ivan", Owner: owner},
+		},
+		{
+			name:    "Wrong password",
+			args:    args{login: "ivan", password: "Secret"},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "Unknown user",
+			args:    args{login: "petr", password: "secret"},
+			wantErr: ErrInvalidCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Authenticate(tt.args.login, tt.args.password)
+			if err != tt.wantErr {
+				t.Errorf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Login != tt.want.Login || got.Owner != tt.want.Owner {
+				t.Errorf("Authenticate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := store.User("petr"); err != ErrUserNotFound {
+		t.Errorf("User() error = %v, want %v", err, ErrUserNotFound)
+	}
+}
+
+func TestStore_AddStaff(t *testing.T) {
+	store := NewStore()
+	tests := []struct {
+		name    string
+		login   string
+		role    card.Role
+		wantErr error
+	}{
+		{name: "Auditor", login: "audit", role: card.RoleAuditor},
+		{name: "Admin", login: "admin", role: card.RoleAdmin},
+		{name: "Existing user", login: "audit", role: card.RoleOperator, wantErr: ErrUserExists},
+		{name: "Customer", login: "ivan", role: card.RoleCustomer, wantErr: card.ErrInvalidRole},
+		{name: "Unknown role", login: "root", role: "root", wantErr: card.ErrInvalidRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.AddStaff(tt.login, "secret", tt.role); err != tt.wantErr {
+				t.Fatalf("AddStaff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	user, err := store.Authenticate("audit", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := user.Principal(), (card.Principal{Name: "audit", Role: card.RoleAuditor}); got != want {
+		t.Errorf("Principal() got = %v, want %v", got, want)
+	}
+	if err = store.Add("ivan", "secret", card.Owner{CustomerId: 1, FirstName: "Ivan", LastName: "Ivanov"}); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ = store.User("ivan"); user.Role != card.RoleCustomer {
+		t.Errorf("Add() role = %v, want %v", user.Role, card.RoleCustomer)
+	}
+}
@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidToken      = errors.New("invalid token")
+	ErrTokenExpired      = errors.New("token expired")
+	ErrTokenRevoked      = errors.New("token revoked")
+	ErrTokenNotFound     = errors.New("token not found")
+	ErrInvalidScope      = errors.New("invalid scope")
+	ErrInvalidTTL        = errors.New("invalid token ttl")
+	ErrInsufficientScope = errors.New("insufficient scope")
+	ErrNoTokenFile       = errors.New("tokens are not stored in a file")
+)
+
+// Право, которое дает токен
+type Scope string
+
+const (
+	ScopeReadOperations Scope = "read:operations" // Чтение баланса, операций, выписок и аналитики любых карт
+	ScopeWriteTransfers Scope = "write:transfers" // Переводы между любыми картами
+	ScopeAdminCards     Scope = "admin:cards"     // Выпуск карт
+)
+
+var knownScopes = map[Scope]bool{
+	ScopeReadOperations: true,
+	ScopeWriteTransfers: true,
+	ScopeAdminCards:     true,
+}
+
+// Время действия токена по умолчанию
+const DefaultTokenTTL = 90 * 24 * time.Hour
+
+// Префикс токенов, по которому их легко найти в логах и коде
+const tokenPrefix = "bgo"
+
+// Функция разбора прав, перечисленных через запятую
+func ParseScopes(value string) ([]Scope, error) {
+	var result []Scope
+	for _, part := range strings.Split(value, ",") {
+		scope := Scope(strings.TrimSpace(part))
+		if !knownScopes[scope] {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, part)
+		}
+		result = append(result, scope)
+	}
+	return result, nil
+}
+
+// Токен API для программного доступа. Сам токен выдается один раз, хранится только его хеш.
+// Права ограничивают маршруты и команды серверов, роль - операции сервиса карт
+type Token struct {
+	Id      string    `json:"id"`
+	Name    string    `json:"name"` // Назначение токена, например, имя скрипта
+	Role    card.Role `json:"role"`
+	Scopes  []Scope   `json:"scopes"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	Revoked bool      `json:"revoked"`
+}
+
+// Метод проверки права токена
+func (t Token) Allows(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Метод получения того, от чьего имени токен выполняет операции сервиса карт
+func (t Token) Principal() card.Principal {
+	return card.Principal{Name: "token:" + t.Name, Role: t.Role}
+}
+
+// Токен в хранилище: описание и SHA-256 секрета в hex. Секрет случайный и длинный,
+// поэтому медленный хеш, как у паролей, не нужен
+type storedToken struct {
+	Token
+	Hash string `json:"hash"`
+}
+
+// Хранилище токенов API. Может храниться в файле JSON: выпуск и отзыв записывают файл,
+// серверы перечитывают его методом Reload. Методы безопасны для вызова из нескольких горутин
+type Tokens struct {
+	Clock Clock
+
+	path string
+
+	mu     sync.RWMutex
+	tokens map[string]*storedToken
+}
+
+// Конструктор пустого хранилища в памяти
+func NewTokens() *Tokens {
+	return &Tokens{Clock: systemClock{}, tokens: make(map[string]*storedToken)}
+}
+
+// Функция загрузки хранилища из файла path. Отсутствующий файл - пустое хранилище,
+// он будет создан при первом выпуске токена
+func LoadTokens(path string) (*Tokens, error) {
+	t := NewTokens()
+	t.path = path
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Метод выпуска токена name с ролью сотрудника role и правами scopes на время ttl.
+// Возвращает токен вида bgo_<id>_<секрет>. Токен не может быть выпущен для клиента: у него нет карт
+func (t *Tokens) Issue(name string, role card.Role, scopes []Scope, ttl time.Duration) (string, Token, error) {
+	if ttl <= 0 {
+		return "", Token{}, ErrInvalidTTL
+	}
+	if _, err := card.ParseRole(string(role)); err != nil || role == card.RoleCustomer {
+		return "", Token{}, card.ErrInvalidRole
+	}
+	if len(scopes) == 0 {
+		return "", Token{}, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return "", Token{}, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", Token{}, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", Token{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := t.Clock.Now().UTC().Truncate(time.Second)
+	stored := &storedToken{
+		Token: Token{
+			Id:      hex.EncodeToString(id),
+			Name:    name,
+			Role:    role,
+			Scopes:  append([]Scope(nil), scopes...),
+			Created: now,
+			Expires: now.Add(ttl),
+		},
+		Hash: hashSecret(encoded),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens[stored.Id] = stored
+	if err := t.save(); err != nil {
+		delete(t.tokens, stored.Id)
+		return "", Token{}, err
+	}
+	return strings.Join([]string{tokenPrefix, stored.Id, encoded}, "_"), stored.Token, nil
+}
+
+// Метод проверки токена: секрета, срока действия и отзыва
+func (t *Tokens) Verify(token string) (Token, error) {
+	parts := strings.SplitN(token, "_", 3)
+	if len(parts) != 3 || parts[0] != tokenPrefix {
+		return Token{}, ErrInvalidToken
+	}
+
+	// Revoke меняет токен в хранилище, поэтому проверяется копия, снятая под блокировкой
+	t.mu.RLock()
+	found, ok := t.tokens[parts[1]]
+	var stored storedToken
+	if ok {
+		stored = *found
+	}
+	t.mu.RUnlock()
+	if !ok {
+		return Token{}, ErrInvalidToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(parts[2])), []byte(stored.Hash)) != 1 {
+		return Token{}, ErrInvalidToken
+	}
+	if stored.Revoked {
+		return Token{}, ErrTokenRevoked
+	}
+	if !t.Clock.Now().Before(stored.Expires) {
+		return Token{}, ErrTokenExpired
+	}
+	return stored.Token, nil
+}
+
+// Метод отзыва токена по идентификатору. Отозванный токен остается в списке
+func (t *Tokens) Revoke(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stored, ok := t.tokens[id]
+	if !ok {
+		return ErrTokenNotFound
+	}
+	if stored.Revoked {
+		return nil
+	}
+	stored.Revoked = true
+	if err := t.save(); err != nil {
+		stored.Revoked = false
+		return err
+	}
+	return nil
+}
+
+// Метод получения всех токенов в порядке выпуска
+func (t *Tokens) List() []Token {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]Token, 0, len(t.tokens))
+	for _, stored := range t.tokens {
+		result = append(result, stored.Token)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Created.Equal(result[j].Created) {
+			return result[i].Created.Before(result[j].Created)
+		}
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+// Метод повторного чтения файла хранилища. При ошибке остаются прежние токены
+func (t *Tokens) Reload() error {
+	if t.path == "" {
+		return ErrNoTokenFile
+	}
+	data, err := ioutil.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		data, err = []byte("[]"), nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []*storedToken
+	if err = json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("%s: %w", t.path, err)
+	}
+
+	tokens := make(map[string]*storedToken, len(list))
+	for _, stored := range list {
+		if stored.Id == "" || stored.Hash == "" {
+			return fmt.Errorf("%s: %w", t.path, ErrInvalidToken)
+		}
+		tokens[stored.Id] = stored
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens = tokens
+	return nil
+}
+
+// Метод перечитывания файла хранилища при получении сигналов signals до отмены ctx,
+// чтобы выпущенные и отозванные токены действовали без перезапуска сервера
+func (t *Tokens) ReloadOnSignal(ctx context.Context, signals ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-ch:
+			if err := t.Reload(); err != nil {
+				log.Printf("%v: reload tokens: %v", sig, err)
+				continue
+			}
+			log.Printf("%v: tokens reloaded", sig)
+		}
+	}
+}
+
+// Метод записи хранилища в файл через временный файл, чтобы сервер не прочитал его наполовину.
+// Хранилище в памяти не записывается. Вызывается под блокировкой
+func (t *Tokens) save() error {
+	if t.path == "" {
+		return nil
+	}
+	list := make([]*storedToken, 0, len(t.tokens))
+	for _, stored := range t.tokens {
+		list = append(list, stored)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+	data, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(t.path), filepath.Base(t.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), t.path)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"errors"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestTokens() (*Tokens, *card.ManualClock) {
+	clock := card.NewManualClock(time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC))
+	tokens := NewTokens()
+	tokens.Clock = clock
+	return tokens, clock
+}
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []Scope
+		wantErr bool
+	}{
+		{name: "One", value: "read:operations", want: []Scope{ScopeReadOperations}},
+		{name: "Several", value: "read:operations, write:transfers,admin:cards", want: []Scope{ScopeReadOperations, ScopeWriteTransfers, ScopeAdminCards}},
+		{name: "Unknown", value: "read:operations,delete:cards", wantErr: true},
+		{name: "Empty", value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScopes(tt.value)
+			if (err != nil) != tt.wantErr || err != nil && !errors.Is(err, ErrInvalidScope) {
+				t.Fatalf("ParseScopes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseScopes() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokens_Verify(t *testing.T) {
+	tokens, clock := newTestTokens()
+	token, issued, err := tokens.Issue("report", card.RoleAuditor, []Scope{ScopeReadOperations}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedToken, err := tokens.Issue("old", card.RoleAdmin, []Scope{ScopeWriteTransfers}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tokens.Revoke(revokedToken.Id); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, "bgo_"+issued.Id+"_") {
+		t.Errorf("Issue() token = %q, want prefix bgo_%s_", token, issued.Id)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "Valid", token: token},
+		{name: "Revoked", token: revoked, wantErr: ErrTokenRevoked},
+		{name: "Wrong secret", token: "bgo_" + issued.Id + "_" + strings.Repeat("A", 43), wantErr: ErrInvalidToken},
+		{name: "Unknown id", token: "bgo_0000000000000000_" + token[len("bgo_")+len(issued.Id)+1:], wantErr: ErrInvalidToken},
+		{name: "Without prefix", token: token[len("bgo_"):], wantErr: ErrInvalidToken},
+		{name: "Empty", token: "", wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokens.Verify(tt.token)
+			if err != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, issued) {
+				t.Errorf("Verify() got = %v, want %v", got, issued)
+			}
+		})
+	}
+
+	clock.Advance(time.Hour)
+	if _, err = tokens.Verify(token); err != ErrTokenExpired {
+		t.Errorf("Verify() after expiry error = %v, want %v", err, ErrTokenExpired)
+	}
+}
+
+func TestTokens_VerifyConcurrentRevoke(t *testing.T) {
+	tokens, _ := newTestTokens()
+	var secrets []string
+	var ids []string
+	for i := 0; i < 10; i++ {
+		token, issued, err := tokens.Issue("report", card.RoleAuditor, []Scope{ScopeReadOperations}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets = append(secrets, token)
+		ids = append(ids, issued.Id)
+	}
+
+	// Проверки идут одновременно с отзывом: гонку находит go test -race
+	var wg sync.WaitGroup
+	started := make(chan struct{}, len(secrets))
+	for _, token := range secrets {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			started <- struct{}{}
+			for i := 0; i < 1000; i++ {
+				if _, err := tokens.Verify(token); err != nil && err != ErrTokenRevoked {
+					t.Errorf("Verify() error = %v", err)
+					return
+				}
+			}
+		}(token)
+	}
+	for range secrets {
+		<-started
+	}
+	for _, id := range ids {
+		if err := tokens.Revoke(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	for _, token := range secrets {
+		if _, err := tokens.Verify(token); err != ErrTokenRevoked {
+			t.Errorf("Verify() after Revoke error = %v, want %v", err, ErrTokenRevoked)
+		}
+	}
+}
+
+func TestTokens_Issue(t *testing.T) {
+	tokens, _ := newTestTokens()
+	tests := []struct {
+		name    string
+		role    card.Role
+		scopes  []Scope
+		ttl     time.Duration
+		wantErr error
+	}{
+		{name: "Valid", role: card.RoleOperator, scopes: []Scope{ScopeAdminCards}, ttl: time.Hour},
+		{name: "Without scopes", role: card.RoleOperator, ttl: time.Hour, wantErr: ErrInvalidScope},
+		{name: "Unknown scope", role: card.RoleOperator, scopes: []Scope{"root"}, ttl: time.Hour, wantErr: ErrInvalidScope},
+		{name: "Zero ttl", role: card.RoleOperator, scopes: []Scope{ScopeAdminCards}, wantErr: ErrInvalidTTL},
+		{name: "Customer role", role: card.RoleCustomer, scopes: []Scope{ScopeAdminCards}, ttl: time.Hour, wantErr: card.ErrInvalidRole},
+		{name: "Without role", scopes: []Scope{ScopeAdminCards}, ttl: time.Hour, wantErr: card.ErrInvalidRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := tokens.Issue(tt.name, tt.role, tt.scopes, tt.ttl)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Issue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (!got.Allows(ScopeAdminCards) || got.Allows(ScopeReadOperations)) {
+				t.Errorf("Issue() scopes = %v, want %v", got.Scopes, tt.scopes)
+			}
+			if err == nil && got.Principal() != (card.Principal{Name: "token:Valid", Role: card.RoleOperator}) {
+				t.Errorf("Principal() got = %v", got.Principal())
+			}
+		})
+	}
+	if err := tokens.Revoke("unknown"); err != ErrTokenNotFound {
+		t.Errorf("Revoke() error = %v, want %v", err, ErrTokenNotFound)
+	}
+}
+
+func TestLoadTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	tokens, err := LoadTokens(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, issued, err := tokens.Issue("report", card.RoleAuditor, []Scope{ScopeReadOperations}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// В файле хранится только хеш секрета
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token[strings.LastIndexByte(token, '_')+1:]) {
+		t.Errorf("file contains token secret: %s", data)
+	}
+
+	// Сервер видит отзыв, сделанный другим процессом, после Reload
+	server, err := LoadTokens(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = server.Verify(token); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if err = tokens.Revoke(issued.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err = server.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = server.Verify(token); err != ErrTokenRevoked {
+		t.Errorf("Verify() after Reload() error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if list := server.List(); len(list) != 1 || list[0].Id != issued.Id || !list[0].Revoked {
+		t.Errorf("List() got = %v", list)
+	}
+
+	// Испорченный файл не заменяет загруженные токены
+	if err = ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = server.Reload(); err == nil {
+		t.Error("Reload() of broken file error = nil")
+	}
+	if len(server.List()) != 1 {
+		t.Errorf("List() after failed Reload() got = %v", server.List())
+	}
+	if err = NewTokens().Reload(); err != ErrNoTokenFile {
+		t.Errorf("Reload() in memory error = %v, want %v", err, ErrNoTokenFile)
+	}
+}
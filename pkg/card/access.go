@@ -0,0 +1,132 @@
+package card
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrForbidden   = errors.New("forbidden")
+	ErrInvalidRole = errors.New("invalid role")
+)
+
+// Роль того, от чьего имени выполняется операция
+type Role string
+
+const (
+	RoleCustomer Role = "customer" // Клиент: чтение и переводы только по своим картам
+	RoleOperator Role = "operator" // Операционист: чтение любых карт, выпуск карт и возвраты
+	RoleAuditor  Role = "auditor"  // Аудитор: только чтение любых карт
+	RoleAdmin    Role = "admin"    // Администратор: любые операции
+)
+
+// Функция разбора роли
+func ParseRole(value string) (Role, error) {
+	switch role := Role(value); role {
+	case RoleCustomer, RoleOperator, RoleAuditor, RoleAdmin:
+		return role, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidRole, value)
+}
+
+// Действие с картой, право на которое проверяет сервис
+type Action string
+
+const (
+	ActionRead     Action = "read"     // Баланс, операции, выписки, лимиты, аналитика и срабатывания правил
+	ActionTransfer Action = "transfer" // Списание с карты: переводы, покупки, регулярные платежи и лимиты трат
+	ActionIssue    Action = "issue"    // Выпуск карты и загрузка операций
+	ActionRefund   Action = "refund"   // Возврат и отмена покупки
+)
+
+// Тот, от чьего имени выполняется операция: пользователь или токен API
+type Principal struct {
+	Name     string
+	Role     Role
+	Customer CustomerId // Клиент, чьи карты доступны роли RoleCustomer, для остальных ролей не используется
+}
+
+// Метод проверки права на действие с картой c. Для выпуска карты c равна nil
+func (p Principal) Can(action Action, c *Card) bool {
+	switch p.Role {
+	case RoleAdmin:
+		return true
+	case RoleAuditor:
+		return action == ActionRead
+	case RoleOperator:
+		return action == ActionRead || action == ActionIssue || action == ActionRefund
+	case RoleCustomer:
+		return (action == ActionRead || action == ActionTransfer) && c != nil && p.Customer != 0 && c.CustomerId == p.Customer
+	}
+	return false
+}
+
+// Ошибка отказа в доступе. errors.Is(err, ErrForbidden) выполняется для любого отказа
+type AccessError struct {
+	Principal Principal
+	Action    Action
+	CardId    CardId // 0 для выпуска карты
+}
+
+func (e *AccessError) Error() string {
+	name := e.Principal.Name
+	if name == "" {
+		name = "anonymous"
+	}
+	if e.CardId == 0 {
+		return fmt.Sprintf("%s: %s (%s) can not %s", ErrForbidden, name, e.Principal.Role, e.Action)
+	}
+	return fmt.Sprintf("%s: %s (%s) can not %s card %d", ErrForbidden, name, e.Principal.Role, e.Action, e.CardId)
+}
+
+func (e *AccessError) Unwrap() error {
+	return ErrForbidden
+}
+
+type principalKey struct{}
+
+// Функция добавления в контекст того, от чьего имени выполняются операции сервиса
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// Функция получения из контекста того, от чьего имени выполняются операции
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Метод проверки права из ctx на действие с картой c. Контекст без Principal - внутренний вызов,
+// он разрешен, если не задан RequirePrincipal. Вызывается под s.mu
+func (s *Service) authorize(ctx context.Context, action Action, c *Card) error {
+	var id CardId
+	if c != nil {
+		id = c.Id
+	}
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		if s.RequirePrincipal {
+			return &AccessError{Action: action, CardId: id}
+		}
+		return nil
+	}
+	if !p.Can(action, c) {
+		return &AccessError{Principal: p, Action: action, CardId: id}
+	}
+	return nil
+}
+
+// Метод получения карт, которые можно читать от имени Principal из ctx, в порядке выпуска
+func (s *Service) CardsContext(ctx context.Context) []CardId {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]CardId, 0)
+	for _, c := range s.cards {
+		if s.authorize(ctx, ActionRead, c) == nil {
+			ids = append(ids, c.Id)
+		}
+	}
+	return ids
+}
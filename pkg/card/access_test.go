@@ -0,0 +1,279 @@
+package card
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Role
+		wantErr bool
+	}{
+		{name: "Customer", value: "customer", want: RoleCustomer},
+		{name: "Admin", value: "admin", want: RoleAdmin},
+		{name: "Unknown", value: "root", wantErr: true},
+		{name: "Empty", value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRole(tt.value)
+			if (err != nil) != tt.wantErr || err != nil && !errors.Is(err, ErrInvalidRole) {
+				t.Fatalf("ParseRole() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRole() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrincipal_Can(t *testing.T) {
+	own := &Card{Id: 1, Owner: Owner{CustomerId: 1, FirstName: "Ivan", LastName: "Ivanov"}}
+	other := &Card{Id: 2, Owner: Owner{CustomerId: 2, FirstName: "Petr", LastName: "Petrov"}}
+	namesake := &Card{Id: 3, Owner: Owner{CustomerId: 3, FirstName: "Ivan", LastName: "Ivanov"}}
+	unlinked := &Card{Id: 4, Owner: Owner{FirstName: "Anna", LastName: "Ivanova"}}
+	customer := Principal{Name: "ivan", Role: RoleCustomer, Customer: 1}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		action    Action
+		card      *Card
+		want      bool
+	}{
+		{name: "Customer reads own card", principal: customer, action: ActionRead, card: own, want: true},
+		{name: "Customer transfers from own card", principal: customer, action: ActionTransfer, card: own, want: true},
+		{name: "Customer reads other card", principal: customer, action: ActionRead, card: other},
+		{name: "Customer transfers from other card", principal: customer, action: ActionTransfer, card: other},
+		{name: "Customer reads card of namesake", principal: customer, action: ActionRead, card: namesake},
+		{name: "Customer without id reads card without customer", principal: Principal{Role: RoleCustomer}, action: ActionRead, card: unlinked},
+		{name: "Customer refunds own purchase", principal: customer, action: ActionRefund, card: own},
+		{name: "Customer issues card", principal: customer, action: ActionIssue},
+		{name: "Operator reads any card", principal: Principal{Role: RoleOperator}, action: ActionRead, card: other, want: true},
+		{name: "Operator issues card", principal: Principal{Role: RoleOperator}, action: ActionIssue, want: true},
+		{name: "Operator refunds", principal: Principal{Role: RoleOperator}, action: ActionRefund, card: other, want: true},
+		{name: "Operator transfers", principal: Principal{Role: RoleOperator}, action: ActionTransfer, card: other},
+		{name: "Auditor reads any card", principal: Principal{Role: RoleAuditor}, action: ActionRead, card: other, want: true},
+		{name: "Auditor transfers", principal: Principal{Role: RoleAuditor}, action: ActionTransfer, card: other},
+		{name: "Auditor issues card", principal: Principal{Role: RoleAuditor}, action: ActionIssue},
+		{name: "Admin transfers", principal: Principal{Role: RoleAdmin}, action: ActionTransfer, card: other, want: true},
+		{name: "Unknown role", principal: Principal{Role: "root"}, action: ActionRead, card: own},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.Can(tt.action, tt.card); got != tt.want {
+				t.Errorf("Can() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newAccessTestService() *Service {
+	s := New("Test Bank")
+	s.IDs = NewSequenceGenerator("tx")
+	s.Clock = NewManualClock(time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC))
+	s.Fraud = NewFraudEngine()
+	ivan := s.issueCard(1, Owner{CustomerId: 1, FirstName: "Ivan", LastName: "Ivanov"}, "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+	// Однофамилец с другим идентификатором клиента
+	s.issueCard(2, Owner{CustomerId: 2, FirstName: "Ivan", LastName: "Ivanov"}, "Visa", 1000_00, "RUB", "5106 2100 0000 0002")
+	ivan.AddTransaction(Transaction{Id: "p1", Bill: 100_00, Time: 1599652800, MCC: "5411", Status: StatusDone, Type: TypePurchase})
+	s.alerts = []Alert{{Id: "a1", CardId: 1, Rule: RuleNewMCC}, {Id: "a2", CardId: 2, Rule: RuleNewMCC}}
+	s.recurring = []*Recurring{{Id: "r1", Kind: RecurringCharge, CardId: 2, Amount: 100, Schedule: IntervalSchedule{Interval: time.Hour}}}
+	return s
+}
+
+func TestService_Access(t *testing.T) {
+	customer := WithPrincipal(context.Background(), Principal{Name: "ivan", Role: RoleCustomer, Customer: 1})
+	auditor := WithPrincipal(context.Background(), Principal{Name: "audit", Role: RoleAuditor})
+	operator := WithPrincipal(context.Background(), Principal{Name: "oper", Role: RoleOperator})
+	admin := WithPrincipal(context.Background(), Principal{Name: "root", Role: RoleAdmin})
+	month := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	calls := map[string]func(s *Service, ctx context.Context) error{
+		"Balance": func(s *Service, ctx context.Context) error {
+			_, _, err := s.BalanceContext(ctx, 2)
+			return err
+		},
+		"Statement": func(s *Service, ctx context.Context) error {
+			_, err := s.StatementContext(ctx, 2, month)
+			return err
+		},
+		"Search": func(s *Service, ctx context.Context) error {
+			_, err := s.SearchTransactionsContext(ctx, 2, SearchQuery{})
+			return err
+		},
+		"Analytics": func(s *Service, ctx context.Context) error {
+			_, err := s.AnalyticsContext(ctx, 2, AnalyticsQuery{Period: PeriodMonth, GroupBy: GroupByMCC})
+			return err
+		},
+		"Budgets": func(s *Service, ctx context.Context) error {
+			_, err := s.BudgetReportContext(ctx, 2, month)
+			return err
+		},
+		"Alerts": func(s *Service, ctx context.Context) error {
+			_, err := s.AlertsContext(ctx, AlertQuery{CardId: 2})
+			return err
+		},
+		"Transfer": func(s *Service, ctx context.Context) error {
+			_, err := s.TransferContext(ctx, 2, 1, 100)
+			return err
+		},
+		"Refund": func(s *Service, ctx context.Context) error {
+			_, err := s.RefundContext(ctx, "p1", 100)
+			return err
+		},
+		"Issue": func(s *Service, ctx context.Context) error {
+			_, err := s.OpenCardContext(ctx, 3, Owner{CustomerId: 3, FirstName: "Anna", LastName: "Ivanova"}, "Visa", "RUB", "5106 2100 0000 0003")
+			return err
+		},
+		"Card": func(s *Service, ctx context.Context) error {
+			_, err := s.CardContext(ctx, 2)
+			return err
+		},
+		"CardIssue": func(s *Service, ctx context.Context) error {
+			_, err := s.CardIssueContext(ctx, 3, Owner{FirstName: "Anna", LastName: "Ivanova"}, "Visa", 100_00, "RUB", "5106 2100 0000 0003")
+			return err
+		},
+		"MakeTransactions": func(s *Service, ctx context.Context) error {
+			return s.MakeTransactionsContext(ctx, 2, 1)
+		},
+		"AddTransactions": func(s *Service, ctx context.Context) error {
+			return s.AddTransactionsContext(ctx, 2, Transaction{Id: "i1", Bill: 100, Status: StatusDone})
+		},
+		"Purchase": func(s *Service, ctx context.Context) error {
+			_, err := s.PurchaseContext(ctx, 2, 100, "5411")
+			return err
+		},
+		"SetBudget": func(s *Service, ctx context.Context) error {
+			return s.SetBudgetContext(ctx, 2, "5411", 100, BudgetDecline)
+		},
+		"RecurringCharge": func(s *Service, ctx context.Context) error {
+			_, err := s.AddRecurringChargeContext(ctx, 2, 100, "5411", IntervalSchedule{Interval: time.Hour})
+			return err
+		},
+		"ScheduledTransfer": func(s *Service, ctx context.Context) error {
+			_, err := s.AddScheduledTransferContext(ctx, 2, 1, 100, IntervalSchedule{Interval: time.Hour})
+			return err
+		},
+		"CancelRecurring": func(s *Service, ctx context.Context) error {
+			return s.CancelRecurringContext(ctx, "r1")
+		},
+	}
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		allowed []string
+	}{
+		{name: "Customer", ctx: customer},
+		{name: "Auditor", ctx: auditor, allowed: []string{"Balance", "Statement", "Search", "Analytics", "Budgets", "Alerts", "Card"}},
+		{name: "Operator", ctx: operator, allowed: []string{"Balance", "Statement", "Search", "Analytics", "Budgets", "Alerts", "Card", "Refund", "Issue", "CardIssue", "MakeTransactions", "AddTransactions"}},
+		{name: "Admin", ctx: admin, allowed: []string{"Balance", "Statement", "Search", "Analytics", "Budgets", "Alerts", "Card", "Refund", "Issue", "CardIssue", "MakeTransactions", "AddTransactions", "Transfer", "Purchase", "SetBudget", "RecurringCharge", "ScheduledTransfer", "CancelRecurring"}},
+	}
+	for _, tt := range tests {
+		for call, do := range calls {
+			allowed := false
+			for _, name := range tt.allowed {
+				allowed = allowed || name == call
+			}
+			err := do(newAccessTestService(), tt.ctx)
+			var accessErr *AccessError
+			if allowed && err != nil {
+				t.Errorf("%s %s error = %v, want allowed", tt.name, call, err)
+			}
+			if !allowed && (!errors.Is(err, ErrForbidden) || !errors.As(err, &accessErr)) {
+				t.Errorf("%s %s error = %v, want %v", tt.name, call, err, ErrForbidden)
+			}
+		}
+	}
+}
+
+func TestService_AccessFiltering(t *testing.T) {
+	s := newAccessTestService()
+	customer := WithPrincipal(context.Background(), Principal{Name: "ivan", Role: RoleCustomer, Customer: 1})
+
+	if got := s.CardsContext(customer); !reflect.DeepEqual(got, []CardId{1}) {
+		t.Errorf("CardsContext() customer got = %v", got)
+	}
+	if got := s.CardsContext(context.Background()); !reflect.DeepEqual(got, []CardId{1, 2}) {
+		t.Errorf("CardsContext() internal got = %v", got)
+	}
+	alerts, err := s.AlertsContext(customer, AlertQuery{})
+	if err != nil || len(alerts) != 1 || alerts[0].Id != "a1" {
+		t.Errorf("AlertsContext() customer got = %v, %v", alerts, err)
+	}
+	if _, err = s.TransferContext(customer, 1, 2, 100); err != nil {
+		t.Errorf("TransferContext() from own card error = %v", err)
+	}
+
+	// Без Principal в контексте вызовы запрещены, если он обязателен
+	s.RequirePrincipal = true
+	if _, _, err = s.Balance(1); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Balance() without principal error = %v, want %v", err, ErrForbidden)
+	}
+	if got := s.CardsContext(context.Background()); len(got) != 0 {
+		t.Errorf("CardsContext() without principal got = %v", got)
+	}
+	if _, _, err = s.BalanceContext(customer, 1); err != nil {
+		t.Errorf("BalanceContext() with principal error = %v", err)
+	}
+	if got := s.RecurringPaymentsContext(customer); len(got) != 0 {
+		t.Errorf("RecurringPaymentsContext() customer got = %v", got)
+	}
+}
+
+func TestService_CardContext(t *testing.T) {
+	s := newAccessTestService()
+	got, err := s.CardContext(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Изменение копии не меняет карту сервиса
+	got.Balance = 0
+	got.AddTransaction(Transaction{Id: "x1", Bill: 100, Status: StatusDone})
+	got.Transactions.Transactions[0].Bill = 1
+	again, err := s.CardById(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Balance != 1000_00 || len(again.Transactions.Transactions) != 1 || again.Transactions.Transactions[0].Bill != 100_00 {
+		t.Errorf("CardById() after change of copy got = %+v", again)
+	}
+}
+
+func TestService_Cards(t *testing.T) {
+	s := newAccessTestService()
+	cards := s.Cards()
+	if len(cards) != 2 || cards[0].Id != 1 || cards[1].Id != 2 {
+		t.Fatalf("Cards() got = %+v", cards)
+	}
+
+	// Изменение копии не меняет карту сервиса
+	cards[0].Balance = 0
+	if again := s.Cards(); again[0].Balance != 1000_00 {
+		t.Errorf("Cards() after change of copy got = %+v", again[0])
+	}
+
+	// Без Principal карты не выдаются, если он обязателен
+	s.RequirePrincipal = true
+	if got := s.Cards(); len(got) != 0 {
+		t.Errorf("Cards() without principal got = %+v", got)
+	}
+}
+
+func TestAccessError_Error(t *testing.T) {
+	err := &AccessError{Principal: Principal{Name: "ivan", Role: RoleCustomer}, Action: ActionRead, CardId: 2}
+	if got, want := err.Error(), "forbidden: ivan (customer) can not read card 2"; got != want {
+		t.Errorf("Error() got = %q, want %q", got, want)
+	}
+	err = &AccessError{Action: ActionIssue}
+	if got, want := err.Error(), "forbidden: anonymous () can not issue"; got != want {
+		t.Errorf("Error() got = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package card
+
+import (
+	"context"
+)
+
+// Функция получения ключа группировки транзакции
+type KeyFunc func(transaction Transaction) string
+
+// Функция получения суммируемого значения транзакции
+type ValueFunc func(transaction Transaction) int64
+
+// Ключ группировки по коду категории
+func ByMCC(transaction Transaction) string {
+	return transaction.MCC
+}
+
+// Значение суммы транзакции
+func ByBill(transaction Transaction) int64 {
+	return transaction.Bill
+}
+
+// Как часто горутины проверяют отмену контекста
+const cancelCheckInterval = 1024
+
+// Функция параллельного суммирования значений транзакций по ключу.
+// Слайс делится на goroutines частей, остаток распределяется по первым частям.
+// Число горутин ограничивается снизу единицей и сверху количеством транзакций
+func Aggregate(
+	ctx context.Context,
+	transactions []Transaction,
+	goroutines int,
+	key KeyFunc,
+	value ValueFunc,
+) (map[string]int64, error) {
+	result := make(map[string]int64)
+	if len(transactions) == 0 {
+		return result, ctx.Err()
+	}
+
+	if goroutines < 1 {
+		goroutines = 1
+	}
+	if goroutines > len(transactions) {
+		goroutines = len(transactions)
+	}
+
+	ch := make(chan map[string]int64, goroutines)
+	partSize, rest := len(transactions)/goroutines, len(transactions)%goroutines
+	start := 0
+	for i := 0; i < goroutines; i++ {
+		end := start + partSize
+		if i < rest {
+			end++
+		}
+		part := transactions[start:end]
+		start = end
+
+		go func() {
+			sum := make(map[string]int64)
+			for i, t := range part {
+				if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+					break
+				}
+				sum[key(t)] += value(t)
+			}
+			ch <- sum
+		}()
+	}
+
+	for i := 0; i < goroutines; i++ {
+		for k, v := range <-ch {
+			result[k] += v
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,131 @@
+package card
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"testing/quick"
+)
+
+// Набор случайных транзакций для property-based тестов
+type randomTransactions []Transaction
+
+func (randomTransactions) Generate(rand *rand.Rand, size int) reflect.Value {
+	mcc := []string{"5411", "5812", "5912", "4121"}
+	transactions := make(randomTransactions, rand.Intn(size*10+1))
+	for i := range transactions {
+		transactions[i] = Transaction{
+			Id:     strconv.Itoa(i),
+			Bill:   rand.Int63n(100_000_00),
+			Time:   1606192422 + int64(i),
+			MCC:    mcc[rand.Intn(len(mcc))],
+			Status: "Done",
+		}
+	}
+	return reflect.ValueOf(transactions)
+}
+
+// Последовательный подсчет, с которым сравниваются параллельные реализации
+func sequentialSum(transactions []Transaction) map[string]int64 {
+	m := make(map[string]int64)
+	for _, t := range transactions {
+		m[t.MCC] += t.Bill
+	}
+	return m
+}
+
+func TestSumCategoryTransactions_Property(t *testing.T) {
+	variants := map[string]func([]Transaction, int) (map[string]int64, error){
+		"SumCategoryTransactionsMutex":            SumCategoryTransactionsMutex,
+		"SumCategoryTransactionsChan":             SumCategoryTransactionsChan,
+		"SumCategoryTransactionsMutexWithoutFunc": SumCategoryTransactionsMutexWithoutFunc,
+	}
+	for name, sum := range variants {
+		sum := sum
+		t.Run(name, func(t *testing.T) {
+			property := func(transactions randomTransactions, goroutines int8) bool {
+				got, err := sum(transactions, int(goroutines))
+				if err != nil {
+					return false
+				}
+				return reflect.DeepEqual(got, sequentialSum(transactions))
+			}
+			if err := quick.Check(property, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	transactions := []Transaction{
+		{Id: "0001", Bill: 100_00, MCC: "5411", Status: "Done"},
+		{Id: "0002", Bill: 200_00, MCC: "5812", Status: "Done"},
+		{Id: "0003", Bill: 400_00, MCC: "5411", Status: "Done"},
+		{Id: "0004", Bill: 300_00, MCC: "5812", Status: "Declined"},
+		{Id: "0005", Bill: 500_00, MCC: "5411", Status: "Done"},
+	}
+	type args struct {
+		goroutines int
+		key        KeyFunc
+		value      ValueFunc
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]int64
+	}{
+		{
+			name: "Remainder is not dropped",
+			args: args{goroutines: 2, key: ByMCC, value: ByBill},
+			want: map[string]int64{"5411": 1000_00, "5812": 500_00},
+		},
+		{
+			name: "Zero goroutines",
+			args: args{goroutines: 0, key: ByMCC, value: ByBill},
+			want: map[string]int64{"5411": 1000_00, "5812": 500_00},
+		},
+		{
+			name: "More goroutines than transactions",
+			args: args{goroutines: 16, key: ByMCC, value: ByBill},
+			want: map[string]int64{"5411": 1000_00, "5812": 500_00},
+		},
+		{
+			name: "Count by status",
+			args: args{
+				goroutines: 3,
+				key:        func(t Transaction) string { return t.Status },
+				value:      func(t Transaction) int64 { return 1 },
+			},
+			want: map[string]int64{"Done": 4, "Declined": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Aggregate(context.Background(), transactions, tt.args.goroutines, tt.args.key, tt.args.value)
+			if err != nil {
+				t.Errorf("Aggregate() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Aggregate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregate_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	transactions := []Transaction{{Id: "0001", Bill: 100_00, MCC: "5411", Status: "Done"}}
+	got, err := Aggregate(ctx, transactions, 4, ByMCC, ByBill)
+	if err != context.Canceled {
+		t.Errorf("Aggregate() error = %v, wantErr %v", err, context.Canceled)
+	}
+	if got != nil {
+		t.Errorf("Aggregate() got = %v, want nil", got)
+	}
+}
@@ -0,0 +1,242 @@
+package card
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrInvalidPeriod  = errors.New("invalid period")
+	ErrInvalidGroupBy = errors.New("invalid group by")
+	ErrInvalidRange   = errors.New("invalid time range")
+)
+
+// Период группировки транзакций
+type Period string
+
+const (
+	PeriodDay    Period = "day"
+	PeriodWeek   Period = "week" // Неделя начинается с понедельника
+	PeriodMonth  Period = "month"
+	PeriodCustom Period = "custom" // Один период от From до To
+)
+
+// Признак группировки транзакций
+type GroupBy string
+
+const (
+	GroupByMCC      GroupBy = "mcc"
+	GroupByCategory GroupBy = "category" // Группа категорий из справочника MCC
+	GroupByStatus   GroupBy = "status"
+	GroupByIssuer   GroupBy = "issuer"
+)
+
+// Параметры запроса аналитики.
+// Нулевые From и To означают отсутствие ограничения
+type AnalyticsQuery struct {
+	Period  Period
+	GroupBy GroupBy
+	From    time.Time
+	To      time.Time
+}
+
+// Агрегированные показатели по группе транзакций за период.
+// Суммы в валюте карты, карты в разных валютах попадают в разные группы
+type AnalyticsBucket struct {
+	From     int64  `json:"from"` // Начало периода включительно
+	To       int64  `json:"to"`   // Конец периода не включительно
+	Key      string `json:"key"`
+	Currency string `json:"currency"`
+	Total    int64  `json:"total"`
+	Count    int64  `json:"count"`
+	Average  int64  `json:"average"`
+	Min      int64  `json:"min"`
+	Max      int64  `json:"max"`
+}
+
+// Метод расчета аналитики по транзакциям карты
+func (s *Service) Analytics(id CardId, query AnalyticsQuery) ([]AnalyticsBucket, error) {
+	return s.AnalyticsContext(context.Background(), id, query)
+}
+
+// Метод расчета аналитики по транзакциям карты с возможностью отмены
+func (s *Service) AnalyticsContext(ctx context.Context, id CardId, query AnalyticsQuery) ([]AnalyticsBucket, error) {
+	s.mu.Lock()
+	c, err := s.cardById(id)
+	if err == nil {
+		err = s.authorize(ctx, ActionRead, c)
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	// Аналитика считается в валюте карты
+	transactions, err := c.ConvertTransactions(s.Rates)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	converted := *c
+	converted.Transactions.Transactions = transactions
+
+	return AnalyzeContext(ctx, []*Card{&converted}, query)
+}
+
+// Метод расчета аналитики по транзакциям всех карт, которые разрешено читать
+func (s *Service) AnalyticsAll(query AnalyticsQuery) ([]AnalyticsBucket, error) {
+	return s.AnalyticsAllContext(context.Background(), query)
+}
+
+// Метод расчета аналитики по всем картам, доступным Principal из ctx.
+// Транзакции копируются под блокировкой сервиса, расчет идет уже без нее.
+// Суммы карт в разных валютах не складываются: группы разделяются по валюте карты
+func (s *Service) AnalyticsAllContext(ctx context.Context, query AnalyticsQuery) ([]AnalyticsBucket, error) {
+	s.mu.Lock()
+	cards := make([]*Card, 0, len(s.cards))
+	for _, c := range s.cards {
+		if s.authorize(ctx, ActionRead, c) != nil {
+			continue
+		}
+		// Аналитика считается в валюте каждой карты
+		transactions, err := c.ConvertTransactions(s.Rates)
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		converted := *c
+		converted.Transactions.Transactions = transactions
+		converted.Budgets = nil
+		converted.timeIndex = nil
+		cards = append(cards, &converted)
+	}
+	s.mu.Unlock()
+
+	return AnalyzeContext(ctx, cards, query)
+}
+
+// Функция расчета аналитики по транзакциям карт с группировкой по периоду и признаку
+func Analyze(cards []*Card, query AnalyticsQuery) ([]AnalyticsBucket, error) {
+	return AnalyzeContext(context.Background(), cards, query)
+}
+
+// Функция расчета аналитики с возможностью отмены
+func AnalyzeContext(ctx context.Context, cards []*Card, query AnalyticsQuery) ([]AnalyticsBucket, error) {
+	if err := query.validate(); err != nil {
+		return nil, err
+	}
+
+	type bucketKey struct {
+		from     int64
+		key      string
+		currency string
+	}
+	buckets := make(map[bucketKey]*AnalyticsBucket)
+
+	for _, c := range cards {
+		for i, t := range c.Transactions.Transactions {
+			if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			tm := time.Unix(t.Time, 0).UTC()
+			if !query.From.IsZero() && tm.Before(query.From) {
+				continue
+			}
+			if !query.To.IsZero() && !tm.Before(query.To) {
+				continue
+			}
+
+			from, to := query.periodBounds(tm)
+			k := bucketKey{from: from.Unix(), key: query.key(c, t), currency: c.Currency}
+			b, ok := buckets[k]
+			if !ok {
+				b = &AnalyticsBucket{From: k.from, To: to.Unix(), Key: k.key, Currency: k.currency, Min: t.Bill, Max: t.Bill}
+				buckets[k] = b
+			}
+			b.Total += t.Bill
+			b.Count++
+			if t.Bill < b.Min {
+				b.Min = t.Bill
+			}
+			if t.Bill > b.Max {
+				b.Max = t.Bill
+			}
+		}
+	}
+
+	result := make([]AnalyticsBucket, 0, len(buckets))
+	for _, b := range buckets {
+		b.Average = b.Total / b.Count
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].From != result[j].From {
+			return result[i].From < result[j].From
+		}
+		if result[i].Key != result[j].Key {
+			return result[i].Key < result[j].Key
+		}
+		return result[i].Currency < result[j].Currency
+	})
+
+	return result, nil
+}
+
+func (q AnalyticsQuery) validate() error {
+	switch q.Period {
+	case PeriodDay, PeriodWeek, PeriodMonth:
+	case PeriodCustom:
+		if q.From.IsZero() || q.To.IsZero() {
+			return ErrInvalidRange
+		}
+	default:
+		return ErrInvalidPeriod
+	}
+
+	switch q.GroupBy {
+	case GroupByMCC, GroupByCategory, GroupByStatus, GroupByIssuer:
+	default:
+		return ErrInvalidGroupBy
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return ErrInvalidRange
+	}
+
+	return nil
+}
+
+// Метод определения границ периода, в который попадает время транзакции
+func (q AnalyticsQuery) periodBounds(tm time.Time) (time.Time, time.Time) {
+	day := time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch q.Period {
+	case PeriodDay:
+		return day, day.AddDate(0, 0, 1)
+	case PeriodWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		start := day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		start := time.Date(tm.Year(), tm.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return q.From.UTC(), q.To.UTC()
+	}
+}
+
+// Метод получения значения признака группировки транзакции
+func (q AnalyticsQuery) key(c *Card, t Transaction) string {
+	switch q.GroupBy {
+	case GroupByCategory:
+		return DefaultMCCRegistry().Group(t.MCC)
+	case GroupByStatus:
+		return t.Status
+	case GroupByIssuer:
+		return c.Issuer
+	default:
+		return t.MCC
+	}
+}
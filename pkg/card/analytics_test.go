@@ -0,0 +1,123 @@
+package card
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newAnalyticsService() *Service {
+	s := New("Test Bank")
+	c := s.issueCard(1, Owner{FirstName: "User", LastName: "User"}, "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+	c.AddTransaction(Transaction{Id: "0001", Bill: 100_00, Time: time.Date(2020, 9, 7, 10, 0, 0, 0, time.UTC).Unix(), MCC: "5411", Status: "Done"})
+	c.AddTransaction(Transaction{Id: "0002", Bill: 300_00, Time: time.Date(2020, 9, 7, 20, 0, 0, 0, time.UTC).Unix(), MCC: "5411", Status: "Done"})
+	c.AddTransaction(Transaction{Id: "0003", Bill: 200_00, Time: time.Date(2020, 9, 13, 12, 0, 0, 0, time.UTC).Unix(), MCC: "5812", Status: "Done"})
+	c.AddTransaction(Transaction{Id: "0004", Bill: 500_00, Time: time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC).Unix(), MCC: "5812", Status: "Declined"})
+	return s
+}
+
+func TestService_Analytics(t *testing.T) {
+	sep := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	oct := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	week := time.Date(2020, 9, 7, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		query   AnalyticsQuery
+		want    []AnalyticsBucket
+		wantErr error
+	}{
+		{
+			name:  "Month by mcc",
+			query: AnalyticsQuery{Period: PeriodMonth, GroupBy: GroupByMCC},
+			want: []AnalyticsBucket{
+				{From: sep.Unix(), To: oct.Unix(), Key: "5411", Currency: "RUB", Total: 400_00, Count: 2, Average: 200_00, Min: 100_00, Max: 300_00},
+				{From: sep.Unix(), To: oct.Unix(), Key: "5812", Currency: "RUB", Total: 200_00, Count: 1, Average: 200_00, Min: 200_00, Max: 200_00},
+				{From: oct.Unix(), To: oct.AddDate(0, 1, 0).Unix(), Key: "5812", Currency: "RUB", Total: 500_00, Count: 1, Average: 500_00, Min: 500_00, Max: 500_00},
+			},
+		},
+		{
+			name:  "Week by status in range",
+			query: AnalyticsQuery{Period: PeriodWeek, GroupBy: GroupByStatus, From: sep, To: oct},
+			want: []AnalyticsBucket{
+				{From: week.Unix(), To: week.AddDate(0, 0, 7).Unix(), Key: "Done", Currency: "RUB", Total: 600_00, Count: 3, Average: 200_00, Min: 100_00, Max: 300_00},
+			},
+		},
+		{
+			name:  "Custom range by issuer",
+			query: AnalyticsQuery{Period: PeriodCustom, GroupBy: GroupByIssuer, From: sep, To: oct.AddDate(0, 0, 1)},
+			want: []AnalyticsBucket{
+				{From: sep.Unix(), To: oct.AddDate(0, 0, 1).Unix(), Key: "Visa", Currency: "RUB", Total: 1100_00, Count: 4, Average: 275_00, Min: 100_00, Max: 500_00},
+			},
+		},
+		{
+			name:  "Day by category",
+			query: AnalyticsQuery{Period: PeriodDay, GroupBy: GroupByCategory, To: week.AddDate(0, 0, 1)},
+			want: []AnalyticsBucket{
+				{From: week.Unix(), To: week.AddDate(0, 0, 1).Unix(), Key: "retail", Currency: "RUB", Total: 400_00, Count: 2, Average: 200_00, Min: 100_00, Max: 300_00},
+			},
+		},
+		{
+			name:    "Custom without range",
+			query:   AnalyticsQuery{Period: PeriodCustom, GroupBy: GroupByMCC},
+			wantErr: ErrInvalidRange,
+		},
+		{
+			name:    "Unknown period",
+			query:   AnalyticsQuery{Period: "year", GroupBy: GroupByMCC},
+			wantErr: ErrInvalidPeriod,
+		},
+		{
+			name:    "Unknown group by",
+			query:   AnalyticsQuery{Period: PeriodDay, GroupBy: "owner"},
+			wantErr: ErrInvalidGroupBy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newAnalyticsService().Analytics(1, tt.query)
+			if err != tt.wantErr {
+				t.Errorf("Analytics() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analytics() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_AnalyticsAll(t *testing.T) {
+	s := newAnalyticsService()
+	petr := s.issueCard(2, Owner{CustomerId: 2, FirstName: "Petr", LastName: "Petrov"}, "MasterCard", 1000_00, "RUB", "5106 2100 0000 0002")
+	petr.AddTransaction(Transaction{Id: "0005", Bill: 50_00, Time: time.Date(2020, 9, 7, 11, 0, 0, 0, time.UTC).Unix(), MCC: "5411", Status: "Done"})
+	// Карта в другой валюте того же эмитента: ее суммы не складываются с рублевыми
+	dollars := s.issueCard(3, Owner{CustomerId: 3, FirstName: "John", LastName: "Smith"}, "Visa", 1000_00, "USD", "5106 2100 0000 0003")
+	dollars.AddTransaction(Transaction{Id: "0006", Bill: 10_00, Time: time.Date(2020, 9, 8, 11, 0, 0, 0, time.UTC).Unix(), MCC: "5411", Status: "Done"})
+	query := AnalyticsQuery{Period: PeriodCustom, GroupBy: GroupByIssuer, From: time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{name: "All cards", ctx: context.Background(), want: []string{"MasterCard RUB 5000", "Visa RUB 60000", "Visa USD 1000"}},
+		{name: "Customer", ctx: WithPrincipal(context.Background(), Principal{Role: RoleCustomer, Customer: petr.CustomerId}), want: []string{"MasterCard RUB 5000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.AnalyticsAllContext(tt.ctx, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := make([]string, len(got))
+			for i, bucket := range got {
+				keys[i] = fmt.Sprintf("%s %s %d", bucket.Key, bucket.Currency, bucket.Total)
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("AnalyticsAllContext() keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
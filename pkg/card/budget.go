@@ -0,0 +1,230 @@
+package card
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrBudgetExceeded    = errors.New("budget exceeded")
+	ErrInvalidBudgetMode = errors.New("invalid budget mode")
+)
+
+// Поведение при превышении лимита
+type BudgetMode string
+
+const (
+	BudgetDecline BudgetMode = "decline" // Транзакция отклоняется
+	BudgetFlag    BudgetMode = "flag"    // Транзакция проводится и помечается
+)
+
+// Месячный лимит трат по коду MCC или группе категорий
+type Budget struct {
+	Key   string     `json:"key" xml:"key"` // Код MCC или группа категорий из справочника
+	Limit int64      `json:"limit" xml:"limit"`
+	Mode  BudgetMode `json:"mode" xml:"mode"`
+}
+
+// Состояние лимита за месяц
+type BudgetStatus struct {
+	Budget
+	Name      string `json:"name"` // Название категории для отображения
+	Spent     int64  `json:"spent"`
+	Remaining int64  `json:"remaining"` // Отрицательный остаток означает превышение
+}
+
+// Метод проверки, относится ли транзакция к лимиту
+func (b Budget) Matches(mcc string) bool {
+	return b.Key == mcc || b.Key == DefaultMCCRegistry().Group(mcc)
+}
+
+// Метод установки месячного лимита на карту.
+// Лимит с тем же ключом заменяется, режим должен быть BudgetDecline или BudgetFlag
+func (s *Service) SetBudget(id CardId, key string, limit int64, mode BudgetMode) error {
+	return s.SetBudgetContext(context.Background(), id, key, limit, mode)
+}
+
+// Метод установки месячного лимита с проверкой права ActionTransfer
+func (s *Service) SetBudgetContext(ctx context.Context, id CardId, key string, limit int64, mode BudgetMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit <= 0 {
+		return ErrInvalidAmount
+	}
+	if mode != BudgetDecline && mode != BudgetFlag {
+		return ErrInvalidBudgetMode
+	}
+
+	c, err := s.cardById(id)
+	if err != nil {
+		return err
+	}
+	if err = s.authorize(ctx, ActionTransfer, c); err != nil {
+		return err
+	}
+
+	budget := Budget{Key: key, Limit: limit, Mode: mode}
+	for i := range c.Budgets {
+		if c.Budgets[i].Key == key {
+			c.Budgets[i] = budget
+			return nil
+		}
+	}
+	c.Budgets = append(c.Budgets, budget)
+
+	return nil
+}
+
+// Метод покупки по карте в валюте карты с проверкой месячных лимитов.
+// При превышении лимита с BudgetDecline транзакция сохраняется со статусом StatusDeclined
+// и возвращается ErrBudgetExceeded, с BudgetFlag - проводится со статусом StatusFlagged
+func (s *Service) Purchase(id CardId, amount int64, mcc string) (Transaction, error) {
+	return s.PurchaseInCurrencyContext(context.Background(), id, amount, "", mcc)
+}
+
+// Метод покупки по карте с проверкой права ActionTransfer
+func (s *Service) PurchaseContext(ctx context.Context, id CardId, amount int64, mcc string) (Transaction, error) {
+	return s.PurchaseInCurrencyContext(ctx, id, amount, "", mcc)
+}
+
+// Метод покупки по карте в указанной валюте.
+// Баланс и лимиты считаются в валюте карты по курсу на время покупки
+func (s *Service) PurchaseInCurrency(id CardId, amount int64, currency string, mcc string) (Transaction, error) {
+	return s.PurchaseInCurrencyContext(context.Background(), id, amount, currency, mcc)
+}
+
+// Метод покупки по карте в указанной валюте с проверкой права ActionTransfer
+func (s *Service) PurchaseInCurrencyContext(ctx context.Context, id CardId, amount int64, currency string, mcc string) (Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.purchase(ctx, id, amount, currency, mcc)
+}
+
+// Метод покупки по карте в указанной валюте. Вызывается под s.mu
+func (s *Service) purchase(ctx context.Context, id CardId, amount int64, currency string, mcc string) (Transaction, error) {
+	if amount <= 0 {
+		return Transaction{}, ErrInvalidAmount
+	}
+
+	c, err := s.cardById(id)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if err = s.authorize(ctx, ActionTransfer, c); err != nil {
+		return Transaction{}, err
+	}
+	if currency == c.Currency {
+		currency = ""
+	}
+
+	now := s.now()
+	transaction := Transaction{
+		Id:       s.newTransactionId(),
+		Bill:     amount,
+		Time:     now.Unix(),
+		MCC:      mcc,
+		Status:   StatusDone,
+		Type:     TypePurchase,
+		Currency: currency,
+	}
+	bill, err := c.BillInCardCurrency(s.Rates, transaction)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if int64(c.Balance) < bill {
+		return Transaction{}, ErrInsufficientFunds
+	}
+
+	for _, b := range c.Budgets {
+		if !b.Matches(mcc) {
+			continue
+		}
+		spent, err := c.spent(s.Rates, b, now)
+		if err != nil {
+			return Transaction{}, err
+		}
+		if spent+bill <= b.Limit {
+			continue
+		}
+		if b.Mode == BudgetDecline {
+			transaction.Status = StatusDeclined
+			c.AddTransaction(transaction)
+			return transaction, ErrBudgetExceeded
+		}
+		transaction.Status = StatusFlagged
+	}
+
+	s.checkFraud(c, transaction)
+	c.Balance -= int(bill)
+	c.AddTransaction(transaction)
+
+	return transaction, nil
+}
+
+// Метод получения состояния лимитов карты за месяц, в который попадает month
+func (s *Service) BudgetReport(id CardId, month time.Time) ([]BudgetStatus, error) {
+	return s.BudgetReportContext(context.Background(), id, month)
+}
+
+// Метод получения состояния лимитов карты с проверкой права ActionRead
+func (s *Service) BudgetReportContext(ctx context.Context, id CardId, month time.Time) ([]BudgetStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.cardById(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.authorize(ctx, ActionRead, c); err != nil {
+		return nil, err
+	}
+
+	report := make([]BudgetStatus, 0, len(c.Budgets))
+	for _, b := range c.Budgets {
+		spent, err := c.spent(s.Rates, b, month)
+		if err != nil {
+			return nil, err
+		}
+		report = append(report, BudgetStatus{
+			Budget:    b,
+			Name:      budgetName(b.Key),
+			Spent:     spent,
+			Remaining: b.Limit - spent,
+		})
+	}
+
+	return report, nil
+}
+
+// Метод расчета трат по лимиту за месяц в валюте карты.
+// Отклоненные транзакции не учитываются, возвраты и отмены уменьшают траты
+func (c *Card) spent(rates RateProvider, b Budget, month time.Time) (int64, error) {
+	month = month.UTC()
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC).Unix()
+	to := time.Date(month.Year(), month.Month()+1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	var spent int64
+	for _, t := range c.Transactions.Transactions {
+		if t.Time < from || t.Time >= to || t.Status == StatusDeclined || !b.Matches(t.MCC) {
+			continue
+		}
+		bill, err := c.BillInCardCurrency(rates, t)
+		if err != nil {
+			return 0, err
+		}
+		spent += bill
+	}
+
+	return spent, nil
+}
+
+// Функция получения названия лимита: название категории для кода MCC, иначе сам ключ
+func budgetName(key string) string {
+	if mcc, ok := DefaultMCCRegistry().Lookup(key); ok {
+		return mcc.NameRu
+	}
+	return key
+}
@@ -0,0 +1,123 @@
+package card
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestService_Purchase(t *testing.T) {
+	type args struct {
+		purchases []int64
+		mcc       string
+	}
+	tests := []struct {
+		name        string
+		budget      Budget
+		args        args
+		wantStatus  string
+		wantBalance int
+		wantErr     error
+	}{
+		{
+			name:        "Within budget",
+			budget:      Budget{Key: "5812", Limit: 20000_00, Mode: BudgetDecline},
+			args:        args{purchases: []int64{5000_00, 15000_00}, mcc: "5812"},
+			wantStatus:  StatusDone,
+			wantBalance: 30000_00,
+			wantErr:     nil,
+		},
+		{
+			name:        "Declined over budget",
+			budget:      Budget{Key: "5812", Limit: 20000_00, Mode: BudgetDecline},
+			args:        args{purchases: []int64{15000_00, 6000_00}, mcc: "5812"},
+			wantStatus:  StatusDeclined,
+			wantBalance: 35000_00,
+			wantErr:     ErrBudgetExceeded,
+		},
+		{
+			name:        "Flagged over category budget",
+			budget:      Budget{Key: "miscellaneous", Limit: 20000_00, Mode: BudgetFlag},
+			args:        args{purchases: []int64{15000_00, 6000_00}, mcc: "5814"},
+			wantStatus:  StatusFlagged,
+			wantBalance: 29000_00,
+			wantErr:     nil,
+		},
+		{
+			name:        "Other category",
+			budget:      Budget{Key: "5812", Limit: 100_00, Mode: BudgetDecline},
+			args:        args{purchases: []int64{1000_00}, mcc: "5411"},
+			wantStatus:  StatusDone,
+			wantBalance: 49000_00,
+			wantErr:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New("Test Bank")
+			s.CardIssue(1, "User", "User", "Visa", 50000_00, "RUB", "5106 2100 0000 0001")
+			if err := s.SetBudget(1, tt.budget.Key, tt.budget.Limit, tt.budget.Mode); err != nil {
+				t.Fatal(err)
+			}
+
+			var got Transaction
+			var err error
+			for _, amount := range tt.args.purchases {
+				got, err = s.Purchase(1, amount, tt.args.mcc)
+			}
+			if err != tt.wantErr {
+				t.Errorf("Purchase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("Purchase() status = %v, want %v", got.Status, tt.wantStatus)
+			}
+			if balance := s.cards[0].Balance; balance != tt.wantBalance {
+				t.Errorf("Purchase() balance = %v, want %v", balance, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestService_BudgetReport(t *testing.T) {
+	s := New("Test Bank")
+	c := s.issueCard(1, Owner{FirstName: "User", LastName: "User"}, "Visa", 50000_00, "RUB", "5106 2100 0000 0001")
+	month := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	c.AddTransaction(Transaction{Id: "0001", Bill: 3000_00, Time: month.AddDate(0, 0, 2).Unix(), MCC: "5812", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "0002", Bill: 5000_00, Time: month.AddDate(0, 0, 3).Unix(), MCC: "5812", Status: StatusDeclined})
+	c.AddTransaction(Transaction{Id: "0003", Bill: 4000_00, Time: month.AddDate(0, 1, 0).Unix(), MCC: "5812", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "0004", Bill: -1000_00, Time: month.AddDate(0, 0, 4).Unix(), MCC: "5812", Status: StatusDone, Type: TypeRefund, OriginalId: "0001"})
+	if err := s.SetBudget(1, "5812", 20000_00, BudgetDecline); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetBudget(1, "5812", 10000_00, BudgetFlag); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.BudgetReport(1, month)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BudgetStatus{{
+		Budget:    Budget{Key: "5812", Limit: 10000_00, Mode: BudgetFlag},
+		Name:      "Рестораны",
+		Spent:     2000_00,
+		Remaining: 8000_00,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BudgetReport() got = %v, want %v", got, want)
+	}
+}
+
+func TestService_SetBudgetInvalidMode(t *testing.T) {
+	s := New("Test Bank")
+	s.issueCard(1, Owner{FirstName: "User", LastName: "User"}, "Visa", 50000_00, "RUB", "5106 2100 0000 0001")
+
+	for _, mode := range []BudgetMode{"", "warn"} {
+		if err := s.SetBudget(1, "5812", 10000_00, mode); err != ErrInvalidBudgetMode {
+			t.Errorf("SetBudget() mode %q error = %v, wantErr %v", mode, err, ErrInvalidBudgetMode)
+		}
+	}
+	if got := s.cards[0].Budgets; len(got) != 0 {
+		t.Errorf("SetBudget() with invalid mode set budgets %v", got)
+	}
+}
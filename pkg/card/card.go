@@ -2,11 +2,11 @@
 package card
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -18,13 +18,21 @@ import (
 )
 
 var (
-	ErrCardNotFound   = errors.New("card not found")
-	ErrNoTransactions = errors.New("no user transactions")
+	ErrCardNotFound        = errors.New("card not found")
+	ErrNoTransactions      = errors.New("no user transactions")
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrInvalidAmount       = errors.New("invalid amount")
+	ErrNotRefundable       = errors.New("transaction can not be refunded")
+	ErrRefundExceeded      = errors.New("refund exceeds original bill")
+	ErrAlreadyReversed     = errors.New("transaction already reversed")
+	ErrAlreadyRefunded     = errors.New("transaction already refunded")
+	ErrCardExists          = errors.New("card already exists")
+	ErrInvalidCard         = errors.New("invalid card")
 )
 
 // Описание банковской карты"
 type Card struct {
-	Id           cardId
+	Id           CardId
 	Owner               // Владелец карты
 	Issuer       string // Платежная система
 	Balance      int    // Баланс карты
@@ -32,39 +40,65 @@ type Card struct {
 	Number       string // Номер карты в платежной системе
 	Icon         string // Иконка платежной системы
 	Transactions Transactions
+	Budgets      []Budget // Месячные лимиты трат по категориям
+	timeIndex    []int    // Позиции транзакций, упорядоченные по времени и идентификатору
 }
 
 // Идентификатор банковской карты
-type cardId int64
+type CardId int64
+
+// Идентификатор клиента банка. По нему, а не по имени, карты связываются с пользователем клиента
+type CustomerId int64
 
 // Инициалы владельца банковской карты
 type Owner struct {
-	FirstName string // Имя владельца карты
-	LastName  string // Фамилия владельца карты
+	CustomerId CustomerId // Клиент - владелец карты, 0 - карта не связана с клиентом
+	FirstName  string     // Имя владельца карты
+	LastName   string     // Фамилия владельца карты
 }
 
 type Transaction struct {
-	XMLName string `xml:"transaction"`
-	Id      string `json:"id" xml:"id"`
-	Bill    int64  `json:"bill" xml:"bill"`
-	Time    int64  `json:"time" xml:"time"`
-	MCC     string `json:"mcc" xml:"mcc"`
-	Status  string `json:"status" xml:"status"`
+	XMLName    string `xml:"transaction"`
+	Id         string `json:"id" xml:"id"`
+	Bill       int64  `json:"bill" xml:"bill"`
+	Time       int64  `json:"time" xml:"time"`
+	MCC        string `json:"mcc" xml:"mcc"`
+	Status     string `json:"status" xml:"status"`
+	Type       string `json:"type,omitempty" xml:"type,omitempty"`               // Тип операции: покупка, возврат, отмена
+	OriginalId string `json:"original_id,omitempty" xml:"original_id,omitempty"` // Идентификатор исходной транзакции для возврата и отмены
+	Currency   string `json:"currency,omitempty" xml:"currency,omitempty"`       // Валюта операции, пустая - валюта карты
 }
 
+// Статусы транзакций
+const (
+	StatusDone     = "Done"
+	StatusDeclined = "Declined" // Транзакция отклонена и не изменила баланс
+	StatusFlagged  = "Flagged"  // Транзакция проведена, но требует внимания
+	StatusReversed = "Reversed"
+)
+
 type Transactions struct {
-	XMLName      string `xml:"transactions"`
-	Transactions []Transaction
+	XMLName      string        `xml:"transactions"`
+	Transactions []Transaction `xml:"transaction"`
 }
 
 // Метод добавления транзакции
 func (c *Card) AddTransaction(transaction Transaction) {
 	c.Transactions.Transactions = append(c.Transactions.Transactions, transaction)
-
+	c.indexLast()
 }
 
 // Метод генерации 2х транзакций с разными MCC
 func (c *Card) MakeTransactions(count int) error {
+	return c.MakeTransactionsContext(context.Background(), count)
+}
+
+// Генератор идентификаторов транзакций, созданных без сервиса. Общий для всех карт,
+// поэтому идентификаторы не совпадают между картами
+var defaultIDs IDGenerator = NewULIDGenerator(SystemClock{})
+
+// Метод генерации транзакций с возможностью отмены. Идентификаторы берутся из defaultIDs
+func (c *Card) MakeTransactionsContext(ctx context.Context, count int) error {
 
 	if c == nil {
 		return ErrCardNotFound
@@ -75,22 +109,29 @@ func (c *Card) MakeTransactions(count int) error {
 		return nil
 	}
 
+	// Смещения считаются от начального времени, чтобы при большом count часы и минуты
+	// не переполнялись в другие дни
+	start := time.Date(2020, 9, 10, 12, 23, 21, 0, time.UTC)
 	for i := 0; i < count; i++ {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		at := start.Add(time.Duration(i) * time.Hour)
 		c.AddTransaction(Transaction{
-			Id: strconv.Itoa((i + 1) + i),
+			Id: defaultIDs.NewId(),
 
 			Bill: int64(100_00),
 
-			Time:   time.Date(2020, 9, 10, 12+i, 23+i, 21+i, 0, time.UTC).Unix(),
+			Time:   at.Unix(),
 			MCC:    "5411",
 			Status: "Done",
 		})
 		c.AddTransaction(Transaction{
-			Id: strconv.Itoa((i + 2) + i),
+			Id: defaultIDs.NewId(),
 
 			Bill: int64(102_00),
 
-			Time:   time.Date(2020, 9, 10, 14+i, 15+i, 21+i, 0, time.UTC).Unix(),
+			Time:   at.Add(time.Hour + 52*time.Minute).Unix(),
 			MCC:    "5812",
 			Status: "Done",
 		})
@@ -101,6 +142,56 @@ func (c *Card) MakeTransactions(count int) error {
 
 }
 
+// Метод генерации транзакций на карте сервиса.
+// Время транзакций берется по часам сервиса в прошлом, идентификаторы - из генератора сервиса
+func (s *Service) MakeTransactions(id CardId, count int) error {
+	return s.MakeTransactionsContext(context.Background(), id, count)
+}
+
+// Метод генерации транзакций на карте сервиса с проверкой права ActionIssue
+func (s *Service) MakeTransactionsContext(ctx context.Context, id CardId, count int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.cardById(id)
+	if err != nil {
+		return err
+	}
+	if err = s.authorize(ctx, ActionIssue, c); err != nil {
+		return err
+	}
+
+	if count <= 0 {
+		log.Println("count must be > 0")
+		return nil
+	}
+
+	// Транзакции идут по возрастанию времени и заканчиваются текущим моментом
+	start := s.now().Add(-time.Duration(count) * time.Hour)
+	for i := 0; i < count; i++ {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		at := start.Add(time.Duration(i+1) * time.Hour)
+		c.AddTransaction(Transaction{
+			Id:     s.newTransactionId(),
+			Bill:   int64(100_00),
+			Time:   at.Add(-30 * time.Minute).Unix(),
+			MCC:    "5411",
+			Status: StatusDone,
+		})
+		c.AddTransaction(Transaction{
+			Id:     s.newTransactionId(),
+			Bill:   int64(102_00),
+			Time:   at.Unix(),
+			MCC:    "5812",
+			Status: StatusDone,
+		})
+	}
+
+	return nil
+}
+
 // Функция расчета суммы по категории
 func SumByMCC(transactions []Transaction, mcc []string) int64 {
 	var mmcSum int64
@@ -119,16 +210,10 @@ func SumByMCC(transactions []Transaction, mcc []string) int64 {
 
 // Функция преобразования кода в название категории
 func TranslateMCC(code string) string {
-	// представим, что mcc читается из файла (научимся позже)
-	mcc := map[string]string{
-		"5411": "Супермаркеты",
-		"5812": "Рестораны",
-	}
-
 	const errCategoryUndef = "Категория не указана"
 
-	if value, ok := mcc[code]; ok {
-		return value
+	if value, ok := DefaultMCCRegistry().Lookup(code); ok {
+		return value.NameRu
 	}
 
 	return errCategoryUndef
@@ -137,56 +222,28 @@ func TranslateMCC(code string) string {
 
 // Функция сложения сумм транзакций по категориям
 func SumCategoryTransactions(transactions []Transaction) (map[string]int64, error) {
+	return SumCategoryTransactionsContext(context.Background(), transactions, 1)
+}
+
+// Функция сложения сумм транзакций по категориям в goroutines горутинах с возможностью отмены
+func SumCategoryTransactionsContext(ctx context.Context, transactions []Transaction, goroutines int) (map[string]int64, error) {
 
 	if transactions == nil {
 		return nil, ErrNoTransactions
 	}
 
-	m := make(map[string]int64)
-
-	for i := range transactions {
-		m[transactions[i].MCC] += transactions[i].Bill
-	}
-
-	return m, nil
+	return Aggregate(ctx, transactions, goroutines, ByMCC, ByBill)
 
 }
 
 // Функция сложения сумм транзакций по категориям с использованием goroutines и mutex
 func SumCategoryTransactionsMutex(transactions []Transaction, goroutines int) (map[string]int64, error) {
-	wg := sync.WaitGroup{}
-	wg.Add(goroutines)
-
-	mu := sync.Mutex{}
 
 	if transactions == nil {
 		return nil, ErrNoTransactions
 	}
 
-	m := make(map[string]int64)
-
-	partSize := len(transactions) / goroutines
-
-	for i := 0; i < goroutines; i++ {
-		part := transactions[i*partSize : (i+1)*partSize]
-		go func() {
-			mapSum, err := SumCategoryTransactions(part)
-			if err != nil {
-				fmt.Println(err)
-			}
-			mu.Lock()
-			for key, i := range mapSum {
-				m[key] += i
-
-			}
-			mu.Unlock()
-			wg.Done()
-		}()
-
-	}
-	wg.Wait()
-
-	return m, nil
+	return Aggregate(context.Background(), transactions, goroutines, ByMCC, ByBill)
 
 }
 
@@ -197,126 +254,273 @@ func SumCategoryTransactionsChan(transactions []Transaction, goroutines int) (ma
 		return nil, ErrNoTransactions
 	}
 
-	result := make(map[string]int64)
-	ch := make(chan map[string]int64)
-	partSize := len(transactions) / goroutines
-
-	for i := 0; i < goroutines; i++ {
-		part := transactions[i*partSize : (i+1)*partSize]
-		go func(ch chan<- map[string]int64) {
-			s, err := SumCategoryTransactions(part)
-			if err != nil {
-				log.Printf("failed to sum: %s\n", err)
-			}
-			ch <- s
-		}(ch)
-	}
-
-	fin := 0
-
-	for sum := range ch {
-		for k, v := range sum {
-			result[k] += v
-
-		}
-		fin++
-		if fin == goroutines {
-			close(ch)
-			break
-		}
-	}
-
-	return result, nil
+	return Aggregate(context.Background(), transactions, goroutines, ByMCC, ByBill)
 
 }
 
-// Функция с mutex'ом, который защищает любые операции с map, соответственно, её задача: разделить слайс транзакций на несколько кусков и в отдельных горутинах посчитать, но теперь горутины напрямую пишут в общий map с результатами. Важно: эта функция внутри себя не должна вызывать функцию из п.1
+// Функция сложения сумм транзакций по категориям, в которой горутины пишут в общий map с результатами
 func SumCategoryTransactionsMutexWithoutFunc(transactions []Transaction, goroutines int) (map[string]int64, error) {
-	wg := sync.WaitGroup{}
-	wg.Add(goroutines)
-
-	mu := sync.Mutex{}
 
 	if transactions == nil {
 		return nil, ErrNoTransactions
 	}
 
-	mapSum := make(map[string]int64)
-
-	partSize := len(transactions) / goroutines
-
-	for i := 0; i < goroutines; i++ {
-		part := transactions[i*partSize : (i+1)*partSize]
-		go func() {
-
-			for i := range part {
-				mu.Lock()
-				mapSum[part[i].MCC] += part[i].Bill
-				mu.Unlock()
-			}
-			wg.Done()
-
-		}()
-
-	}
-	wg.Wait()
-
-	return mapSum, nil
+	return Aggregate(context.Background(), transactions, goroutines, ByMCC, ByBill)
 
 }
 
 // Сервис банка
 type Service struct {
 	BankName string
-	Cards    []*Card
+	Rates    RateProvider // Курсы для операций в валюте, отличной от валюты карты
+	Clock    Clock        // Источник времени новых транзакций
+	IDs      IDGenerator  // Генератор идентификаторов новых транзакций
+	Fraud    *FraudEngine // Проверка новых транзакций, пустой движок отключает проверку
+	AlertIDs IDGenerator  // Генератор идентификаторов срабатываний, отдельный от транзакций
+	// Запрет вызовов без Principal в контексте. Методы без контекста в этом случае тоже запрещены,
+	// потому что выполняются с context.Background()
+	RequirePrincipal bool
+
+	mu        sync.Mutex // Защищает карты, регулярные платежи и срабатывания правил
+	cards     []*Card    // Наружу выдаются только копии карт
+	recurring []*Recurring
+	alerts    []Alert
 }
 
 // Конструктор сервиса
 func New(bankName string) *Service {
-	return &Service{BankName: bankName}
+	return &Service{
+		BankName: bankName,
+		Clock:    SystemClock{},
+		IDs:      NewULIDGenerator(SystemClock{}),
+		Fraud:    NewFraudEngine(DefaultFraudRules()...),
+		AlertIDs: NewSequenceGenerator("alert"),
+	}
+}
+
+// Метод получения текущего времени по часам сервиса
+func (s *Service) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// Метод получения идентификатора новой транзакции.
+// Сервис, созданный без конструктора, получает генератор ULID при первом вызове
+func (s *Service) newTransactionId() string {
+	if s.IDs == nil {
+		s.IDs = NewULIDGenerator(SystemClock{})
+	}
+	return s.IDs.NewId()
+}
+
+// Метод получения идентификатора нового срабатывания правила
+func (s *Service) newAlertId() string {
+	if s.AlertIDs == nil {
+		s.AlertIDs = NewSequenceGenerator("alert")
+	}
+	return s.AlertIDs.NewId()
 }
 
 // Метод создания экземпляра банковской карты
 func (s *Service) CardIssue(
-	id cardId,
+	id CardId,
 	fistName,
 	lastName,
 	issuer string,
 	balance int,
 	currency string,
 	number string,
-) *Card {
+) (Card, error) {
+	owner := Owner{FirstName: fistName, LastName: lastName}
+	return s.CardIssueContext(context.Background(), id, owner, issuer, balance, currency, number)
+}
+
+// Метод создания карты с начальным балансом с проверкой права ActionIssue
+func (s *Service) CardIssueContext(ctx context.Context, id CardId, owner Owner, issuer string, balance int, currency, number string) (Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.authorize(ctx, ActionIssue, nil); err != nil {
+		return Card{}, err
+	}
+	return s.issueCard(id, owner, issuer, balance, currency, number).snapshot(), nil
+}
+
+// Метод добавления карты без проверок. Вызывается под s.mu
+func (s *Service) issueCard(id CardId, owner Owner, issuer string, balance int, currency, number string) *Card {
 	var card = &Card{
-		Id: id,
-		Owner: Owner{
-			FirstName: fistName,
-			LastName:  lastName,
-		},
+		Id:       id,
+		Owner:    owner,
 		Issuer:   issuer,
 		Balance:  balance,
 		Currency: currency,
 		Number:   number,
 		Icon:     "https://.../logo.png",
 	}
-	s.Cards = append(s.Cards, card)
+	s.cards = append(s.cards, card)
 	return card
 }
 
-const prefix = "5106 21" //Первые 6 цифр нашего банка
+// Метод выпуска карты с нулевым балансом под блокировкой сервиса. В отличие от CardIssue
+// проверяет, что карты с таким идентификатором еще нет
+func (s *Service) OpenCard(id CardId, owner Owner, issuer, currency, number string) (Card, error) {
+	return s.OpenCardContext(context.Background(), id, owner, issuer, currency, number)
+}
 
-// Метод поиска банковской карты по номеру платежной системы
-func (s *Service) Card() (*Card, error) {
+// Метод выпуска карты с проверкой права ActionIssue
+func (s *Service) OpenCardContext(ctx context.Context, id CardId, owner Owner, issuer, currency, number string) (Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.authorize(ctx, ActionIssue, nil); err != nil {
+		return Card{}, err
+	}
+	if id <= 0 || owner.CustomerId <= 0 || owner.FirstName == "" || owner.LastName == "" || currency == "" || number == "" {
+		return Card{}, ErrInvalidCard
+	}
+	if _, err := s.cardById(id); err == nil {
+		return Card{}, ErrCardExists
+	}
+	return s.issueCard(id, owner, issuer, 0, currency, number).snapshot(), nil
+}
+
+// Метод получения копии карты по идентификатору
+func (s *Service) CardById(id CardId) (Card, error) {
+	return s.CardContext(context.Background(), id)
+}
+
+// Метод получения копии карты с проверкой права ActionRead.
+// Изменения копии не влияют на карту сервиса
+func (s *Service) CardContext(ctx context.Context, id CardId) (Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.cardById(id)
+	if err != nil {
+		return Card{}, err
+	}
+	if err = s.authorize(ctx, ActionRead, c); err != nil {
+		return Card{}, err
+	}
+	return c.snapshot(), nil
+}
+
+// Метод добавления транзакций на карту без изменения баланса, например, прочитанных ImporterFromCsv
+func (s *Service) AddTransactions(id CardId, transactions ...Transaction) error {
+	return s.AddTransactionsContext(context.Background(), id, transactions...)
+}
 
-	for _, c := range s.Cards {
-		if strings.HasPrefix(c.Number, prefix) == true {
+// Метод добавления транзакций на карту с проверкой права ActionIssue
+func (s *Service) AddTransactionsContext(ctx context.Context, id CardId, transactions ...Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.cardById(id)
+	if err != nil {
+		return err
+	}
+	if err = s.authorize(ctx, ActionIssue, c); err != nil {
+		return err
+	}
+	for _, t := range transactions {
+		c.AddTransaction(t)
+	}
+	return nil
+}
+
+// Метод поиска банковской карты по идентификатору. Вызывается под s.mu
+func (s *Service) cardById(id CardId) (*Card, error) {
+	for _, c := range s.cards {
+		if c.Id == id {
 			return c, nil
 		}
 	}
 	return nil, ErrCardNotFound
 }
 
+// Метод получения копии карты, не разделяющей с ней транзакции и лимиты
+func (c *Card) snapshot() Card {
+	copied := *c
+	copied.Transactions.Transactions = append([]Transaction(nil), c.Transactions.Transactions...)
+	copied.Budgets = append([]Budget(nil), c.Budgets...)
+	copied.timeIndex = append([]int(nil), c.timeIndex...)
+	return copied
+}
+
+// Метод получения баланса и валюты карты под блокировкой сервиса
+func (s *Service) Balance(id CardId) (int, string, error) {
+	return s.BalanceContext(context.Background(), id)
+}
+
+// Метод получения баланса и валюты карты с проверкой права ActionRead
+func (s *Service) BalanceContext(ctx context.Context, id CardId) (int, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.cardById(id)
+	if err != nil {
+		return 0, "", err
+	}
+	if err = s.authorize(ctx, ActionRead, c); err != nil {
+		return 0, "", err
+	}
+	return c.Balance, c.Currency, nil
+}
+
+// Метод получения идентификаторов карт клиента в порядке выпуска
+func (s *Service) CardsByCustomer(customer CustomerId) []CardId {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []CardId
+	for _, c := range s.cards {
+		if customer != 0 && c.CustomerId == customer {
+			ids = append(ids, c.Id)
+		}
+	}
+	return ids
+}
+
+// Метод получения копий карт, которые разрешено читать, в порядке выпуска.
+// Заменяет поле Cards прежних версий: изменения копий не влияют на карты сервиса
+func (s *Service) Cards() []Card {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cards := make([]Card, 0, len(s.cards))
+	for _, c := range s.cards {
+		if s.authorize(context.Background(), ActionRead, c) == nil {
+			cards = append(cards, c.snapshot())
+		}
+	}
+	return cards
+}
+
+const prefix = "5106 21" //Первые 6 цифр нашего банка
+
+// Метод поиска банковской карты по номеру платежной системы. Возвращает копию первой карты банка,
+// которую разрешено читать
+func (s *Service) Card() (Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.cards {
+		if strings.HasPrefix(c.Number, prefix) == true && s.authorize(context.Background(), ActionRead, c) == nil {
+			return c.snapshot(), nil
+		}
+	}
+	return Card{}, ErrCardNotFound
+}
+
 // Функция экспорта пользовательских транзакций в .csv
 func ExporterToCsv(user *Card) error {
+	return ExporterToCsvContext(context.Background(), user)
+}
+
+// Функция экспорта пользовательских транзакций в .csv с возможностью отмены
+func ExporterToCsvContext(ctx context.Context, user *Card) error {
 
 	file, err := os.Create("export.csv")
 
@@ -331,16 +535,19 @@ func ExporterToCsv(user *Card) error {
 		}
 	}(file)
 
-	writer := csv.NewWriter(file)
+	writer := csv.NewWriter(contextWriter{ctx: ctx, w: file})
 	defer writer.Flush()
 
-	err = writer.Write([]string{"ID", "Bill", "Time", "MCC", "Status"})
+	err = writer.Write([]string{"ID", "Bill", "Time", "MCC", "Status", "Type", "OriginalID", "Currency"})
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 
-	for _, value := range user.Transactions.Transactions {
+	for i, value := range user.Transactions.Transactions {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
 		err = writer.Write(transactionToSlice(value))
 		if err != nil {
 			log.Println(err)
@@ -354,6 +561,11 @@ func ExporterToCsv(user *Card) error {
 
 // Функция импорта пользовательских транзакций из .csv
 func ImporterFromCsv(us *Card, fileName string) error {
+	return ImporterFromCsvContext(context.Background(), us, fileName)
+}
+
+// Функция импорта пользовательских транзакций из .csv с возможностью отмены
+func ImporterFromCsvContext(ctx context.Context, us *Card, fileName string) error {
 	file, err := os.Open(fileName)
 	if err != nil {
 		log.Println("Cannot open file", err)
@@ -364,14 +576,17 @@ func ImporterFromCsv(us *Card, fileName string) error {
 		}
 	}(file)
 
-	reader := csv.NewReader(file)
+	reader := csv.NewReader(contextReader{ctx: ctx, r: file})
 
 	records, err := reader.ReadAll()
 	if err != nil {
 		log.Println("Cannot read data", err)
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
-	err = us.MapRowToTransaction(records)
+	err = us.MapRowToTransactionContext(ctx, records)
 	if err != nil {
 		return err
 	}
@@ -381,10 +596,18 @@ func ImporterFromCsv(us *Card, fileName string) error {
 
 // Функция экспорта пользовательских транзакций в .json
 func ExporterToJson(user *Card, fileName string) error {
+	return ExporterToJsonContext(context.Background(), user, fileName)
+}
+
+// Функция экспорта пользовательских транзакций в .json с возможностью отмены
+func ExporterToJsonContext(ctx context.Context, user *Card, fileName string) error {
 	file, err := json.MarshalIndent(user.Transactions, "", " ")
 	if err != nil {
 		log.Println(err)
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	err = ioutil.WriteFile(fileName, file, 0644)
 	if err != nil {
@@ -395,6 +618,11 @@ func ExporterToJson(user *Card, fileName string) error {
 
 // Функция импорта пользовательских транзакций из .json
 func ImporterFromJson(user *Card, fileName string) error {
+	return ImporterFromJsonContext(context.Background(), user, fileName)
+}
+
+// Функция импорта пользовательских транзакций из .json с возможностью отмены
+func ImporterFromJsonContext(ctx context.Context, user *Card, fileName string) error {
 
 	file, err := os.Open(fileName)
 	if err != nil {
@@ -406,7 +634,7 @@ func ImporterFromJson(user *Card, fileName string) error {
 		}
 	}(file)
 
-	reader, err := ioutil.ReadAll(file)
+	reader, err := ioutil.ReadAll(contextReader{ctx: ctx, r: file})
 	if err != nil {
 		return err
 	}
@@ -415,6 +643,9 @@ func ImporterFromJson(user *Card, fileName string) error {
 	if err != nil {
 		return err
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	for _, value := range decoded.Transactions {
 		user.Transactions.Transactions = append(user.Transactions.Transactions, value)
@@ -425,10 +656,18 @@ func ImporterFromJson(user *Card, fileName string) error {
 
 // Функция экспорта пользовательских транзакций в .xml
 func ExporterToXml(user *Card, fileName string) error {
+	return ExporterToXmlContext(context.Background(), user, fileName)
+}
+
+// Функция экспорта пользовательских транзакций в .xml с возможностью отмены
+func ExporterToXmlContext(ctx context.Context, user *Card, fileName string) error {
 	file, err := xml.MarshalIndent(user.Transactions, "", " ")
 	if err != nil {
 		return err
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	file = append([]byte(xml.Header), file...)
 
@@ -442,6 +681,11 @@ func ExporterToXml(user *Card, fileName string) error {
 
 // Функция импорта пользовательских транзакций из .xml
 func ImporterFromXml(user *Card, fileName string) error {
+	return ImporterFromXmlContext(context.Background(), user, fileName)
+}
+
+// Функция импорта пользовательских транзакций из .xml с возможностью отмены
+func ImporterFromXmlContext(ctx context.Context, user *Card, fileName string) error {
 
 	file, err := os.Open(fileName)
 	if err != nil {
@@ -453,7 +697,7 @@ func ImporterFromXml(user *Card, fileName string) error {
 		}
 	}(file)
 
-	reader, err := ioutil.ReadAll(file)
+	reader, err := ioutil.ReadAll(contextReader{ctx: ctx, r: file})
 	if err != nil {
 		return err
 	}
@@ -463,6 +707,9 @@ func ImporterFromXml(user *Card, fileName string) error {
 	if err != nil {
 		return err
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	for _, value := range decoded.Transactions {
 		user.Transactions.Transactions = append(user.Transactions.Transactions, value)
@@ -473,9 +720,18 @@ func ImporterFromXml(user *Card, fileName string) error {
 	return nil
 }
 
+// Метод преобразования строк csv в транзакции карты
 func (c *Card) MapRowToTransaction(transactions [][]string) error {
+	return c.MapRowToTransactionContext(context.Background(), transactions)
+}
+
+// Метод преобразования строк csv в транзакции карты с возможностью отмены
+func (c *Card) MapRowToTransactionContext(ctx context.Context, transactions [][]string) error {
 
-	for _, i := range transactions {
+	for n, i := range transactions {
+		if n%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if i[0] == "ID" {
 			continue
 		}
@@ -496,6 +752,14 @@ func (c *Card) MapRowToTransaction(transactions [][]string) error {
 			MCC:    i[3],
 			Status: i[4],
 		}
+		// Файлы старого формата не содержат колонок типа и исходной транзакции
+		if len(i) > 6 {
+			transaction.Type = i[5]
+			transaction.OriginalId = i[6]
+		}
+		if len(i) > 7 {
+			transaction.Currency = i[7]
+		}
 
 		c.AddTransaction(transaction)
 	}
@@ -512,6 +776,9 @@ func transactionToSlice(transaction Transaction) []string {
 	data = append(data, strconv.Itoa(int(transaction.Time)))
 	data = append(data, transaction.MCC)
 	data = append(data, transaction.Status)
+	data = append(data, transaction.Type)
+	data = append(data, transaction.OriginalId)
+	data = append(data, transaction.Currency)
 
 	return data
 
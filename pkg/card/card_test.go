@@ -206,13 +206,13 @@ func BenchmarkSumCategoryTransactions(b *testing.B) {
 	}
 
 	want := map[string]int64{
-		"5411": 5_099_995_000_00,
-		"5812": 5_101_995_000_00,
+		"5411": 100_000_000_00,
+		"5812": 102_000_000_00,
 	}
 	b.ResetTimer() // сбрасываем таймер, т.к. сама генерация транзакций достаточно ресурсоёмка
 
 	for i := 0; i < b.N; i++ {
-		result, err := SumCategoryTransactions(user.Transactions)
+		result, err := SumCategoryTransactions(user.Transactions.Transactions)
 		if err != nil {
 			log.Println(err)
 			break
@@ -238,7 +238,7 @@ func BenchmarkSumCategoryTransactionsMutex(b *testing.B) {
 		Currency:     "RUR",
 		Number:       "4619071400941155",
 		Icon:         "https://cdn.visa.com/cdn/assets/images/logos/visa/logo.png",
-		Transactions: []Transaction{},
+		Transactions: Transactions{},
 	}
 
 	err := user.MakeTransactions(1_000_000)
@@ -247,13 +247,13 @@ func BenchmarkSumCategoryTransactionsMutex(b *testing.B) {
 	}
 
 	want := map[string]int64{
-		"5411": 5_099_995_000_00,
-		"5812": 5_101_995_000_00,
+		"5411": 100_000_000_00,
+		"5812": 102_000_000_00,
 	}
 	b.ResetTimer() // сбрасываем таймер, т.к. сама генерация транзакций достаточно ресурсоёмка
 
 	for i := 0; i < b.N; i++ {
-		result, err := SumCategoryTransactionsMutex(user.Transactions, runtime.NumCPU())
+		result, err := SumCategoryTransactionsMutex(user.Transactions.Transactions, runtime.NumCPU())
 		if err != nil {
 			log.Println(err)
 			break
@@ -279,7 +279,7 @@ func BenchmarkSumCategoryTransactionsChan(b *testing.B) {
 		Currency:     "RUR",
 		Number:       "4619071400941155",
 		Icon:         "https://cdn.visa.com/cdn/assets/images/logos/visa/logo.png",
-		Transactions: []Transaction{},
+		Transactions: Transactions{},
 	}
 
 	err := user.MakeTransactions(1_000_000)
@@ -288,13 +288,13 @@ func BenchmarkSumCategoryTransactionsChan(b *testing.B) {
 	}
 
 	want := map[string]int64{
-		"5411": 5_099_995_000_00,
-		"5812": 5_101_995_000_00,
+		"5411": 100_000_000_00,
+		"5812": 102_000_000_00,
 	}
 	b.ResetTimer() // сбрасываем таймер, т.к. сама генерация транзакций достаточно ресурсоёмка
 
 	for i := 0; i < b.N; i++ {
-		result, err := SumCategoryTransactionsChan(user.Transactions, runtime.NumCPU())
+		result, err := SumCategoryTransactionsChan(user.Transactions.Transactions, runtime.NumCPU())
 		if err != nil {
 			log.Println(err)
 			break
@@ -320,7 +320,7 @@ func BenchmarkSumCategoryTransactionsMutexWithoutFunc(b *testing.B) {
 		Currency:     "RUR",
 		Number:       "4619071400941155",
 		Icon:         "https://cdn.visa.com/cdn/assets/images/logos/visa/logo.png",
-		Transactions: []Transaction{},
+		Transactions: Transactions{},
 	}
 
 	err := user.MakeTransactions(1_000_000)
@@ -329,13 +329,13 @@ func BenchmarkSumCategoryTransactionsMutexWithoutFunc(b *testing.B) {
 	}
 
 	want := map[string]int64{
-		"5411": 5_099_995_000_00,
-		"5812": 5_101_995_000_00,
+		"5411": 100_000_000_00,
+		"5812": 102_000_000_00,
 	}
 	b.ResetTimer() // сбрасываем таймер, т.к. сама генерация транзакций достаточно ресурсоёмка
 
 	for i := 0; i < b.N; i++ {
-		result, err := SumCategoryTransactionsMutexWithoutFunc(user.Transactions, runtime.NumCPU())
+		result, err := SumCategoryTransactionsMutexWithoutFunc(user.Transactions.Transactions, runtime.NumCPU())
 		if err != nil {
 			log.Println(err)
 			break
@@ -347,3 +347,64 @@ func BenchmarkSumCategoryTransactionsMutexWithoutFunc(b *testing.B) {
 		b.StartTimer() // продолжаем работу таймера
 	}
 }
+
+func TestService_CardsByCustomer(t *testing.T) {
+	s := New("Test Bank")
+	s.issueCard(1, Owner{CustomerId: 1, FirstName: "Ivan", LastName: "Ivanov"}, "Visa", 0, "RUB", "5106 2100 0000 0001")
+	s.issueCard(2, Owner{CustomerId: 2, FirstName: "Petr", LastName: "Petrov"}, "Visa", 0, "RUB", "5106 2100 0000 0002")
+	s.issueCard(3, Owner{CustomerId: 1, FirstName: "Ivan", LastName: "Ivanov"}, "MasterCard", 0, "RUB", "5106 2100 0000 0003")
+	s.issueCard(4, Owner{CustomerId: 3, FirstName: "Ivan", LastName: "Ivanov"}, "Visa", 0, "RUB", "5106 2100 0000 0004")
+	s.CardIssue(5, "Ivan", "Ivanov", "Visa", 0, "RUB", "5106 2100 0000 0005")
+
+	tests := []struct {
+		name     string
+		customer CustomerId
+		want     []CardId
+	}{
+		{name: "Two cards", customer: 1, want: []CardId{1, 3}},
+		{name: "One card", customer: 2, want: []CardId{2}},
+		{name: "Namesake", customer: 3, want: []CardId{4}},
+		{name: "No cards", customer: 4},
+		{name: "Without customer", customer: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.CardsByCustomer(tt.customer); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CardsByCustomer() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_OpenCard(t *testing.T) {
+	s := New("Test Bank")
+	s.CardIssue(1, "Ivan", "Ivanov", "Visa", 0, "RUB", "5106 2100 0000 0001")
+	owner := Owner{CustomerId: 2, FirstName: "Petr", LastName: "Petrov"}
+
+	tests := []struct {
+		name    string
+		id      CardId
+		owner   Owner
+		wantErr error
+	}{
+		{name: "New card", id: 2, owner: owner},
+		{name: "Existing id", id: 1, owner: owner, wantErr: ErrCardExists},
+		{name: "Zero id", id: 0, owner: owner, wantErr: ErrInvalidCard},
+		{name: "Without owner", id: 3, wantErr: ErrInvalidCard},
+		{name: "Without customer", id: 3, owner: Owner{FirstName: "Petr", LastName: "Petrov"}, wantErr: ErrInvalidCard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.OpenCard(tt.id, tt.owner, "Visa", "RUB", "5106 2100 0000 0002")
+			if err != tt.wantErr {
+				t.Fatalf("OpenCard() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Id != tt.id || got.Owner != tt.owner || got.Balance != 0) {
+				t.Errorf("OpenCard() got = %v", got)
+			}
+		})
+	}
+	if got := s.CardsByCustomer(owner.CustomerId); !reflect.DeepEqual(got, []CardId{2}) {
+		t.Errorf("CardsByCustomer() after OpenCard() got = %v", got)
+	}
+}
@@ -0,0 +1,198 @@
+package card
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Правило начисления кешбэка
+type CashbackRule struct {
+	Key        string // Код MCC или группа категорий, пустой ключ подходит для любых покупок
+	Rate       int64  // Ставка в сотых долях процента: 500 - это 5%
+	MonthlyCap int64  // Максимальный кешбэк по правилу за месяц, 0 - без ограничения
+}
+
+// Движок начисления кешбэка.
+// Для каждой покупки применяется первое подходящее правило
+type CashbackEngine struct {
+	Rules []CashbackRule
+	Rates RateProvider // Курсы для пересчета покупок в иностранной валюте в валюту карты
+}
+
+// Начисление кешбэка по карте за месяц по одному правилу
+type Accrual struct {
+	XMLName string `xml:"accrual"`
+	CardId  CardId `json:"card_id" xml:"card_id"`
+	Period  string `json:"period" xml:"period"` // Месяц в формате 2006-01
+	Key     string `json:"key" xml:"key"`       // Ключ сработавшего правила
+	Base    int64  `json:"base" xml:"base"`     // Сумма покупок в валюте карты, участвующих в начислении
+	Amount  int64  `json:"amount" xml:"amount"`
+}
+
+// Журнал начислений кешбэка
+type Ledger struct {
+	XMLName  string `xml:"ledger"`
+	Accruals []Accrual
+}
+
+// Конструктор движка кешбэка
+func NewCashbackEngine(rules ...CashbackRule) *CashbackEngine {
+	return &CashbackEngine{Rules: rules}
+}
+
+// Метод поиска правила для кода MCC
+func (e *CashbackEngine) rule(mcc string) (CashbackRule, bool) {
+	for _, r := range e.Rules {
+		if r.Key == "" || r.Key == mcc || r.Key == DefaultMCCRegistry().Group(mcc) {
+			return r, true
+		}
+	}
+	return CashbackRule{}, false
+}
+
+// Метод начисления кешбэка по транзакциям карт.
+// Отклоненные и отмененные покупки не учитываются, из частично возвращенных учитывается остаток.
+// Суммы пересчитываются в валюту карты по курсу на время транзакции, лимиты правил тоже в валюте карты
+func (e *CashbackEngine) Accrue(cards ...*Card) (Ledger, error) {
+	type accrualKey struct {
+		cardId CardId
+		period string
+		key    string
+	}
+
+	accruals := make(map[accrualKey]*Accrual)
+	var keys []accrualKey
+
+	for _, c := range cards {
+		refunded := make(map[string]int64)
+		for _, t := range c.Transactions.Transactions {
+			if t.Type == TypeRefund {
+				bill, err := c.BillInCardCurrency(e.Rates, t)
+				if err != nil {
+					return Ledger{}, err
+				}
+				refunded[t.OriginalId] += -bill
+			}
+		}
+
+		for _, t := range c.Transactions.Transactions {
+			if !t.IsPurchase() || t.Status == StatusDeclined || t.Status == StatusReversed {
+				continue
+			}
+			bill, err := c.BillInCardCurrency(e.Rates, t)
+			if err != nil {
+				return Ledger{}, err
+			}
+			base := bill - refunded[t.Id]
+			if base <= 0 {
+				continue
+			}
+			r, ok := e.rule(t.MCC)
+			if !ok {
+				continue
+			}
+
+			k := accrualKey{
+				cardId: c.Id,
+				period: time.Unix(t.Time, 0).UTC().Format("2006-01"),
+				key:    r.Key,
+			}
+			a, ok := accruals[k]
+			if !ok {
+				a = &Accrual{CardId: k.cardId, Period: k.period, Key: k.key}
+				accruals[k] = a
+				keys = append(keys, k)
+			}
+			a.Base += base
+			a.Amount = a.Base * r.Rate / 100_00
+			if r.MonthlyCap > 0 && a.Amount > r.MonthlyCap {
+				a.Amount = r.MonthlyCap
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].cardId != keys[j].cardId {
+			return keys[i].cardId < keys[j].cardId
+		}
+		if keys[i].period != keys[j].period {
+			return keys[i].period < keys[j].period
+		}
+		return keys[i].key < keys[j].key
+	})
+
+	ledger := Ledger{Accruals: make([]Accrual, 0, len(keys))}
+	for _, k := range keys {
+		ledger.Accruals = append(ledger.Accruals, *accruals[k])
+	}
+
+	return ledger, nil
+}
+
+// Функция экспорта журнала начислений в .csv
+func ExportLedgerToCsv(ledger Ledger, fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	defer func(c io.Closer) {
+		if cerr := c.Close(); cerr != nil {
+			log.Println(cerr)
+		}
+	}(file)
+
+	writer := csv.NewWriter(file)
+
+	err = writer.Write([]string{"CardID", "Period", "Key", "Base", "Amount"})
+	if err != nil {
+		return err
+	}
+
+	for _, a := range ledger.Accruals {
+		err = writer.Write([]string{
+			strconv.FormatInt(int64(a.CardId), 10),
+			a.Period,
+			a.Key,
+			strconv.FormatInt(a.Base, 10),
+			strconv.FormatInt(a.Amount, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Функция экспорта журнала начислений в .json
+func ExportLedgerToJson(ledger Ledger, fileName string) error {
+	file, err := json.MarshalIndent(ledger, "", " ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fileName, file, 0644)
+}
+
+// Функция экспорта журнала начислений в .xml
+func ExportLedgerToXml(ledger Ledger, fileName string) error {
+	file, err := xml.MarshalIndent(ledger, "", " ")
+	if err != nil {
+		return err
+	}
+
+	file = append([]byte(xml.Header), file...)
+
+	return ioutil.WriteFile(fileName, file, 0644)
+}
@@ -0,0 +1,113 @@
+package card
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCashbackEngine_Accrue(t *testing.T) {
+	sep := time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC).Unix()
+	oct := time.Date(2020, 10, 10, 12, 0, 0, 0, time.UTC).Unix()
+	c := &Card{Id: 1}
+	c.AddTransaction(Transaction{Id: "0001", Bill: 1000_00, Time: sep, MCC: "5411", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "0002", Bill: 2000_00, Time: sep, MCC: "5812", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "0003", Bill: 5000_00, Time: sep, MCC: "5812", Status: StatusDeclined})
+	c.AddTransaction(Transaction{Id: "0004", Bill: 3000_00, Time: sep, MCC: "5812", Status: StatusReversed})
+	c.AddTransaction(Transaction{Id: "0004-reversal", Bill: -3000_00, Time: sep, MCC: "5812", Status: StatusDone, Type: TypeReversal, OriginalId: "0004"})
+	c.AddTransaction(Transaction{Id: "0005", Bill: 500_00, Time: sep, MCC: "5411", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "0005-refund-1", Bill: -200_00, Time: sep, MCC: "5411", Status: StatusDone, Type: TypeRefund, OriginalId: "0005"})
+	c.AddTransaction(Transaction{Id: "0006", Bill: 10000_00, Time: oct, MCC: "5411", Status: StatusDone})
+
+	engine := NewCashbackEngine(
+		CashbackRule{Key: "5411", Rate: 5_00, MonthlyCap: 300_00},
+		CashbackRule{Key: "", Rate: 1_00},
+	)
+
+	got, err := engine.Accrue(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Ledger{Accruals: []Accrual{
+		{CardId: 1, Period: "2020-09", Key: "", Base: 2000_00, Amount: 20_00},
+		{CardId: 1, Period: "2020-09", Key: "5411", Base: 1300_00, Amount: 65_00},
+		{CardId: 1, Period: "2020-10", Key: "5411", Base: 10000_00, Amount: 300_00},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Accrue() got = %v, want %v", got, want)
+	}
+}
+
+func TestCashbackEngine_AccrueInCurrency(t *testing.T) {
+	sep := time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC).Unix()
+	c := &Card{Id: 1, Currency: "RUB"}
+	c.AddTransaction(Transaction{Id: "0001", Bill: 10_00, Time: sep, MCC: "5411", Status: StatusDone, Currency: "USD"})
+	c.AddTransaction(Transaction{Id: "0001-refund-1", Bill: -4_00, Time: sep, MCC: "5411", Status: StatusDone, Type: TypeRefund, OriginalId: "0001", Currency: "USD"})
+	c.AddTransaction(Transaction{Id: "0002", Bill: 100_00, Time: sep, MCC: "5411", Status: StatusDone})
+
+	engine := NewCashbackEngine(CashbackRule{Key: "5411", Rate: 5_00})
+
+	if _, err := engine.Accrue(c); err != ErrRateNotFound {
+		t.Errorf("Accrue() error = %v, wantErr %v", err, ErrRateNotFound)
+	}
+
+	engine.Rates = staticRates{"USD": 75}
+	got, err := engine.Accrue(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Ledger{Accruals: []Accrual{
+		{CardId: 1, Period: "2020-09", Key: "5411", Base: 550_00, Amount: 27_50},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Accrue() got = %v, want %v", got, want)
+	}
+}
+
+func TestLedger_Export(t *testing.T) {
+	dir := t.TempDir()
+	ledger := Ledger{Accruals: []Accrual{
+		{CardId: 1, Period: "2020-09", Key: "5411", Base: 1300_00, Amount: 65_00},
+	}}
+
+	if err := ExportLedgerToCsv(ledger, filepath.Join(dir, "ledger.csv")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "ledger.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "CardID,Period,Key,Base,Amount\n1,2020-09,5411,130000,6500\n"; string(data) != want {
+		t.Errorf("ExportLedgerToCsv() got = %q, want %q", data, want)
+	}
+
+	if err = ExportLedgerToJson(ledger, filepath.Join(dir, "ledger.json")); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(filepath.Join(dir, "ledger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Ledger
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, ledger) {
+		t.Errorf("ExportLedgerToJson() got = %v, want %v", decoded, ledger)
+	}
+
+	if err = ExportLedgerToXml(ledger, filepath.Join(dir, "ledger.xml")); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(filepath.Join(dir, "ledger.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<accrual>") || !strings.Contains(string(data), "<amount>6500</amount>") {
+		t.Errorf("ExportLedgerToXml() got = %s", data)
+	}
+}
@@ -0,0 +1,93 @@
+package card
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestContext_Canceled(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "export.json")
+	source := &Card{}
+	source.AddTransaction(Transaction{Id: "0001", Bill: 100_00, Time: 1606192422, MCC: "5411", Status: "Done"})
+	if err := ExporterToJson(source, fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "MakeTransactionsContext",
+			call: func() error { return (&Card{}).MakeTransactionsContext(ctx, 10) },
+		},
+		{
+			name: "MapRowToTransactionContext",
+			call: func() error {
+				return (&Card{}).MapRowToTransactionContext(ctx, [][]string{{"0001", "100", "1606192422", "5411", "Done"}})
+			},
+		},
+		{
+			name: "ImporterFromJsonContext",
+			call: func() error { return ImporterFromJsonContext(ctx, &Card{}, fileName) },
+		},
+		{
+			name: "ImporterFromXmlContext",
+			call: func() error { return ImporterFromXmlContext(ctx, &Card{}, fileName) },
+		},
+		{
+			name: "ExporterToJsonContext",
+			call: func() error { return ExporterToJsonContext(ctx, source, filepath.Join(dir, "canceled.json")) },
+		},
+		{
+			name: "SumCategoryTransactionsContext",
+			call: func() error {
+				_, err := SumCategoryTransactionsContext(ctx, source.Transactions.Transactions, 2)
+				return err
+			},
+		},
+		{
+			name: "RefundContext",
+			call: func() error {
+				_, err := newRefundService().RefundContext(ctx, "0001", 100_00)
+				return err
+			},
+		},
+		{
+			name: "AnalyticsContext",
+			call: func() error {
+				_, err := newAnalyticsService().AnalyticsContext(ctx, 1, AnalyticsQuery{Period: PeriodDay, GroupBy: GroupByMCC})
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err != context.Canceled {
+				t.Errorf("%s() error = %v, wantErr %v", tt.name, err, context.Canceled)
+			}
+		})
+	}
+}
+
+func TestImporterFromJsonContext(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "export.json")
+	source := &Card{}
+	source.AddTransaction(Transaction{Id: "0001", Bill: 100_00, Time: 1606192422, MCC: "5411", Status: "Done"})
+	if err := ExporterToJsonContext(context.Background(), source, fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &Card{}
+	if err := ImporterFromJsonContext(context.Background(), imported, fileName); err != nil {
+		t.Fatal(err)
+	}
+	if len(imported.Transactions.Transactions) != 1 || imported.Transactions.Transactions[0] != source.Transactions.Transactions[0] {
+		t.Errorf("ImporterFromJsonContext() got = %v", imported.Transactions.Transactions)
+	}
+}
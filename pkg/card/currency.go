@@ -0,0 +1,182 @@
+package card
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrRateNotFound   = errors.New("exchange rate not found")
+	ErrInvalidRateRow = errors.New("invalid exchange rate row")
+)
+
+// Источник курсов валют
+type RateProvider interface {
+	// Курс, по которому единица from обменивается на to в момент at
+	Rate(from, to string, at time.Time) (float64, error)
+}
+
+// Курс валюты, действующий с даты
+type datedRate struct {
+	from time.Time
+	rate float64
+}
+
+type currencyPair struct {
+	from string
+	to   string
+}
+
+// Источник курсов, загружаемый из csv файла формата date,from,to,rate.
+// Курс действует с указанной даты до даты следующего курса той же пары
+type FileRateProvider struct {
+	rates map[currencyPair][]datedRate
+}
+
+// Конструктор источника курсов из файла
+func NewFileRateProvider(fileName string) (*FileRateProvider, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer func(c io.Closer) {
+		if cerr := c.Close(); cerr != nil {
+			log.Println("Cannot close file", cerr)
+		}
+	}(file)
+
+	p := &FileRateProvider{rates: make(map[currencyPair][]datedRate)}
+	err = p.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Метод загрузки курсов в формате csv: date,from,to,rate, дата в формате 2006-01-02
+func (p *FileRateProvider) Load(reader io.Reader) error {
+	if p.rates == nil {
+		p.rates = make(map[currencyPair][]datedRate)
+	}
+
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if len(record) != 4 {
+			return ErrInvalidRateRow
+		}
+		if record[0] == "date" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", record[0])
+		if err != nil {
+			return err
+		}
+		rate, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			return err
+		}
+		if rate <= 0 {
+			return ErrInvalidRateRow
+		}
+		pair := currencyPair{from: record[1], to: record[2]}
+		p.rates[pair] = append(p.rates[pair], datedRate{from: date, rate: rate})
+	}
+
+	for _, rates := range p.rates {
+		sort.Slice(rates, func(i, j int) bool {
+			return rates[i].from.Before(rates[j].from)
+		})
+	}
+
+	return nil
+}
+
+// Метод получения курса на момент at. Если прямой пары нет, используется обратный курс
+func (p *FileRateProvider) Rate(from, to string, at time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	if rate, ok := p.find(currencyPair{from: from, to: to}, at); ok {
+		return rate, nil
+	}
+	if rate, ok := p.find(currencyPair{from: to, to: from}, at); ok {
+		return 1 / rate, nil
+	}
+	return 0, ErrRateNotFound
+}
+
+func (p *FileRateProvider) find(pair currencyPair, at time.Time) (float64, bool) {
+	rates := p.rates[pair]
+	i := sort.Search(len(rates), func(i int) bool {
+		return rates[i].from.After(at)
+	})
+	if i == 0 {
+		return 0, false
+	}
+	return rates[i-1].rate, true
+}
+
+// Функция конвертации суммы в копейках по курсу на момент at
+func Convert(rates RateProvider, amount int64, from, to string, at time.Time) (int64, error) {
+	if from == to {
+		return amount, nil
+	}
+	if rates == nil {
+		return 0, ErrRateNotFound
+	}
+	rate, err := rates.Rate(from, to, at)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(float64(amount) * rate)), nil
+}
+
+// Метод получения суммы транзакции в валюте карты по курсу на время транзакции.
+// Транзакция без валюты считается проведенной в валюте карты
+func (c *Card) BillInCardCurrency(rates RateProvider, t Transaction) (int64, error) {
+	if t.Currency == "" {
+		return t.Bill, nil
+	}
+	return Convert(rates, t.Bill, t.Currency, c.Currency, time.Unix(t.Time, 0))
+}
+
+// Метод получения транзакций карты с суммами, пересчитанными в валюту карты
+func (c *Card) ConvertTransactions(rates RateProvider) ([]Transaction, error) {
+	converted := make([]Transaction, len(c.Transactions.Transactions))
+	for i, t := range c.Transactions.Transactions {
+		bill, err := c.BillInCardCurrency(rates, t)
+		if err != nil {
+			return nil, err
+		}
+		t.Bill = bill
+		t.Currency = c.Currency
+		converted[i] = t
+	}
+	return converted, nil
+}
+
+// Функция сложения сумм транзакций карты по категориям в валюте карты
+func SumCategoryTransactionsConverted(
+	ctx context.Context,
+	c *Card,
+	rates RateProvider,
+	goroutines int,
+) (map[string]int64, error) {
+	transactions, err := c.ConvertTransactions(rates)
+	if err != nil {
+		return nil, err
+	}
+	return SumCategoryTransactionsContext(ctx, transactions, goroutines)
+}
@@ -0,0 +1,139 @@
+package card
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRates = `date,from,to,rate
+2020-09-01,USD,RUB,74.5
+2020-09-15,USD,RUB,75.5
+2020-09-01,EUR,RUB,88
+`
+
+func newTestRates(t *testing.T) *FileRateProvider {
+	rates := &FileRateProvider{}
+	if err := rates.Load(strings.NewReader(testRates)); err != nil {
+		t.Fatal(err)
+	}
+	return rates
+}
+
+func TestFileRateProvider_Rate(t *testing.T) {
+	type args struct {
+		from string
+		to   string
+		at   time.Time
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    float64
+		wantErr error
+	}{
+		{
+			name: "Rate at date",
+			args: args{from: "USD", to: "RUB", at: time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC)},
+			want: 74.5,
+		},
+		{
+			name: "Newer rate",
+			args: args{from: "USD", to: "RUB", at: time.Date(2020, 9, 15, 0, 0, 0, 0, time.UTC)},
+			want: 75.5,
+		},
+		{
+			name: "Inverse rate",
+			args: args{from: "RUB", to: "EUR", at: time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC)},
+			want: 1 / 88.0,
+		},
+		{
+			name: "Same currency",
+			args: args{from: "RUB", to: "RUB", at: time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC)},
+			want: 1,
+		},
+		{
+			name:    "Before first rate",
+			args:    args{from: "USD", to: "RUB", at: time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC)},
+			wantErr: ErrRateNotFound,
+		},
+		{
+			name:    "Unknown pair",
+			args:    args{from: "USD", to: "EUR", at: time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC)},
+			wantErr: ErrRateNotFound,
+		},
+	}
+	rates := newTestRates(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rates.Rate(tt.args.from, tt.args.to, tt.args.at)
+			if err != tt.wantErr {
+				t.Errorf("Rate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Rate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSumCategoryTransactionsConverted(t *testing.T) {
+	c := &Card{Currency: "RUB"}
+	c.AddTransaction(Transaction{Id: "0001", Bill: 100_00, Time: time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC).Unix(), MCC: "5411", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "0002", Bill: 10_00, Time: time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC).Unix(), MCC: "5411", Status: StatusDone, Currency: "USD"})
+	c.AddTransaction(Transaction{Id: "0003", Bill: 10_00, Time: time.Date(2020, 9, 20, 0, 0, 0, 0, time.UTC).Unix(), MCC: "5812", Status: StatusDone, Currency: "USD"})
+
+	got, err := SumCategoryTransactionsConverted(context.Background(), c, newTestRates(t), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"5411": 845_00, "5812": 755_00}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SumCategoryTransactionsConverted() got = %v, want %v", got, want)
+	}
+
+	if _, err = SumCategoryTransactionsConverted(context.Background(), c, nil, 2); err != ErrRateNotFound {
+		t.Errorf("SumCategoryTransactionsConverted() error = %v, wantErr %v", err, ErrRateNotFound)
+	}
+}
+
+func TestService_PurchaseInCurrency(t *testing.T) {
+	s := New("Test Bank")
+	s.Rates = staticRates{"USD": 75}
+	s.CardIssue(1, "User", "User", "Visa", 10000_00, "RUB", "5106 2100 0000 0001")
+
+	got, err := s.PurchaseInCurrency(1, 10_00, "USD", "5411")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Bill != 10_00 || got.Currency != "USD" {
+		t.Errorf("PurchaseInCurrency() got = %+v", got)
+	}
+	if balance := s.cards[0].Balance; balance != 9250_00 {
+		t.Errorf("PurchaseInCurrency() balance = %v, want %v", balance, 9250_00)
+	}
+
+	if _, err = s.Refund(got.Id, 4_00); err != nil {
+		t.Fatal(err)
+	}
+	if balance := s.cards[0].Balance; balance != 9550_00 {
+		t.Errorf("Refund() balance = %v, want %v", balance, 9550_00)
+	}
+}
+
+// Курсы к рублю, не зависящие от времени
+type staticRates map[string]float64
+
+func (r staticRates) Rate(from, to string, at time.Time) (float64, error) {
+	if to != "RUB" {
+		return 0, ErrRateNotFound
+	}
+	rate, ok := r[from]
+	if !ok {
+		return 0, ErrRateNotFound
+	}
+	return rate, nil
+}
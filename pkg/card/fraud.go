@@ -0,0 +1,252 @@
+package card
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Названия правил поиска подозрительных операций
+const (
+	RuleVelocity    = "velocity"     // Слишком много операций за короткое время
+	RuleAmountSpike = "amount_spike" // Сумма намного больше обычной для категории
+	RuleNewMCC      = "new_mcc"      // Первая операция в категории
+	RuleNightTime   = "night_time"   // Операция ночью
+)
+
+// Правило поиска подозрительных операций.
+// Check получает историю карты до новой транзакции и возвращает причину срабатывания
+type FraudRule interface {
+	Name() string
+	Check(history []Transaction, t Transaction) (reason string, hit bool)
+}
+
+// Правило частоты: Count и больше операций, включая новую, за Window
+type VelocityRule struct {
+	Count  int
+	Window time.Duration
+}
+
+func (r VelocityRule) Name() string {
+	return RuleVelocity
+}
+
+func (r VelocityRule) Check(history []Transaction, t Transaction) (string, bool) {
+	from := t.Time - int64(r.Window/time.Second)
+	count := 1
+	for _, h := range history {
+		if h.IsPurchase() && h.Time > from && h.Time <= t.Time {
+			count++
+		}
+	}
+	if count < r.Count {
+		return "", false
+	}
+	return fmt.Sprintf("%d transactions in %v", count, r.Window), true
+}
+
+// Правило всплеска суммы: сумма больше средней по категории в Factor раз.
+// Сравниваются только операции в той же валюте; без MinHistory операций в категории правило не срабатывает
+type AmountSpikeRule struct {
+	Factor     float64
+	MinHistory int
+}
+
+func (r AmountSpikeRule) Name() string {
+	return RuleAmountSpike
+}
+
+func (r AmountSpikeRule) Check(history []Transaction, t Transaction) (string, bool) {
+	var total int64
+	var count int
+	for _, h := range history {
+		if h.IsPurchase() && h.Status != StatusDeclined && h.MCC == t.MCC && h.Currency == t.Currency {
+			total += h.Bill
+			count++
+		}
+	}
+	if count == 0 || count < r.MinHistory {
+		return "", false
+	}
+	average := float64(total) / float64(count)
+	if float64(t.Bill) <= average*r.Factor {
+		return "", false
+	}
+	return fmt.Sprintf("bill %d is %.1f times the category average %.0f", t.Bill, float64(t.Bill)/average, average), true
+}
+
+// Правило новой категории: операция в категории, которой не было в истории.
+// Для карт с историей меньше MinHistory операций правило не срабатывает
+type NewMCCRule struct {
+	MinHistory int
+}
+
+func (r NewMCCRule) Name() string {
+	return RuleNewMCC
+}
+
+func (r NewMCCRule) Check(history []Transaction, t Transaction) (string, bool) {
+	if len(history) < r.MinHistory {
+		return "", false
+	}
+	for _, h := range history {
+		if h.MCC == t.MCC {
+			return "", false
+		}
+	}
+	return fmt.Sprintf("first transaction in category %s", t.MCC), true
+}
+
+// Правило ночной операции: время операции с From часов до To часов в Location.
+// Пустая Location означает UTC
+type NightTimeRule struct {
+	From     int
+	To       int
+	Location *time.Location
+}
+
+func (r NightTimeRule) Name() string {
+	return RuleNightTime
+}
+
+func (r NightTimeRule) Check(history []Transaction, t Transaction) (string, bool) {
+	location := r.Location
+	if location == nil {
+		location = time.UTC
+	}
+	at := time.Unix(t.Time, 0).In(location)
+	hour := at.Hour()
+
+	night := hour >= r.From && hour < r.To
+	// Интервал через полночь, например, с 23 до 6
+	if r.From > r.To {
+		night = hour >= r.From || hour < r.To
+	}
+	if !night {
+		return "", false
+	}
+	return fmt.Sprintf("transaction at %s", at.Format("15:04")), true
+}
+
+// Функция получения набора правил по умолчанию
+func DefaultFraudRules() []FraudRule {
+	return []FraudRule{
+		VelocityRule{Count: 5, Window: 10 * time.Minute},
+		AmountSpikeRule{Factor: 5, MinHistory: 3},
+		NewMCCRule{MinHistory: 10},
+		NightTimeRule{From: 0, To: 6},
+	}
+}
+
+// Срабатывание правила на транзакции
+type Alert struct {
+	Id            string `json:"id"`
+	CardId        CardId `json:"card_id"`
+	TransactionId string `json:"transaction_id"`
+	Rule          string `json:"rule"`
+	Reason        string `json:"reason"`
+	Time          int64  `json:"time"` // Время транзакции
+}
+
+// Движок проверки транзакций по набору правил
+type FraudEngine struct {
+	Rules []FraudRule
+}
+
+// Конструктор движка проверки транзакций
+func NewFraudEngine(rules ...FraudRule) *FraudEngine {
+	return &FraudEngine{Rules: rules}
+}
+
+// Метод проверки новой транзакции по истории карты.
+// Возвраты, отмены и входящие переводы не проверяются
+func (e *FraudEngine) Evaluate(c *Card, t Transaction) []Alert {
+	if e == nil || t.Bill <= 0 || !(t.IsPurchase() || t.Type == TypeTransfer) {
+		return nil
+	}
+
+	var alerts []Alert
+	for _, rule := range e.Rules {
+		reason, hit := rule.Check(c.Transactions.Transactions, t)
+		if !hit {
+			continue
+		}
+		alerts = append(alerts, Alert{
+			CardId:        c.Id,
+			TransactionId: t.Id,
+			Rule:          rule.Name(),
+			Reason:        reason,
+			Time:          t.Time,
+		})
+	}
+	return alerts
+}
+
+// Условия выборки срабатываний. Пустые поля не ограничивают выборку
+type AlertQuery struct {
+	CardId CardId
+	Rule   string
+	From   time.Time
+	To     time.Time // Не включается в выборку
+}
+
+// Метод проверки новой транзакции и сохранения срабатываний. Вызывается под s.mu, когда статус
+// транзакции уже известен, но до ее добавления на карту. Отклоненные транзакции не проверяются
+func (s *Service) checkFraud(c *Card, t Transaction) {
+	if t.Status == StatusDeclined {
+		return
+	}
+	for _, alert := range s.Fraud.Evaluate(c, t) {
+		alert.Id = s.newAlertId()
+		s.alerts = append(s.alerts, alert)
+	}
+}
+
+// Метод получения срабатываний правил в порядке их появления
+func (s *Service) Alerts(q AlertQuery) []Alert {
+	alerts, _ := s.AlertsContext(context.Background(), q)
+	return alerts
+}
+
+// Метод получения срабатываний правил по картам, которые разрешено читать. Запрос по карте
+// без права ActionRead возвращает ошибку, без карты - срабатывания только по доступным картам
+func (s *Service) AlertsContext(ctx context.Context, q AlertQuery) ([]Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q.CardId != 0 {
+		if c, err := s.cardById(q.CardId); err == nil {
+			if err = s.authorize(ctx, ActionRead, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	readable := make(map[CardId]bool)
+	result := make([]Alert, 0)
+	for _, alert := range s.alerts {
+		if q.CardId != 0 && alert.CardId != q.CardId {
+			continue
+		}
+		allowed, ok := readable[alert.CardId]
+		if !ok {
+			c, err := s.cardById(alert.CardId)
+			allowed = err == nil && s.authorize(ctx, ActionRead, c) == nil
+			readable[alert.CardId] = allowed
+		}
+		if !allowed {
+			continue
+		}
+		if q.Rule != "" && alert.Rule != q.Rule {
+			continue
+		}
+		if !q.From.IsZero() && alert.Time < q.From.Unix() {
+			continue
+		}
+		if !q.To.IsZero() && alert.Time >= q.To.Unix() {
+			continue
+		}
+		result = append(result, alert)
+	}
+	return result, nil
+}
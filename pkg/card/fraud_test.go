@@ -0,0 +1,178 @@
+package card
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFraudRule_Check(t *testing.T) {
+	base := time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC).Unix()
+	history := []Transaction{
+		{Id: "0001", Bill: 100_00, Time: base - 3600, MCC: "5411", Status: StatusDone},
+		{Id: "0002", Bill: 120_00, Time: base - 300, MCC: "5411", Status: StatusDone},
+		{Id: "0003", Bill: 80_00, Time: base - 200, MCC: "5411", Status: StatusDone},
+		{Id: "0004", Bill: -80_00, Time: base - 100, MCC: "5411", Status: StatusDone, Type: TypeRefund, OriginalId: "0003"},
+	}
+	type args struct {
+		rule FraudRule
+		t    Transaction
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantHit bool
+	}{
+		{
+			name:    "Velocity hit",
+			args:    args{rule: VelocityRule{Count: 3, Window: 10 * time.Minute}, t: Transaction{Bill: 50_00, Time: base, MCC: "5411"}},
+			wantHit: true,
+		},
+		{
+			name:    "Velocity ignores refunds and old transactions",
+			args:    args{rule: VelocityRule{Count: 4, Window: 10 * time.Minute}, t: Transaction{Bill: 50_00, Time: base, MCC: "5411"}},
+			wantHit: false,
+		},
+		{
+			name:    "Amount spike hit",
+			args:    args{rule: AmountSpikeRule{Factor: 5, MinHistory: 3}, t: Transaction{Bill: 600_00, Time: base, MCC: "5411"}},
+			wantHit: true,
+		},
+		{
+			name:    "Amount spike below factor",
+			args:    args{rule: AmountSpikeRule{Factor: 5, MinHistory: 3}, t: Transaction{Bill: 400_00, Time: base, MCC: "5411"}},
+			wantHit: false,
+		},
+		{
+			name:    "Amount spike without history",
+			args:    args{rule: AmountSpikeRule{Factor: 5, MinHistory: 3}, t: Transaction{Bill: 600_00, Time: base, MCC: "5812"}},
+			wantHit: false,
+		},
+		{
+			name:    "New category",
+			args:    args{rule: NewMCCRule{MinHistory: 3}, t: Transaction{Bill: 50_00, Time: base, MCC: "5812"}},
+			wantHit: true,
+		},
+		{
+			name:    "Known category",
+			args:    args{rule: NewMCCRule{MinHistory: 3}, t: Transaction{Bill: 50_00, Time: base, MCC: "5411"}},
+			wantHit: false,
+		},
+		{
+			name:    "New category on short history",
+			args:    args{rule: NewMCCRule{MinHistory: 10}, t: Transaction{Bill: 50_00, Time: base, MCC: "5812"}},
+			wantHit: false,
+		},
+		{
+			name:    "Night over midnight",
+			args:    args{rule: NightTimeRule{From: 23, To: 6}, t: Transaction{Bill: 50_00, Time: base + 12*3600, MCC: "5411"}},
+			wantHit: true,
+		},
+		{
+			name:    "Night in location",
+			args:    args{rule: NightTimeRule{From: 0, To: 6, Location: time.FixedZone("UTC+9", 9*3600)}, t: Transaction{Bill: 50_00, Time: base + 4*3600, MCC: "5411"}},
+			wantHit: true,
+		},
+		{
+			name:    "Day time",
+			args:    args{rule: NightTimeRule{From: 0, To: 6}, t: Transaction{Bill: 50_00, Time: base, MCC: "5411"}},
+			wantHit: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, hit := tt.args.rule.Check(history, tt.args.t)
+			if hit != tt.wantHit {
+				t.Errorf("Check() got = %v (%v), want %v", hit, reason, tt.wantHit)
+			}
+			if hit && reason == "" {
+				t.Error("Check() got empty reason")
+			}
+		})
+	}
+}
+
+func TestService_Alerts(t *testing.T) {
+	now := time.Date(2020, 9, 10, 2, 0, 0, 0, time.UTC)
+	clock := NewManualClock(now)
+	s := New("Test Bank")
+	s.Clock = clock
+	s.IDs = NewSequenceGenerator("tx")
+	s.Fraud = NewFraudEngine(VelocityRule{Count: 3, Window: time.Hour}, NightTimeRule{From: 0, To: 6})
+	s.CardIssue(1, "User", "User", "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+	s.CardIssue(2, "User", "User", "Visa", 1000_00, "RUB", "5106 2100 0000 0002")
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.Purchase(1, 10_00, "5411"); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+	}
+	clock.Set(now.Add(10 * time.Hour))
+	if _, err := s.Transfer(2, 1, 10_00); err != nil {
+		t.Fatal(err)
+	}
+	refund, err := s.Refund("tx-000000000001", 10_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query AlertQuery
+		want  int
+	}{
+		{name: "All", query: AlertQuery{}, want: 4},
+		{name: "Card", query: AlertQuery{CardId: 2}, want: 0},
+		{name: "Rule", query: AlertQuery{Rule: RuleVelocity}, want: 1},
+		{name: "Range", query: AlertQuery{From: now.Add(time.Minute), To: now.Add(2 * time.Minute)}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Alerts(tt.query); len(got) != tt.want {
+				t.Errorf("Alerts() got = %+v, want %v alerts", got, tt.want)
+			}
+		})
+	}
+
+	for _, alert := range s.Alerts(AlertQuery{}) {
+		if alert.TransactionId == refund.Id {
+			t.Errorf("Alerts() got alert for refund %+v", alert)
+		}
+	}
+}
+
+func TestService_AlertsDeclined(t *testing.T) {
+	s := New("Test Bank")
+	s.Clock = NewManualClock(time.Date(2020, 9, 10, 2, 0, 0, 0, time.UTC))
+	s.IDs = NewSequenceGenerator("tx")
+	s.Fraud = NewFraudEngine(NightTimeRule{From: 0, To: 6})
+	s.CardIssue(1, "User", "User", "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+	if err := s.SetBudget(1, "5411", 50_00, BudgetDecline); err != nil {
+		t.Fatal(err)
+	}
+
+	// Отклоненная по лимиту покупка не проверяется правилами
+	if _, err := s.Purchase(1, 100_00, "5411"); err != ErrBudgetExceeded {
+		t.Fatalf("Purchase() error = %v, wantErr %v", err, ErrBudgetExceeded)
+	}
+	if got := s.Alerts(AlertQuery{}); len(got) != 0 {
+		t.Errorf("Alerts() got = %+v, want no alerts", got)
+	}
+
+	first, err := s.Purchase(1, 10_00, "5411")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Purchase(1, 10_00, "5411")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Идентификаторы срабатываний не расходуют последовательность транзакций
+	if first.Id != "tx-000000000002" || second.Id != "tx-000000000003" {
+		t.Errorf("Purchase() ids = %v, %v", first.Id, second.Id)
+	}
+	alerts := s.Alerts(AlertQuery{})
+	if len(alerts) != 2 || alerts[0].Id != "alert-000000000001" || alerts[1].Id != "alert-000000000002" {
+		t.Errorf("Alerts() got = %+v", alerts)
+	}
+}
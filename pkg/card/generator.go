@@ -0,0 +1,254 @@
+package card
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+var ErrInvalidGeneratorConfig = errors.New("invalid generator config")
+
+// Распределение сумм транзакций в копейках
+type AmountDistribution interface {
+	Sample(r *rand.Rand) int64
+}
+
+// Равномерное распределение сумм от Min до Max включительно
+type UniformAmount struct {
+	Min int64
+	Max int64
+}
+
+func (d UniformAmount) Sample(r *rand.Rand) int64 {
+	return d.Min + r.Int63n(d.Max-d.Min+1)
+}
+
+// Логнормальное распределение сумм: большинство покупок около медианы и редкие крупные.
+// Результат ограничивается диапазоном от Min до Max
+type LogNormalAmount struct {
+	Median int64
+	Sigma  float64
+	Min    int64
+	Max    int64
+}
+
+func (d LogNormalAmount) Sample(r *rand.Rand) int64 {
+	amount := int64(float64(d.Median) * math.Exp(r.NormFloat64()*d.Sigma))
+	if amount < d.Min {
+		return d.Min
+	}
+	if amount > d.Max {
+		return d.Max
+	}
+	return amount
+}
+
+// Категория с весом в общем потоке транзакций и собственным распределением сумм
+type WeightedMCC struct {
+	Code   string
+	Weight float64
+	Amount AmountDistribution // Пустое распределение - используется GeneratorConfig.Amount
+}
+
+// Статус транзакции с весом
+type WeightedStatus struct {
+	Status string
+	Weight float64
+}
+
+// Настройки генератора транзакций
+type GeneratorConfig struct {
+	Seed        int64 // Одинаковый Seed с одинаковыми настройками дает одинаковые данные
+	From        time.Time
+	To          time.Time
+	MCC         []WeightedMCC
+	Amount      AmountDistribution // Распределение сумм по умолчанию
+	HourWeights [24]float64        // Вес каждого часа суток по UTC
+	Statuses    []WeightedStatus
+	IDs         IDGenerator // Пустой генератор - последовательные идентификаторы с префиксом "gen"
+}
+
+// Функция получения настроек, похожих на траты частного клиента за последние 90 дней до to
+func DefaultGeneratorConfig(seed int64, to time.Time) GeneratorConfig {
+	return GeneratorConfig{
+		Seed: seed,
+		From: to.AddDate(0, 0, -90),
+		To:   to,
+		MCC: []WeightedMCC{
+			{Code: "5411", Weight: 30, Amount: LogNormalAmount{Median: 800_00, Sigma: 0.8, Min: 50_00, Max: 20_000_00}},
+			{Code: "5499", Weight: 8, Amount: LogNormalAmount{Median: 300_00, Sigma: 0.6, Min: 30_00, Max: 5_000_00}},
+			{Code: "5812", Weight: 12, Amount: LogNormalAmount{Median: 1_500_00, Sigma: 0.6, Min: 200_00, Max: 30_000_00}},
+			{Code: "5814", Weight: 12, Amount: LogNormalAmount{Median: 400_00, Sigma: 0.4, Min: 100_00, Max: 3_000_00}},
+			{Code: "4111", Weight: 10, Amount: UniformAmount{Min: 46_00, Max: 62_00}},
+			{Code: "4121", Weight: 6, Amount: LogNormalAmount{Median: 450_00, Sigma: 0.5, Min: 150_00, Max: 5_000_00}},
+			{Code: "5541", Weight: 6, Amount: LogNormalAmount{Median: 2_500_00, Sigma: 0.4, Min: 500_00, Max: 10_000_00}},
+			{Code: "5912", Weight: 5, Amount: LogNormalAmount{Median: 700_00, Sigma: 0.7, Min: 50_00, Max: 15_000_00}},
+			{Code: "5651", Weight: 4, Amount: LogNormalAmount{Median: 3_500_00, Sigma: 0.7, Min: 500_00, Max: 60_000_00}},
+			{Code: "5732", Weight: 2, Amount: LogNormalAmount{Median: 12_000_00, Sigma: 1, Min: 1_000_00, Max: 300_000_00}},
+			{Code: "5815", Weight: 3, Amount: UniformAmount{Min: 99_00, Max: 999_00}},
+			{Code: "4814", Weight: 2, Amount: UniformAmount{Min: 300_00, Max: 1_500_00}},
+		},
+		Amount: LogNormalAmount{Median: 1_000_00, Sigma: 0.8, Min: 10_00, Max: 100_000_00},
+		HourWeights: [24]float64{
+			1, 0.5, 0.3, 0.2, 0.2, 0.5, 2, 5, 8, 7, 6, 7,
+			10, 10, 7, 6, 7, 9, 12, 12, 10, 7, 4, 2,
+		},
+		Statuses: []WeightedStatus{
+			{Status: StatusDone, Weight: 97},
+			{Status: StatusDeclined, Weight: 3},
+		},
+	}
+}
+
+// Генератор синтетических транзакций. Не безопасен для использования из нескольких горутин
+type Generator struct {
+	config    GeneratorConfig
+	rnd       *rand.Rand
+	mcc       []float64 // Накопленные веса категорий
+	hours     []float64
+	statuses  []float64
+	ids       IDGenerator
+	timeRange int64
+}
+
+// Конструктор генератора с проверкой настроек
+func NewGenerator(config GeneratorConfig) (*Generator, error) {
+	if config.To.Sub(config.From) < time.Second || len(config.MCC) == 0 || len(config.Statuses) == 0 {
+		return nil, ErrInvalidGeneratorConfig
+	}
+	if !validAmount(config.Amount) {
+		return nil, ErrInvalidGeneratorConfig
+	}
+
+	mccWeights := make([]float64, len(config.MCC))
+	for i, m := range config.MCC {
+		if m.Amount == nil && config.Amount == nil || !validAmount(m.Amount) {
+			return nil, ErrInvalidGeneratorConfig
+		}
+		mccWeights[i] = m.Weight
+	}
+	statusWeights := make([]float64, len(config.Statuses))
+	for i, s := range config.Statuses {
+		statusWeights[i] = s.Weight
+	}
+
+	mcc, ok := cumulativeWeights(mccWeights)
+	if !ok {
+		return nil, ErrInvalidGeneratorConfig
+	}
+	statuses, ok := cumulativeWeights(statusWeights)
+	if !ok {
+		return nil, ErrInvalidGeneratorConfig
+	}
+	// Без весов по часам время распределено равномерно
+	hourWeights := config.HourWeights[:]
+	if config.HourWeights == [24]float64{} {
+		hourWeights = make([]float64, 24)
+		for i := range hourWeights {
+			hourWeights[i] = 1
+		}
+	}
+	hours, ok := cumulativeWeights(hourWeights)
+	if !ok {
+		return nil, ErrInvalidGeneratorConfig
+	}
+
+	ids := config.IDs
+	if ids == nil {
+		ids = NewSequenceGenerator("gen")
+	}
+
+	return &Generator{
+		config:    config,
+		rnd:       rand.New(rand.NewSource(config.Seed)),
+		mcc:       mcc,
+		hours:     hours,
+		statuses:  statuses,
+		ids:       ids,
+		timeRange: int64(config.To.Sub(config.From) / time.Second),
+	}, nil
+}
+
+// Функция проверки границ распределения сумм
+func validAmount(d AmountDistribution) bool {
+	switch d := d.(type) {
+	case UniformAmount:
+		return d.Min > 0 && d.Min <= d.Max
+	case LogNormalAmount:
+		return d.Median > 0 && d.Sigma >= 0 && d.Min > 0 && d.Min <= d.Max
+	}
+	return true
+}
+
+// Функция получения накопленных весов. Веса не могут быть отрицательными, а их сумма - нулевой
+func cumulativeWeights(weights []float64) ([]float64, bool) {
+	result := make([]float64, len(weights))
+	var total float64
+	for i, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, false
+		}
+		total += w
+		result[i] = total
+	}
+	return result, total > 0
+}
+
+// Метод выбора индекса по накопленным весам
+func (g *Generator) pick(cumulative []float64) int {
+	x := g.rnd.Float64() * cumulative[len(cumulative)-1]
+	i := sort.SearchFloat64s(cumulative, x)
+	// Значение, совпавшее с границей, относится к следующему элементу с ненулевым весом
+	for i < len(cumulative)-1 && cumulative[i] <= x {
+		i++
+	}
+	return i
+}
+
+// Метод получения следующей транзакции
+func (g *Generator) Next() Transaction {
+	m := g.config.MCC[g.pick(g.mcc)]
+	amount := m.Amount
+	if amount == nil {
+		amount = g.config.Amount
+	}
+
+	return Transaction{
+		Id:     g.ids.NewId(),
+		Bill:   amount.Sample(g.rnd),
+		Time:   g.nextTime().Unix(),
+		MCC:    m.Code,
+		Status: g.config.Statuses[g.pick(g.statuses)].Status,
+		Type:   TypePurchase,
+	}
+}
+
+// Метод выбора времени: день равномерно из диапазона, час по весам, минуты и секунды равномерно.
+// Для неполных дней на границах диапазона время выбирается заново
+func (g *Generator) nextTime() time.Time {
+	from := g.config.From.UTC()
+	for attempt := 0; attempt < 16; attempt++ {
+		day := from.Add(time.Duration(g.rnd.Int63n(g.timeRange)) * time.Second)
+		t := time.Date(day.Year(), day.Month(), day.Day(), g.pick(g.hours), g.rnd.Intn(60), g.rnd.Intn(60), 0, time.UTC)
+		if !t.Before(from) && t.Before(g.config.To) {
+			return t
+		}
+	}
+	return from.Add(time.Duration(g.rnd.Int63n(g.timeRange)) * time.Second)
+}
+
+// Метод генерации count транзакций в потоковую запись. Запись не закрывается
+func (g *Generator) Generate(ctx context.Context, count int, w TransactionWriter) error {
+	for i := 0; i < count; i++ {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := w.WriteTransaction(g.Next()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
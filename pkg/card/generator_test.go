@@ -0,0 +1,144 @@
+package card
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGenerator_Deterministic(t *testing.T) {
+	to := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	generate := func() []Transaction {
+		g, err := NewGenerator(DefaultGeneratorConfig(42, to))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &Card{}
+		if err := g.Generate(context.Background(), 1000, NewCardTransactionWriter(c)); err != nil {
+			t.Fatal(err)
+		}
+		return c.Transactions.Transactions
+	}
+
+	first, second := generate(), generate()
+	if !reflect.DeepEqual(first, second) {
+		t.Error("Generate() with the same seed got different transactions")
+	}
+}
+
+func TestGenerator_Next(t *testing.T) {
+	from := time.Date(2020, 9, 10, 15, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 9, 20, 15, 0, 0, 0, time.UTC)
+	var hours [24]float64
+	hours[9] = 1
+	hours[20] = 3
+	g, err := NewGenerator(GeneratorConfig{
+		Seed: 1,
+		From: from,
+		To:   to,
+		MCC: []WeightedMCC{
+			{Code: "5411", Weight: 1, Amount: UniformAmount{Min: 100_00, Max: 200_00}},
+			{Code: "5812", Weight: 0},
+			{Code: "5814", Weight: 3},
+		},
+		Amount:      LogNormalAmount{Median: 500_00, Sigma: 1, Min: 300_00, Max: 900_00},
+		HourWeights: hours,
+		Statuses:    []WeightedStatus{{Status: StatusDone, Weight: 1}, {Status: StatusDeclined, Weight: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 10_000; i++ {
+		tx := g.Next()
+		counts[tx.MCC]++
+		counts[tx.Status]++
+
+		at := time.Unix(tx.Time, 0).UTC()
+		if at.Before(from) || !at.Before(to) {
+			t.Fatalf("Next() got time %v out of range", at)
+		}
+		if at.Hour() != 9 && at.Hour() != 20 {
+			t.Fatalf("Next() got hour %v with zero weight", at.Hour())
+		}
+		switch tx.MCC {
+		case "5411":
+			if tx.Bill < 100_00 || tx.Bill > 200_00 {
+				t.Fatalf("Next() got bill %v for 5411", tx.Bill)
+			}
+		case "5814":
+			if tx.Bill < 300_00 || tx.Bill > 900_00 {
+				t.Fatalf("Next() got bill %v for 5814", tx.Bill)
+			}
+		default:
+			t.Fatalf("Next() got mcc %v with zero weight", tx.MCC)
+		}
+	}
+
+	if counts["5814"] < 2*counts["5411"] {
+		t.Errorf("Next() got 5411: %v, 5814: %v, want ratio about 1:3", counts["5411"], counts["5814"])
+	}
+	if counts[StatusDeclined] < 4000 || counts[StatusDone] < 4000 {
+		t.Errorf("Next() got done: %v, declined: %v, want about equal", counts[StatusDone], counts[StatusDeclined])
+	}
+}
+
+func TestNewGenerator_Invalid(t *testing.T) {
+	to := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		modify func(c *GeneratorConfig)
+	}{
+		{
+			name:   "Empty range",
+			modify: func(c *GeneratorConfig) { c.From = c.To },
+		},
+		{
+			name:   "No categories",
+			modify: func(c *GeneratorConfig) { c.MCC = nil },
+		},
+		{
+			name:   "Zero weights",
+			modify: func(c *GeneratorConfig) { c.Statuses = []WeightedStatus{{Status: StatusDone}} },
+		},
+		{
+			name:   "Negative weight",
+			modify: func(c *GeneratorConfig) { c.MCC[0].Weight = -1 },
+		},
+		{
+			name:   "Invalid amount",
+			modify: func(c *GeneratorConfig) { c.MCC[0].Amount = UniformAmount{Min: 10_00, Max: 1_00} },
+		},
+		{
+			name: "No amount",
+			modify: func(c *GeneratorConfig) {
+				c.Amount = nil
+				c.MCC[0].Amount = nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultGeneratorConfig(1, to)
+			tt.modify(&config)
+			if _, err := NewGenerator(config); err != ErrInvalidGeneratorConfig {
+				t.Errorf("NewGenerator() error = %v, wantErr %v", err, ErrInvalidGeneratorConfig)
+			}
+		})
+	}
+}
+
+func TestGenerator_Generate_Canceled(t *testing.T) {
+	g, err := NewGenerator(DefaultGeneratorConfig(1, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := g.Generate(ctx, 10, NewCardTransactionWriter(&Card{})); err != context.Canceled {
+		t.Errorf("Generate() error = %v, wantErr %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,156 @@
+package card
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Генератор уникальных идентификаторов транзакций.
+// Идентификаторы, выданные позже, больше при строковом сравнении
+type IDGenerator interface {
+	NewId() string
+}
+
+// Генератор последовательных идентификаторов, например, для тестов: prefix-000000000001, prefix-000000000002, ...
+type SequenceGenerator struct {
+	mu     sync.Mutex
+	prefix string
+	next   uint64
+}
+
+// Конструктор генератора последовательных идентификаторов
+func NewSequenceGenerator(prefix string) *SequenceGenerator {
+	return &SequenceGenerator{prefix: prefix, next: 1}
+}
+
+func (g *SequenceGenerator) NewId() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := fmt.Sprintf("%s-%012d", g.prefix, g.next)
+	g.next++
+	return id
+}
+
+// Алфавит Crockford base32, используемый в ULID
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Генератор ULID: 48 бит времени в миллисекундах и 80 бит случайности.
+// В пределах одной миллисекунды случайная часть увеличивается на единицу, поэтому порядок сохраняется
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	clock   Clock
+	entropy io.Reader
+	lastMs  uint64
+	last    [10]byte
+}
+
+// Конструктор генератора ULID со случайностью из crypto/rand
+func NewULIDGenerator(clock Clock) *ULIDGenerator {
+	return &ULIDGenerator{clock: clock, entropy: rand.Reader}
+}
+
+func (g *ULIDGenerator) NewId() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.clock.Now().UnixNano() / 1e6)
+	if ms > g.lastMs {
+		g.lastMs = ms
+		if _, err := io.ReadFull(g.entropy, g.last[:]); err != nil {
+			panic(err)
+		}
+	} else {
+		// Часы не ушли вперед: увеличиваем случайную часть предыдущего идентификатора
+		for i := len(g.last) - 1; i >= 0; i-- {
+			g.last[i]++
+			if g.last[i] != 0 {
+				break
+			}
+		}
+	}
+
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(g.lastMs>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(g.lastMs))
+	copy(id[6:], g.last[:])
+
+	return encodeCrockford(id)
+}
+
+// Функция кодирования 128 бит в 26 символов Crockford base32
+func encodeCrockford(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// Генератор UUID версии 7: время в миллисекундах в старших битах и 12-битный счетчик
+// в пределах миллисекунды, поэтому идентификаторы сортируемы
+type UUIDGenerator struct {
+	mu      sync.Mutex
+	clock   Clock
+	entropy io.Reader
+	lastMs  uint64
+	seq     uint16
+}
+
+// Конструктор генератора UUID версии 7 со случайностью из crypto/rand
+func NewUUIDGenerator(clock Clock) *UUIDGenerator {
+	return &UUIDGenerator{clock: clock, entropy: rand.Reader}
+}
+
+func (g *UUIDGenerator) NewId() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var random [10]byte
+	if _, err := io.ReadFull(g.entropy, random[:]); err != nil {
+		panic(err)
+	}
+
+	ms := uint64(g.clock.Now().UnixNano() / 1e6)
+	if ms > g.lastMs {
+		g.lastMs = ms
+		// Счетчик начинается с нижней половины диапазона, чтобы оставить запас для увеличения
+		g.seq = binary.BigEndian.Uint16(random[0:2]) & 0x7ff
+	} else {
+		g.seq++
+		// Счетчик переполнен: занимаем следующую миллисекунду
+		if g.seq > 0xfff {
+			g.lastMs++
+			g.seq = 0
+		}
+	}
+
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(g.lastMs>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(g.lastMs))
+	binary.BigEndian.PutUint16(id[6:8], 0x7000|g.seq)
+	copy(id[8:], random[2:])
+	id[8] = 0x80 | id[8]&0x3f
+
+	var out [36]byte
+	hex.Encode(out[0:8], id[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], id[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], id[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], id[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:36], id[10:16])
+
+	return string(out[:])
+}
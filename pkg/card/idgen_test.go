@@ -0,0 +1,118 @@
+package card
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSequenceGenerator_NewId(t *testing.T) {
+	g := NewSequenceGenerator("tx")
+	for _, want := range []string{"tx-000000000001", "tx-000000000002", "tx-000000000003"} {
+		if got := g.NewId(); got != want {
+			t.Errorf("NewId() got = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestIDGenerator_Sortable(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name   string
+		gen    IDGenerator
+		format *regexp.Regexp
+	}{
+		{
+			name:   "ULID",
+			gen:    NewULIDGenerator(clock),
+			format: regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`),
+		},
+		{
+			name:   "UUIDv7",
+			gen:    NewUUIDGenerator(clock),
+			format: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			prev := ""
+			for i := 0; i < 5000; i++ {
+				// Часть идентификаторов выдается в одну и ту же миллисекунду
+				if i%100 == 0 {
+					clock.Advance(time.Millisecond)
+				}
+				id := tt.gen.NewId()
+				if !tt.format.MatchString(id) {
+					t.Fatalf("NewId() got = %v, invalid format", id)
+				}
+				if seen[id] {
+					t.Fatalf("NewId() got duplicate %v", id)
+				}
+				if id <= prev {
+					t.Fatalf("NewId() got = %v, want greater than %v", id, prev)
+				}
+				seen[id] = true
+				prev = id
+			}
+		})
+	}
+}
+
+func TestService_MakeTransactions(t *testing.T) {
+	now := time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC)
+	s := New("Test Bank")
+	s.Clock = NewManualClock(now)
+	s.IDs = NewSequenceGenerator("tx")
+	s.CardIssue(1, "User", "User", "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+
+	if err := s.MakeTransactions(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MakeTransactions(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MakeTransactions(2, 1); err != ErrCardNotFound {
+		t.Errorf("MakeTransactions() error = %v, wantErr %v", err, ErrCardNotFound)
+	}
+
+	c, _ := s.cardById(1)
+	got := c.Transactions.Transactions
+	if len(got) != 8 {
+		t.Fatalf("MakeTransactions() got %v transactions, want 8", len(got))
+	}
+	if got[0].Id != "tx-000000000001" || got[7].Id != "tx-000000000008" {
+		t.Errorf("MakeTransactions() got ids %v..%v", got[0].Id, got[7].Id)
+	}
+	if got[3].Time != now.Unix() {
+		t.Errorf("MakeTransactions() got last time = %v, want %v", got[3].Time, now.Unix())
+	}
+	for i := 1; i < 4; i++ {
+		if got[i].Time <= got[i-1].Time {
+			t.Errorf("MakeTransactions() got unsorted times %v, %v", got[i-1].Time, got[i].Time)
+		}
+	}
+}
+
+func TestCard_MakeTransactions_UniqueIds(t *testing.T) {
+	first, second := &Card{Id: 1}, &Card{Id: 2}
+	if err := first.MakeTransactions(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.MakeTransactions(3); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range []*Card{first, second} {
+		for _, transaction := range c.Transactions.Transactions {
+			if seen[transaction.Id] {
+				t.Errorf("MakeTransactions() got duplicate id %v", transaction.Id)
+			}
+			seen[transaction.Id] = true
+		}
+	}
+	if len(seen) != 12 {
+		t.Errorf("MakeTransactions() got %v ids, want 12", len(seen))
+	}
+}
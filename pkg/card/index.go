@@ -0,0 +1,56 @@
+package card
+
+import "sort"
+
+// Функция сравнения транзакции с ключом (время, идентификатор)
+func compareKey(t Transaction, time int64, id string) int {
+	switch {
+	case t.Time < time:
+		return -1
+	case t.Time > time:
+		return 1
+	case t.Id < id:
+		return -1
+	case t.Id > id:
+		return 1
+	}
+	return 0
+}
+
+// Метод добавления в индекс последней транзакции карты.
+// Обычно транзакции приходят по возрастанию времени, и позиция просто дописывается в конец
+func (c *Card) indexLast() {
+	transactions := c.Transactions.Transactions
+	last := len(transactions) - 1
+	// Транзакции добавлялись в обход AddTransaction: индекс будет перестроен при чтении
+	if len(c.timeIndex) != last {
+		return
+	}
+
+	t := transactions[last]
+	i := sort.Search(len(c.timeIndex), func(i int) bool {
+		return compareKey(transactions[c.timeIndex[i]], t.Time, t.Id) > 0
+	})
+	c.timeIndex = append(c.timeIndex, 0)
+	copy(c.timeIndex[i+1:], c.timeIndex[i:])
+	c.timeIndex[i] = last
+}
+
+// Метод получения индекса транзакций по времени и идентификатору.
+// Индекс перестраивается, если транзакции добавлялись напрямую в Transactions
+func (c *Card) sortedIndex() []int {
+	transactions := c.Transactions.Transactions
+	if len(c.timeIndex) == len(transactions) {
+		return c.timeIndex
+	}
+
+	c.timeIndex = make([]int, len(transactions))
+	for i := range c.timeIndex {
+		c.timeIndex[i] = i
+	}
+	sort.SliceStable(c.timeIndex, func(i, j int) bool {
+		a, b := transactions[c.timeIndex[i]], transactions[c.timeIndex[j]]
+		return compareKey(a, b.Time, b.Id) < 0
+	})
+	return c.timeIndex
+}
@@ -0,0 +1,226 @@
+package card
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidMCCFile = errors.New("invalid mcc file")
+
+// Справочник кодов ISO 18245, встроенный в бинарный файл
+//
+//go:embed mcc.csv
+var mccData string
+
+// Описание кода категории продавца
+type MCC struct {
+	Code   string `json:"code" xml:"code"`
+	Group  string `json:"group" xml:"group"`     // Группа категорий по диапазону кодов ISO 18245
+	NameRu string `json:"name_ru" xml:"name_ru"` // Название на русском
+	NameEn string `json:"name_en" xml:"name_en"` // Название на английском
+}
+
+// Справочник кодов категорий продавцов
+type MCCRegistry struct {
+	mu     sync.RWMutex
+	byCode map[string]MCC
+	byName map[string][]string // Нормализованное название -> коды
+	byWord map[string][]string // Слово из названия -> коды
+}
+
+// Конструктор пустого справочника
+func NewMCCRegistry() *MCCRegistry {
+	return &MCCRegistry{
+		byCode: make(map[string]MCC),
+		byName: make(map[string][]string),
+		byWord: make(map[string][]string),
+	}
+}
+
+var (
+	defaultMCCRegistry     *MCCRegistry
+	defaultMCCRegistryOnce sync.Once
+)
+
+// Функция получения справочника, загруженного из встроенного набора данных.
+// Справочник загружается один раз при первом обращении
+func DefaultMCCRegistry() *MCCRegistry {
+	defaultMCCRegistryOnce.Do(func() {
+		defaultMCCRegistry = NewMCCRegistry()
+		if err := defaultMCCRegistry.Load(strings.NewReader(mccData)); err != nil {
+			log.Println("Cannot load mcc directory", err)
+		}
+	})
+	return defaultMCCRegistry
+}
+
+// Функция загрузки файла, переопределяющего записи встроенного справочника
+func LoadMCCOverrides(fileName string) error {
+	return DefaultMCCRegistry().LoadFile(fileName)
+}
+
+// Метод загрузки справочника из файла
+func (r *MCCRegistry) LoadFile(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer func(c io.Closer) {
+		if cerr := c.Close(); cerr != nil {
+			log.Println("Cannot close file", cerr)
+		}
+	}(file)
+
+	return r.Load(file)
+}
+
+// Метод загрузки справочника в формате csv: code,group,name_ru,name_en.
+// Уже существующие коды перезаписываются. Файл с ошибкой не меняет справочник
+func (r *MCCRegistry) Load(reader io.Reader) error {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]MCC, len(records))
+	for _, record := range records {
+		if len(record) != 4 || record[0] == "" {
+			return ErrInvalidMCCFile
+		}
+		if record[0] == "code" {
+			continue
+		}
+		loaded[record[0]] = MCC{Code: record[0], Group: record[1], NameRu: record[2], NameEn: record[3]}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for code, mcc := range loaded {
+		if old, ok := r.byCode[code]; ok {
+			r.unindex(old)
+		}
+		r.byCode[code] = mcc
+		r.index(mcc)
+	}
+
+	return nil
+}
+
+// Метод поиска категории по коду
+func (r *MCCRegistry) Lookup(code string) (MCC, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mcc, ok := r.byCode[code]
+	return mcc, ok
+}
+
+// Метод получения группы категорий по коду
+func (r *MCCRegistry) Group(code string) string {
+	mcc, ok := r.Lookup(code)
+	if !ok {
+		return ""
+	}
+	return mcc.Group
+}
+
+// Метод поиска категорий по названию на русском или английском.
+// Сначала ищется точное совпадение названия, затем категории, содержащие все слова запроса
+func (r *MCCRegistry) FindByName(name string) []MCC {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if codes := r.byName[normalizeMCCName(name)]; len(codes) != 0 {
+		return r.collect(codes)
+	}
+
+	words := splitMCCName(name)
+	if len(words) == 0 {
+		return nil
+	}
+
+	matches := make(map[string]int)
+	for _, word := range words {
+		for _, code := range r.byWord[word] {
+			matches[code]++
+		}
+	}
+
+	var codes []string
+	for code, count := range matches {
+		if count == len(words) {
+			codes = append(codes, code)
+		}
+	}
+
+	return r.collect(codes)
+}
+
+func (r *MCCRegistry) collect(codes []string) []MCC {
+	var result []MCC
+	for _, code := range codes {
+		result = append(result, r.byCode[code])
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Code < result[j].Code
+	})
+	return result
+}
+
+func (r *MCCRegistry) index(mcc MCC) {
+	for _, name := range []string{mcc.NameRu, mcc.NameEn} {
+		key := normalizeMCCName(name)
+		r.byName[key] = appendUnique(r.byName[key], mcc.Code)
+		for _, word := range splitMCCName(name) {
+			r.byWord[word] = appendUnique(r.byWord[word], mcc.Code)
+		}
+	}
+}
+
+func (r *MCCRegistry) unindex(mcc MCC) {
+	for _, name := range []string{mcc.NameRu, mcc.NameEn} {
+		key := normalizeMCCName(name)
+		r.byName[key] = removeValue(r.byName[key], mcc.Code)
+		for _, word := range splitMCCName(name) {
+			r.byWord[word] = removeValue(r.byWord[word], mcc.Code)
+		}
+	}
+}
+
+// Функция приведения названия к виду для поиска
+func normalizeMCCName(name string) string {
+	return strings.Join(splitMCCName(name), " ")
+}
+
+// Функция разбиения названия на слова в нижнем регистре
+func splitMCCName(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'а' && r <= 'я' || r == 'ё' || r >= '0' && r <= '9')
+	})
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func removeValue(values []string, value string) []string {
+	for i, v := range values {
+		if v == value {
+			return append(values[:i:i], values[i+1:]...)
+		}
+	}
+	return values
+}
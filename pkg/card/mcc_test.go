@@ -0,0 +1,94 @@
+package card
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranslateMCC(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{name: "Supermarkets", code: "5411", want: "Супермаркеты"},
+		{name: "Restaurants", code: "5812", want: "Рестораны"},
+		{name: "Unknown code", code: "0000", want: "Категория не указана"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TranslateMCC(tt.code); got != tt.want {
+				t.Errorf("TranslateMCC() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMCCRegistry_Lookup(t *testing.T) {
+	r := DefaultMCCRegistry()
+
+	got, ok := r.Lookup("5812")
+	want := MCC{Code: "5812", Group: "miscellaneous", NameRu: "Рестораны", NameEn: "Eating Places, Restaurants"}
+	if !ok || got != want {
+		t.Errorf("Lookup() got = %v, want %v", got, want)
+	}
+
+	if group := r.Group("4111"); group != "transportation" {
+		t.Errorf("Group() got = %v, want %v", group, "transportation")
+	}
+}
+
+func TestMCCRegistry_FindByName(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "Exact russian name", query: "рестораны", want: []string{"5812"}},
+		{name: "Exact english name", query: "Eating places, restaurants", want: []string{"5812"}},
+		{name: "Words", query: "digital goods", want: []string{"5815", "5816", "5817", "5818"}},
+		{name: "Not found", query: "космодром", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, mcc := range DefaultMCCRegistry().FindByName(tt.query) {
+				got = append(got, mcc.Code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindByName() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMCCRegistry_Load(t *testing.T) {
+	r := NewMCCRegistry()
+	if err := r.Load(strings.NewReader("5411,retail,Продукты,Groceries\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Load(strings.NewReader("5411,retail,Супермаркеты,Supermarkets\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.FindByName("продукты"); len(got) != 0 {
+		t.Errorf("FindByName() got = %v, want overridden name removed", got)
+	}
+	if got := r.FindByName("supermarkets"); len(got) != 1 || got[0].NameRu != "Супермаркеты" {
+		t.Errorf("FindByName() got = %v", got)
+	}
+
+	if err := r.Load(strings.NewReader("5411,retail\n")); err != ErrInvalidMCCFile {
+		t.Errorf("Load() error = %v, wantErr %v", err, ErrInvalidMCCFile)
+	}
+
+	// Ошибка в любой строке отменяет загрузку всего файла
+	err := r.Load(strings.NewReader("5411,retail,Продукты,Groceries\n,retail,Без кода,No code\n"))
+	if err != ErrInvalidMCCFile {
+		t.Errorf("Load() error = %v, wantErr %v", err, ErrInvalidMCCFile)
+	}
+	if got, _ := r.Lookup("5411"); got.NameRu != "Супермаркеты" {
+		t.Errorf("Lookup() after invalid file got = %v, want previous record", got)
+	}
+}
@@ -0,0 +1,272 @@
+package card
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrRecurringNotFound = errors.New("recurring payment not found")
+
+// Вид регулярной операции
+type RecurringKind string
+
+const (
+	RecurringCharge   RecurringKind = "charge"   // Регулярное списание, например, подписка
+	RecurringTransfer RecurringKind = "transfer" // Перевод по расписанию
+)
+
+// Регулярная операция
+type Recurring struct {
+	Id       string
+	Kind     RecurringKind
+	CardId   CardId // Карта списания
+	To       CardId // Карта получателя перевода
+	Amount   int64
+	MCC      string // Категория регулярного списания
+	Schedule Schedule
+	NextRun  time.Time // Нулевое время - запуск еще не запланирован
+	Attempts int       // Неудачные попытки текущего запуска из-за нехватки средств
+	Canceled bool
+}
+
+// Метод регистрации регулярного списания с карты
+func (s *Service) AddRecurringCharge(id CardId, amount int64, mcc string, schedule Schedule) (string, error) {
+	return s.AddRecurringChargeContext(context.Background(), id, amount, mcc, schedule)
+}
+
+// Метод регистрации регулярного списания с проверкой права ActionTransfer на карту списания
+func (s *Service) AddRecurringChargeContext(ctx context.Context, id CardId, amount int64, mcc string, schedule Schedule) (string, error) {
+	return s.addRecurring(ctx, &Recurring{
+		Kind:     RecurringCharge,
+		CardId:   id,
+		Amount:   amount,
+		MCC:      mcc,
+		Schedule: schedule,
+	})
+}
+
+// Метод регистрации перевода по расписанию
+func (s *Service) AddScheduledTransfer(from, to CardId, amount int64, schedule Schedule) (string, error) {
+	return s.AddScheduledTransferContext(context.Background(), from, to, amount, schedule)
+}
+
+// Метод регистрации перевода по расписанию с проверкой права ActionTransfer на карту отправителя
+func (s *Service) AddScheduledTransferContext(ctx context.Context, from, to CardId, amount int64, schedule Schedule) (string, error) {
+	if from == to {
+		return "", ErrInvalidTransfer
+	}
+	return s.addRecurring(ctx, &Recurring{
+		Kind:     RecurringTransfer,
+		CardId:   from,
+		To:       to,
+		Amount:   amount,
+		MCC:      transferMCC,
+		Schedule: schedule,
+	})
+}
+
+func (s *Service) addRecurring(ctx context.Context, r *Recurring) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+	if r.Schedule == nil {
+		return "", ErrInvalidSchedule
+	}
+	c, err := s.cardById(r.CardId)
+	if err != nil {
+		return "", err
+	}
+	if err = s.authorize(ctx, ActionTransfer, c); err != nil {
+		return "", err
+	}
+	if r.Kind == RecurringTransfer {
+		if _, err := s.cardById(r.To); err != nil {
+			return "", err
+		}
+	}
+
+	r.Id = fmt.Sprintf("recurring-%d", len(s.recurring)+1)
+	s.recurring = append(s.recurring, r)
+
+	return r.Id, nil
+}
+
+// Метод отмены регулярной операции
+func (s *Service) CancelRecurring(id string) error {
+	return s.CancelRecurringContext(context.Background(), id)
+}
+
+// Метод отмены регулярной операции с проверкой права ActionTransfer на карту списания
+func (s *Service) CancelRecurringContext(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.recurring {
+		if r.Id != id {
+			continue
+		}
+		c, err := s.cardById(r.CardId)
+		if err != nil {
+			return err
+		}
+		if err = s.authorize(ctx, ActionTransfer, c); err != nil {
+			return err
+		}
+		r.Canceled = true
+		return nil
+	}
+	return ErrRecurringNotFound
+}
+
+// Метод получения копии списка регулярных операций
+func (s *Service) RecurringPayments() []Recurring {
+	return s.RecurringPaymentsContext(context.Background())
+}
+
+// Метод получения регулярных операций по картам, которые разрешено читать
+func (s *Service) RecurringPaymentsContext(ctx context.Context) []Recurring {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Recurring, 0, len(s.recurring))
+	for _, r := range s.recurring {
+		if c, err := s.cardById(r.CardId); err == nil && s.authorize(ctx, ActionRead, c) == nil {
+			result = append(result, *r)
+		}
+	}
+	return result
+}
+
+// От имени планировщика выполняются регулярные операции. Право на них проверено при регистрации,
+// поэтому запуски не зависят от RequirePrincipal
+var schedulerPrincipal = Principal{Name: "scheduler", Role: RoleAdmin}
+
+// Планировщик регулярных операций
+type Scheduler struct {
+	svc           *Service
+	clock         Clock
+	Tick          time.Duration // Интервал проверки наступивших операций
+	RetryInterval time.Duration // Пауза перед повтором при нехватке средств
+	MaxRetries    int           // Количество повторов, после которого запуск отклоняется
+}
+
+// Конструктор планировщика
+func NewScheduler(svc *Service, clock Clock) *Scheduler {
+	return &Scheduler{
+		svc:           svc,
+		clock:         clock,
+		Tick:          time.Minute,
+		RetryInterval: time.Hour,
+		MaxRetries:    3,
+	}
+}
+
+// Метод запуска планировщика. Работает до отмены контекста
+func (sc *Scheduler) Run(ctx context.Context) error {
+	for {
+		sc.RunDue()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sc.clock.After(sc.Tick):
+		}
+	}
+}
+
+// Метод выполнения всех операций, время которых наступило.
+// Возвращает количество выполненных запусков, включая неудачные.
+// Операции, отмененные после выбора наступивших, не выполняются и не считаются
+func (sc *Scheduler) RunDue() int {
+	now := sc.clock.Now()
+
+	sc.svc.mu.Lock()
+	var due []*Recurring
+	for _, r := range sc.svc.recurring {
+		if r.Canceled {
+			continue
+		}
+		if r.NextRun.IsZero() {
+			// Первый запуск может прийтись ровно на текущий момент
+			r.NextRun = r.Schedule.Next(now.Add(-time.Nanosecond))
+		}
+		if !r.NextRun.IsZero() && !r.NextRun.After(now) {
+			due = append(due, r)
+		}
+	}
+	sc.svc.mu.Unlock()
+
+	executed := 0
+	for _, r := range due {
+		if sc.execute(r, now) {
+			executed++
+		}
+	}
+
+	return executed
+}
+
+// Метод выполнения одного запуска. При нехватке средств запуск повторяется через RetryInterval,
+// после MaxRetries повторов на карте сохраняется отклоненная транзакция.
+// Возвращает false, если операцию отменили или уже выполнили после выбора наступивших
+func (sc *Scheduler) execute(r *Recurring, now time.Time) bool {
+	sc.svc.mu.Lock()
+	defer sc.svc.mu.Unlock()
+
+	if r.Canceled || r.NextRun.IsZero() || r.NextRun.After(now) {
+		return false
+	}
+
+	ctx := WithPrincipal(context.Background(), schedulerPrincipal)
+	var err error
+	switch r.Kind {
+	case RecurringTransfer:
+		_, err = sc.svc.transfer(ctx, r.CardId, r.To, r.Amount)
+	default:
+		_, err = sc.svc.purchase(ctx, r.CardId, r.Amount, "", r.MCC)
+	}
+
+	if err == ErrInsufficientFunds && r.Attempts < sc.MaxRetries {
+		r.Attempts++
+		r.NextRun = now.Add(sc.RetryInterval)
+		return true
+	}
+
+	if err == ErrInsufficientFunds {
+		sc.decline(r, now)
+	} else if err != nil {
+		log.Printf("recurring %s failed: %v", r.Id, err)
+	}
+
+	r.Attempts = 0
+	r.NextRun = r.Schedule.Next(now)
+	return true
+}
+
+// Метод сохранения отклоненного запуска на карте списания
+func (sc *Scheduler) decline(r *Recurring, now time.Time) {
+	c, err := sc.svc.cardById(r.CardId)
+	if err != nil {
+		log.Printf("recurring %s failed: %v", r.Id, err)
+		return
+	}
+
+	txType := TypePurchase
+	if r.Kind == RecurringTransfer {
+		txType = TypeTransfer
+	}
+	c.AddTransaction(Transaction{
+		Id:     sc.svc.newTransactionId(),
+		Bill:   r.Amount,
+		Time:   now.Unix(),
+		MCC:    r.MCC,
+		Status: StatusDeclined,
+		Type:   txType,
+	})
+}
@@ -0,0 +1,146 @@
+package card
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScheduler_RunDue(t *testing.T) {
+	start := time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	s := New("Test Bank")
+	s.Clock = clock
+	s.CardIssue(1, "User", "User", "Visa", 500_00, "RUB", "5106 2100 0000 0001")
+	s.CardIssue(2, "User", "User", "Visa", 0, "RUB", "5106 2100 0000 0002")
+
+	charge, err := s.AddRecurringCharge(1, 299_00, "5815", IntervalSchedule{Start: start, Interval: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.AddScheduledTransfer(1, 2, 100_00, IntervalSchedule{Start: start.Add(time.Hour), Interval: 24 * time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	// Планировщик работает от своего имени, даже если вызовы без Principal запрещены
+	s.RequirePrincipal = true
+	admin := WithPrincipal(context.Background(), Principal{Name: "admin", Role: RoleAdmin})
+
+	sc := NewScheduler(s, clock)
+	sc.RetryInterval = time.Hour
+	sc.MaxRetries = 1
+
+	if got := sc.RunDue(); got != 1 {
+		t.Errorf("RunDue() got = %v, want %v", got, 1)
+	}
+	if balance := s.cards[0].Balance; balance != 201_00 {
+		t.Errorf("RunDue() balance = %v, want %v", balance, 201_00)
+	}
+
+	clock.Advance(time.Hour)
+	if got := sc.RunDue(); got != 1 {
+		t.Errorf("RunDue() got = %v, want %v", got, 1)
+	}
+	if s.cards[0].Balance != 101_00 || s.cards[1].Balance != 100_00 {
+		t.Errorf("RunDue() balances = %v, %v", s.cards[0].Balance, s.cards[1].Balance)
+	}
+
+	// На следующий день средств на подписку не хватает: одна повторная попытка, затем отказ
+	clock.Advance(23 * time.Hour)
+	sc.RunDue()
+	if attempts := s.RecurringPaymentsContext(admin)[0].Attempts; attempts != 1 {
+		t.Errorf("RunDue() attempts = %v, want %v", attempts, 1)
+	}
+	clock.Advance(time.Hour)
+	sc.RunDue()
+
+	var declined []Transaction
+	for _, transaction := range s.cards[0].Transactions.Transactions {
+		if transaction.Status == StatusDeclined {
+			declined = append(declined, transaction)
+		}
+	}
+	if len(declined) != 1 || declined[0].Bill != 299_00 || declined[0].Time != clock.Now().Unix() {
+		t.Errorf("RunDue() declined = %+v", declined)
+	}
+	recurring := s.RecurringPaymentsContext(admin)[0]
+	if recurring.Attempts != 0 || !recurring.NextRun.Equal(start.Add(48*time.Hour)) {
+		t.Errorf("RunDue() recurring = %+v", recurring)
+	}
+
+	if err = s.CancelRecurringContext(admin, charge); err != nil {
+		t.Fatal(err)
+	}
+	// Отмененное списание больше не запускается, перевод продолжает выполняться
+	clock.Advance(24 * time.Hour)
+	if got := sc.RunDue(); got != 1 {
+		t.Errorf("RunDue() got = %v, want %v", got, 1)
+	}
+}
+
+func TestScheduler_ExecuteCanceled(t *testing.T) {
+	start := time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	s := New("Test Bank")
+	s.Clock = clock
+	s.CardIssue(1, "User", "User", "Visa", 500_00, "RUB", "5106 2100 0000 0001")
+	charge, err := s.AddRecurringCharge(1, 299_00, "5815", IntervalSchedule{Start: start, Interval: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := NewScheduler(s, clock)
+
+	// Операцию отменяют после того, как RunDue выбрал ее среди наступивших, но до списания
+	r := s.recurring[0]
+	r.NextRun = start
+	if err = s.CancelRecurring(charge); err != nil {
+		t.Fatal(err)
+	}
+	if sc.execute(r, start) {
+		t.Error("execute() of canceled recurring got = true, want false")
+	}
+	if balance := s.cards[0].Balance; balance != 500_00 || len(s.cards[0].Transactions.Transactions) != 0 {
+		t.Errorf("execute() of canceled recurring balance = %v, transactions = %v", balance, s.cards[0].Transactions.Transactions)
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	start := time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	s := New("Test Bank")
+	s.Clock = clock
+	s.CardIssue(1, "User", "User", "Visa", 500_00, "RUB", "5106 2100 0000 0001")
+	if _, err := s.AddRecurringCharge(1, 100_00, "5815", IntervalSchedule{Start: start.Add(time.Minute), Interval: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewScheduler(s, clock).Run(ctx)
+	}()
+
+	waitFor(t, func() bool { return clock.Waiters() == 1 })
+	clock.Advance(time.Minute)
+	waitFor(t, func() bool {
+		return s.RecurringPayments()[0].NextRun.Equal(start.Add(time.Minute + time.Hour))
+	})
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run() error = %v, wantErr %v", err, context.Canceled)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,165 @@
+package card
+
+import "context"
+
+// Типы операций по карте
+const (
+	TypePurchase = "purchase" // Покупка
+	TypeRefund   = "refund"   // Частичный или полный возврат покупки
+	TypeReversal = "reversal" // Отмена авторизации
+)
+
+// Метод проверки, является ли транзакция покупкой.
+// Транзакции, созданные до появления типов операций, считаются покупками
+func (t Transaction) IsPurchase() bool {
+	return t.Type == "" || t.Type == TypePurchase
+}
+
+// Метод поиска транзакции по идентификатору среди всех карт сервиса
+func (s *Service) findTransaction(txId string) (*Card, int, error) {
+	for _, c := range s.cards {
+		for i := range c.Transactions.Transactions {
+			if c.Transactions.Transactions[i].Id == txId {
+				return c, i, nil
+			}
+		}
+	}
+	return nil, 0, ErrTransactionNotFound
+}
+
+// Функция поиска транзакций, связанных с исходной
+func linkedTransactions(transactions []Transaction, txId string, txType string) []Transaction {
+	var linked []Transaction
+	for _, t := range transactions {
+		if t.OriginalId == txId && t.Type == txType {
+			linked = append(linked, t)
+		}
+	}
+	return linked
+}
+
+// Метод частичного или полного возврата покупки.
+// Отклоненную покупку вернуть нельзя: деньги по ней не списывались.
+// Сумма всех возвратов по транзакции не может превышать сумму исходной покупки
+func (s *Service) Refund(txId string, amount int64) (Transaction, error) {
+	return s.RefundContext(context.Background(), txId, amount)
+}
+
+// Метод возврата покупки с возможностью отмены
+func (s *Service) RefundContext(ctx context.Context, txId string, amount int64) (Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Transaction{}, err
+	}
+	if amount <= 0 {
+		return Transaction{}, ErrInvalidAmount
+	}
+
+	c, i, err := s.findTransaction(txId)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if err = s.authorize(ctx, ActionRefund, c); err != nil {
+		return Transaction{}, err
+	}
+	original := c.Transactions.Transactions[i]
+
+	if !original.IsPurchase() {
+		return Transaction{}, ErrNotRefundable
+	}
+	if original.Status == StatusDeclined {
+		return Transaction{}, ErrNotRefundable
+	}
+	if original.Status == StatusReversed {
+		return Transaction{}, ErrAlreadyReversed
+	}
+
+	refunds := linkedTransactions(c.Transactions.Transactions, txId, TypeRefund)
+	var refunded int64
+	for _, r := range refunds {
+		refunded += -r.Bill
+	}
+	if refunded+amount > original.Bill {
+		return Transaction{}, ErrRefundExceeded
+	}
+
+	refund := Transaction{
+		Id:         s.newTransactionId(),
+		Bill:       -amount,
+		Time:       s.now().Unix(),
+		MCC:        original.MCC,
+		Status:     StatusDone,
+		Type:       TypeRefund,
+		OriginalId: txId,
+		Currency:   original.Currency,
+	}
+	bill, err := c.BillInCardCurrency(s.Rates, refund)
+	if err != nil {
+		return Transaction{}, err
+	}
+	c.AddTransaction(refund)
+	c.Balance -= int(bill)
+
+	return refund, nil
+}
+
+// Метод отмены авторизации покупки.
+// Отменить можно только проведенную покупку, по которой не было возвратов
+func (s *Service) Reverse(txId string) (Transaction, error) {
+	return s.ReverseContext(context.Background(), txId)
+}
+
+// Метод отмены авторизации покупки с возможностью отмены операции
+func (s *Service) ReverseContext(ctx context.Context, txId string) (Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Transaction{}, err
+	}
+	c, i, err := s.findTransaction(txId)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if err = s.authorize(ctx, ActionRefund, c); err != nil {
+		return Transaction{}, err
+	}
+	original := &c.Transactions.Transactions[i]
+
+	if !original.IsPurchase() {
+		return Transaction{}, ErrNotRefundable
+	}
+	if original.Status == StatusDeclined {
+		return Transaction{}, ErrNotRefundable
+	}
+	if original.Status == StatusReversed {
+		return Transaction{}, ErrAlreadyReversed
+	}
+	if len(linkedTransactions(c.Transactions.Transactions, txId, TypeRefund)) != 0 {
+		return Transaction{}, ErrAlreadyRefunded
+	}
+
+	// Отмена возвращает ровно ту сумму, что была списана, поэтому курс берется на время покупки
+	bill, err := c.BillInCardCurrency(s.Rates, *original)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	original.Status = StatusReversed
+	reversal := Transaction{
+		Id:         s.newTransactionId(),
+		Bill:       -original.Bill,
+		Time:       s.now().Unix(),
+		MCC:        original.MCC,
+		Status:     StatusDone,
+		Type:       TypeReversal,
+		OriginalId: txId,
+		Currency:   original.Currency,
+	}
+	c.Balance += int(bill)
+	c.AddTransaction(reversal)
+
+	return reversal, nil
+}
@@ -0,0 +1,216 @@
+package card
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func newRefundService() *Service {
+	s := New("Test Bank")
+	s.IDs = NewSequenceGenerator("tx")
+	c := s.issueCard(1, Owner{FirstName: "User", LastName: "User"}, "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+	c.AddTransaction(Transaction{Id: "0001", Bill: 300_00, Time: 1606192422, MCC: "5411", Status: "Done"})
+	c.AddTransaction(Transaction{Id: "0002", Bill: 200_00, Time: 1606192432, MCC: "5812", Status: "Done"})
+	return s
+}
+
+func TestService_Refund(t *testing.T) {
+	type args struct {
+		refunds []int64
+		txId    string
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantBalance int
+		wantErr     error
+	}{
+		{
+			name:        "Full refund",
+			args:        args{txId: "0001", refunds: []int64{300_00}},
+			wantBalance: 1300_00,
+			wantErr:     nil,
+		},
+		{
+			name:        "Partial refunds",
+			args:        args{txId: "0001", refunds: []int64{100_00, 200_00}},
+			wantBalance: 1300_00,
+			wantErr:     nil,
+		},
+		{
+			name:        "Cumulative refunds exceed bill",
+			args:        args{txId: "0001", refunds: []int64{200_00, 200_00}},
+			wantBalance: 1200_00,
+			wantErr:     ErrRefundExceeded,
+		},
+		{
+			name:        "Invalid amount",
+			args:        args{txId: "0001", refunds: []int64{0}},
+			wantBalance: 1000_00,
+			wantErr:     ErrInvalidAmount,
+		},
+		{
+			name:        "Unknown transaction",
+			args:        args{txId: "9999", refunds: []int64{100_00}},
+			wantBalance: 1000_00,
+			wantErr:     ErrTransactionNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRefundService()
+			var err error
+			for _, amount := range tt.args.refunds {
+				var refund Transaction
+				refund, err = s.Refund(tt.args.txId, amount)
+				if err != nil {
+					break
+				}
+				if refund.OriginalId != tt.args.txId || refund.Type != TypeRefund || refund.Bill != -amount {
+					t.Errorf("Refund() got = %+v", refund)
+				}
+			}
+			if err != tt.wantErr {
+				t.Errorf("Refund() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := s.cards[0].Balance; got != tt.wantBalance {
+				t.Errorf("Refund() balance = %v, want %v", got, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestService_Reverse(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(s *Service)
+		txId    string
+		wantErr error
+	}{
+		{
+			name:    "Reverse purchase",
+			prepare: func(s *Service) {},
+			txId:    "0002",
+			wantErr: nil,
+		},
+		{
+			name: "Reverse twice",
+			prepare: func(s *Service) {
+				_, _ = s.Reverse("0002")
+			},
+			txId:    "0002",
+			wantErr: ErrAlreadyReversed,
+		},
+		{
+			name: "Reverse refunded purchase",
+			prepare: func(s *Service) {
+				_, _ = s.Refund("0002", 100_00)
+			},
+			txId:    "0002",
+			wantErr: ErrAlreadyRefunded,
+		},
+		{
+			name: "Reverse refund",
+			prepare: func(s *Service) {
+				_, _ = s.Refund("0002", 100_00)
+			},
+			txId:    "tx-000000000001",
+			wantErr: ErrNotRefundable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRefundService()
+			tt.prepare(s)
+			reversal, err := s.Reverse(tt.txId)
+			if err != tt.wantErr {
+				t.Errorf("Reverse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if reversal.Bill != -200_00 || reversal.OriginalId != tt.txId {
+				t.Errorf("Reverse() got = %+v", reversal)
+			}
+			if status := s.cards[0].Transactions.Transactions[1].Status; status != StatusReversed {
+				t.Errorf("Reverse() original status = %v, want %v", status, StatusReversed)
+			}
+		})
+	}
+}
+
+func TestService_RefundDeclined(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(s *Service) (Transaction, error)
+	}{
+		{
+			name:   "Refund",
+			action: func(s *Service) (Transaction, error) { return s.Refund("0003", 100_00) },
+		},
+		{
+			name:   "Reverse",
+			action: func(s *Service) (Transaction, error) { return s.Reverse("0003") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRefundService()
+			s.cards[0].AddTransaction(Transaction{Id: "0003", Bill: 500_00, Time: 1606192442, MCC: "5411", Status: StatusDeclined})
+
+			if _, err := tt.action(s); err != ErrNotRefundable {
+				t.Errorf("%s() error = %v, wantErr %v", tt.name, err, ErrNotRefundable)
+			}
+			if got := s.cards[0].Balance; got != 1000_00 {
+				t.Errorf("%s() balance = %v, want %v", tt.name, got, 1000_00)
+			}
+			if got := len(s.cards[0].Transactions.Transactions); got != 3 {
+				t.Errorf("%s() transactions = %v, want %v", tt.name, got, 3)
+			}
+		})
+	}
+}
+
+func TestRefund_Export(t *testing.T) {
+	s := newRefundService()
+	if _, err := s.Refund("0001", 100_00); err != nil {
+		t.Fatal(err)
+	}
+	c := s.cards[0]
+
+	row := transactionToSlice(c.Transactions.Transactions[2])
+	if row[5] != TypeRefund || row[6] != "0001" {
+		t.Errorf("transactionToSlice() got = %v", row)
+	}
+
+	imported := Card{}
+	if err := imported.MapRowToTransaction([][]string{row}); err != nil {
+		t.Fatal(err)
+	}
+	if imported.Transactions.Transactions[0] != c.Transactions.Transactions[2] {
+		t.Errorf("MapRowToTransaction() got = %+v, want %+v", imported.Transactions.Transactions[0], c.Transactions.Transactions[2])
+	}
+
+	data, err := json.Marshal(c.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decodedJson Transactions
+	if err = json.Unmarshal(data, &decodedJson); err != nil {
+		t.Fatal(err)
+	}
+	if decodedJson.Transactions[2].OriginalId != "0001" {
+		t.Errorf("json export got = %+v", decodedJson.Transactions[2])
+	}
+
+	data, err = xml.Marshal(c.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<type>refund</type><original_id>0001</original_id>") {
+		t.Errorf("xml export got = %s", data)
+	}
+}
@@ -0,0 +1,168 @@
+package card
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Расписание регулярной операции
+type Schedule interface {
+	// Время следующего запуска строго после after. Нулевое время означает, что запусков больше не будет
+	Next(after time.Time) time.Time
+}
+
+// Расписание с фиксированным интервалом, начиная со Start
+type IntervalSchedule struct {
+	Start    time.Time
+	Interval time.Duration
+}
+
+func (s IntervalSchedule) Next(after time.Time) time.Time {
+	if s.Interval <= 0 {
+		return time.Time{}
+	}
+	if after.Before(s.Start) {
+		return s.Start
+	}
+	n := after.Sub(s.Start)/s.Interval + 1
+	return s.Start.Add(n * s.Interval)
+}
+
+// Расписание в формате cron: минута, час, день месяца, месяц, день недели.
+// Поддерживаются *, списки через запятую, диапазоны и шаг: "0 12 1 * *", "*/15 9-18 * * 1-5"
+type CronSchedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// Если ограничены и день месяца, и день недели, достаточно совпадения одного из них
+	domRestricted bool
+	dowRestricted bool
+}
+
+// Ограничение количества шагов поиска следующего запуска, например, для "0 0 30 2 *"
+const cronSearchLimit = 100_000
+
+// Функция разбора расписания в формате cron
+func ParseCron(spec string) (CronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return CronSchedule{}, ErrInvalidSchedule
+	}
+
+	var s CronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return CronSchedule{}, err
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return CronSchedule{}, err
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return CronSchedule{}, err
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return CronSchedule{}, err
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return CronSchedule{}, err
+	}
+	// Воскресенье можно указать как 0 или 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	// Как в cron, поле, начинающееся со звездочки, не ограничивает дни, даже с шагом: "*/2"
+	s.domRestricted = !strings.HasPrefix(fields[2], "*")
+	s.dowRestricted = !strings.HasPrefix(fields[4], "*")
+
+	return s, nil
+}
+
+// Функция разбора поля cron в битовую маску допустимых значений
+func parseCronField(field string, min, max int) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(field, ",") {
+		step, stepped := 1, false
+		if i := strings.Index(part, "/"); i >= 0 {
+			stepped = true
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, ErrInvalidSchedule
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, ErrInvalidSchedule
+			}
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, ErrInvalidSchedule
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, ErrInvalidSchedule
+			}
+			from, to = value, value
+			// Значение с шагом означает диапазон до максимума: "5/15" - это 5,20,35,50
+			if stepped {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, ErrInvalidSchedule
+		}
+
+		for v := from; v <= to; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+func (s CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+
+	for i := 0; i < cronSearchLimit; i++ {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s CronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
@@ -0,0 +1,86 @@
+package card
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "Monthly at noon",
+			spec:  "0 12 1 * *",
+			after: time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC),
+			want:  time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "Every 15 minutes in working hours",
+			spec:  "*/15 9-18 * * 1-5",
+			after: time.Date(2020, 9, 11, 18, 50, 0, 0, time.UTC),
+			want:  time.Date(2020, 9, 14, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "Strictly after",
+			spec:  "30 10 * * *",
+			after: time.Date(2020, 9, 10, 10, 30, 0, 0, time.UTC),
+			want:  time.Date(2020, 9, 11, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "Day of month or sunday",
+			spec:  "0 0 15 * 7",
+			after: time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2020, 9, 13, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "Odd day of month and monday",
+			spec:  "0 0 */2 * 1",
+			after: time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2020, 9, 21, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "Never",
+			spec:  "0 0 30 2 *",
+			after: time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC),
+			want:  time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCron(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 5-1 * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := ParseCron(spec); err != ErrInvalidSchedule {
+			t.Errorf("ParseCron(%q) error = %v, wantErr %v", spec, err, ErrInvalidSchedule)
+		}
+	}
+}
+
+func TestIntervalSchedule_Next(t *testing.T) {
+	start := time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC)
+	s := IntervalSchedule{Start: start, Interval: time.Hour}
+
+	if got := s.Next(start.Add(-time.Minute)); !got.Equal(start) {
+		t.Errorf("Next() got = %v, want %v", got, start)
+	}
+	if got := s.Next(start); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Next() got = %v, want %v", got, start.Add(time.Hour))
+	}
+	if got := s.Next(start.Add(90 * time.Minute)); !got.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("Next() got = %v, want %v", got, start.Add(2*time.Hour))
+	}
+}
@@ -0,0 +1,400 @@
+package card
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort field")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("invalid limit")
+)
+
+// Поле сортировки результатов поиска
+type SortField string
+
+const (
+	SortByTime SortField = "time"
+	SortByBill SortField = "bill"
+	SortById   SortField = "id"
+)
+
+// Размер страницы поиска по умолчанию и максимальный
+const (
+	DefaultSearchLimit = 100
+	MaxSearchLimit     = 1000
+)
+
+// Параметры поиска транзакций карты. Пустые поля не ограничивают выборку
+type SearchQuery struct {
+	From     time.Time
+	To       time.Time // Не включается в выборку
+	MinBill  *int64    // Суммы сравниваются в валюте операции
+	MaxBill  *int64
+	MCC      []string
+	Status   string
+	IdPrefix string
+	Text     string // Слова, которые должны встретиться в идентификаторе, коде или названии категории, статусе или типе
+	Sort     SortField
+	Desc     bool
+	Limit    int    // Нулевой лимит - DefaultSearchLimit
+	Cursor   string // Курсор из SearchResult.NextCursor для получения следующей страницы
+	// Посчитать SearchResult.Total. Подсчет просматривает все подходящие транзакции, поэтому
+	// по умолчанию выключен: без него глубокие страницы не требуют просмотра с начала
+	WithTotal bool
+}
+
+// Страница результатов поиска
+type SearchResult struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"` // Пустой курсор - страница последняя
+	Total        int           `json:"total,omitempty"`       // Количество найденных транзакций на всех страницах, только с WithTotal
+}
+
+// Положение следующей страницы. При сортировке по времени страница начинается после ключа
+// (время, идентификатор) последней выданной транзакции, при других сортировках - со смещения
+type searchCursor struct {
+	keyset bool
+	desc   bool
+	time   int64
+	id     string
+	offset int
+}
+
+// Метод поиска транзакций карты с фильтрами, сортировкой и постраничной выдачей
+func (s *Service) SearchTransactions(id CardId, q SearchQuery) (SearchResult, error) {
+	return s.SearchTransactionsContext(context.Background(), id, q)
+}
+
+// Метод поиска транзакций карты с возможностью отмены
+func (s *Service) SearchTransactionsContext(ctx context.Context, id CardId, q SearchQuery) (SearchResult, error) {
+	if err := q.validate(); err != nil {
+		return SearchResult{}, err
+	}
+	cursor, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	timeOrdered := q.Sort == "" || q.Sort == SortByTime
+	if q.Cursor != "" && (cursor.keyset != timeOrdered || cursor.keyset && cursor.desc != q.Desc) {
+		return SearchResult{}, ErrInvalidCursor
+	}
+
+	s.mu.Lock()
+	c, err := s.cardById(id)
+	if err == nil {
+		err = s.authorize(ctx, ActionRead, c)
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return SearchResult{}, err
+	}
+	if timeOrdered {
+		result, err := q.searchByTime(ctx, c, cursor)
+		s.mu.Unlock()
+		return result, err
+	}
+	found, err := q.filter(ctx, c.Transactions.Transactions)
+	s.mu.Unlock()
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	sortTransactions(found, q.Sort, q.Desc)
+
+	var result SearchResult
+	if q.WithTotal {
+		result.Total = len(found)
+	}
+	offset := cursor.offset
+	if offset > len(found) {
+		offset = len(found)
+	}
+	end := offset + q.limit()
+	if end < len(found) {
+		result.NextCursor = encodeCursor(searchCursor{offset: end})
+	} else {
+		end = len(found)
+	}
+	result.Transactions = found[offset:end]
+
+	return result, nil
+}
+
+// Метод поиска по индексу времени карты. Начало страницы находится двоичным поиском по курсору
+// или границе диапазона времени, поэтому глубокие страницы не требуют просмотра с начала
+func (q SearchQuery) searchByTime(ctx context.Context, c *Card, cursor searchCursor) (SearchResult, error) {
+	index := c.sortedIndex()
+	transactions := c.Transactions.Transactions
+	words := strings.Fields(strings.ToLower(q.Text))
+	limit := q.limit()
+
+	var result SearchResult
+	if q.WithTotal {
+		total, err := q.countByTime(ctx, index, transactions, words)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		result.Total = total
+	}
+	visit := func(n int, t Transaction) (bool, error) {
+		if n%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if !q.matches(t, words) {
+			return true, nil
+		}
+		if len(result.Transactions) == limit {
+			last := result.Transactions[limit-1]
+			result.NextCursor = encodeCursor(searchCursor{keyset: true, desc: q.Desc, time: last.Time, id: last.Id})
+			return false, nil
+		}
+		result.Transactions = append(result.Transactions, t)
+		return true, nil
+	}
+
+	if !q.Desc {
+		start := 0
+		if !q.From.IsZero() {
+			from := q.From.Unix()
+			start = sort.Search(len(index), func(i int) bool { return transactions[index[i]].Time >= from })
+		}
+		if cursor.keyset {
+			after := sort.Search(len(index), func(i int) bool {
+				return compareKey(transactions[index[i]], cursor.time, cursor.id) > 0
+			})
+			if after > start {
+				start = after
+			}
+		}
+		for i := start; i < len(index); i++ {
+			t := transactions[index[i]]
+			if !q.To.IsZero() && t.Time >= q.To.Unix() {
+				break
+			}
+			next, err := visit(i-start, t)
+			if err != nil {
+				return SearchResult{}, err
+			}
+			if !next {
+				break
+			}
+		}
+		return result, nil
+	}
+
+	end := len(index)
+	if !q.To.IsZero() {
+		to := q.To.Unix()
+		end = sort.Search(len(index), func(i int) bool { return transactions[index[i]].Time >= to })
+	}
+	if cursor.keyset {
+		before := sort.Search(len(index), func(i int) bool {
+			return compareKey(transactions[index[i]], cursor.time, cursor.id) >= 0
+		})
+		if before < end {
+			end = before
+		}
+	}
+	for i := end - 1; i >= 0; i-- {
+		t := transactions[index[i]]
+		if !q.From.IsZero() && t.Time < q.From.Unix() {
+			break
+		}
+		next, err := visit(end-1-i, t)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		if !next {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Метод подсчета транзакций, подходящих под запрос, на всех страницах.
+// Границы диапазона времени находятся по индексу, остальные фильтры проверяются для каждой транзакции
+func (q SearchQuery) countByTime(ctx context.Context, index []int, transactions []Transaction, words []string) (int, error) {
+	start, end := 0, len(index)
+	if !q.From.IsZero() {
+		from := q.From.Unix()
+		start = sort.Search(len(index), func(i int) bool { return transactions[index[i]].Time >= from })
+	}
+	if !q.To.IsZero() {
+		to := q.To.Unix()
+		end = sort.Search(len(index), func(i int) bool { return transactions[index[i]].Time >= to })
+	}
+
+	count := 0
+	for i := start; i < end; i++ {
+		if (i-start)%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if q.matches(transactions[index[i]], words) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (q SearchQuery) validate() error {
+	switch q.Sort {
+	case "", SortByTime, SortByBill, SortById:
+	default:
+		return ErrInvalidSort
+	}
+	if q.Limit < 0 {
+		return ErrInvalidLimit
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return ErrInvalidRange
+	}
+	if q.MinBill != nil && q.MaxBill != nil && *q.MinBill > *q.MaxBill {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+func (q SearchQuery) limit() int {
+	if q.Limit == 0 {
+		return DefaultSearchLimit
+	}
+	if q.Limit > MaxSearchLimit {
+		return MaxSearchLimit
+	}
+	return q.Limit
+}
+
+// Метод отбора транзакций по фильтрам в новый слайс
+func (q SearchQuery) filter(ctx context.Context, transactions []Transaction) ([]Transaction, error) {
+	words := strings.Fields(strings.ToLower(q.Text))
+
+	var found []Transaction
+	for i, t := range transactions {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if q.matches(t, words) {
+			found = append(found, t)
+		}
+	}
+	return found, nil
+}
+
+func (q SearchQuery) matches(t Transaction, words []string) bool {
+	if !q.From.IsZero() && t.Time < q.From.Unix() {
+		return false
+	}
+	if !q.To.IsZero() && t.Time >= q.To.Unix() {
+		return false
+	}
+	if q.MinBill != nil && t.Bill < *q.MinBill {
+		return false
+	}
+	if q.MaxBill != nil && t.Bill > *q.MaxBill {
+		return false
+	}
+	if q.Status != "" && !strings.EqualFold(t.Status, q.Status) {
+		return false
+	}
+	if !strings.HasPrefix(t.Id, q.IdPrefix) {
+		return false
+	}
+	if len(q.MCC) != 0 {
+		matched := false
+		for _, code := range q.MCC {
+			if t.MCC == code {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(words) == 0 {
+		return true
+	}
+
+	text := strings.ToLower(strings.Join([]string{t.Id, t.MCC, t.Status, t.Type, t.Currency}, " "))
+	if mcc, ok := DefaultMCCRegistry().Lookup(t.MCC); ok {
+		text += " " + strings.ToLower(mcc.NameRu+" "+mcc.NameEn+" "+mcc.Group)
+	}
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// Функция сортировки транзакций. При равенстве поля порядок задают время и идентификатор
+func sortTransactions(transactions []Transaction, field SortField, desc bool) {
+	less := func(a, b Transaction) bool {
+		switch field {
+		case SortByBill:
+			if a.Bill != b.Bill {
+				return a.Bill < b.Bill
+			}
+		case SortById:
+			return a.Id < b.Id
+		}
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		return a.Id < b.Id
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if desc {
+			return less(transactions[j], transactions[i])
+		}
+		return less(transactions[i], transactions[j])
+	})
+}
+
+// Функция кодирования курсора в непрозрачную для клиента строку
+func encodeCursor(c searchCursor) string {
+	var raw string
+	switch {
+	case c.keyset && c.desc:
+		raw = "kd:" + strconv.FormatInt(c.time, 10) + ":" + c.id
+	case c.keyset:
+		raw = "ka:" + strconv.FormatInt(c.time, 10) + ":" + c.id
+	default:
+		raw = "o:" + strconv.Itoa(c.offset)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (searchCursor, error) {
+	if cursor == "" {
+		return searchCursor{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return searchCursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(data), ":", 3)
+	switch {
+	case len(parts) == 2 && parts[0] == "o":
+		offset, err := strconv.Atoi(parts[1])
+		if err != nil || offset < 0 {
+			return searchCursor{}, ErrInvalidCursor
+		}
+		return searchCursor{offset: offset}, nil
+	case len(parts) == 3 && (parts[0] == "ka" || parts[0] == "kd"):
+		time, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return searchCursor{}, ErrInvalidCursor
+		}
+		return searchCursor{keyset: true, desc: parts[0] == "kd", time: time, id: parts[2]}, nil
+	}
+	return searchCursor{}, ErrInvalidCursor
+}
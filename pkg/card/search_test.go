@@ -0,0 +1,257 @@
+package card
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newSearchService() *Service {
+	s := New("Test Bank")
+	c := s.issueCard(1, Owner{FirstName: "User", LastName: "User"}, "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+	c.AddTransaction(Transaction{Id: "a-0001", Bill: 300_00, Time: 1599739200, MCC: "5411", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "a-0002", Bill: 200_00, Time: 1599825600, MCC: "5812", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "b-0003", Bill: 500_00, Time: 1599912000, MCC: "5411", Status: StatusDeclined})
+	c.AddTransaction(Transaction{Id: "b-0004", Bill: -100_00, Time: 1599998400, MCC: "5411", Status: StatusDone, Type: TypeRefund, OriginalId: "a-0001"})
+	c.AddTransaction(Transaction{Id: "b-0005", Bill: 200_00, Time: 1599825600, MCC: "5814", Status: StatusDone})
+	return s
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestService_SearchTransactions(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   SearchQuery
+		wantIds []string
+		wantErr error
+	}{
+		{
+			name:    "All by time",
+			query:   SearchQuery{},
+			wantIds: []string{"a-0001", "a-0002", "b-0005", "b-0003", "b-0004"},
+		},
+		{
+			name:    "Time range",
+			query:   SearchQuery{From: time.Unix(1599825600, 0), To: time.Unix(1599998400, 0)},
+			wantIds: []string{"a-0002", "b-0005", "b-0003"},
+		},
+		{
+			name:    "Amount range",
+			query:   SearchQuery{MinBill: int64Ptr(0), MaxBill: int64Ptr(300_00)},
+			wantIds: []string{"a-0001", "a-0002", "b-0005"},
+		},
+		{
+			name:    "MCC list",
+			query:   SearchQuery{MCC: []string{"5812", "5814"}},
+			wantIds: []string{"a-0002", "b-0005"},
+		},
+		{
+			name:    "Status",
+			query:   SearchQuery{Status: "declined"},
+			wantIds: []string{"b-0003"},
+		},
+		{
+			name:    "Id prefix",
+			query:   SearchQuery{IdPrefix: "b-"},
+			wantIds: []string{"b-0005", "b-0003", "b-0004"},
+		},
+		{
+			name:    "Text by category name",
+			query:   SearchQuery{Text: "СУПЕРМАРКЕТЫ refund"},
+			wantIds: []string{"b-0004"},
+		},
+		{
+			name:    "Sort by bill descending",
+			query:   SearchQuery{Sort: SortByBill, Desc: true},
+			wantIds: []string{"b-0003", "a-0001", "b-0005", "a-0002", "b-0004"},
+		},
+		{
+			name:    "Invalid sort",
+			query:   SearchQuery{Sort: "mcc"},
+			wantErr: ErrInvalidSort,
+		},
+		{
+			name:    "Invalid cursor",
+			query:   SearchQuery{Cursor: "???"},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "Invalid amount range",
+			query:   SearchQuery{MinBill: int64Ptr(10), MaxBill: int64Ptr(1)},
+			wantErr: ErrInvalidRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newSearchService().SearchTransactions(1, tt.query)
+			if err != tt.wantErr {
+				t.Fatalf("SearchTransactions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var ids []string
+			for _, t := range got.Transactions {
+				ids = append(ids, t.Id)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("SearchTransactions() got = %v, want %v", ids, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestService_SearchTransactions_Pages(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   SearchQuery
+		wantIds []string
+	}{
+		{
+			name:    "By time",
+			query:   SearchQuery{Limit: 2},
+			wantIds: []string{"a-0001", "a-0002", "b-0005", "b-0003", "b-0004"},
+		},
+		{
+			name:    "By time descending",
+			query:   SearchQuery{Limit: 2, Desc: true},
+			wantIds: []string{"b-0004", "b-0003", "b-0005", "a-0002", "a-0001"},
+		},
+		{
+			name:    "By time with filters",
+			query:   SearchQuery{Limit: 1, MCC: []string{"5411"}, From: time.Unix(1599739200, 0), To: time.Unix(1599998400, 0)},
+			wantIds: []string{"a-0001", "b-0003"},
+		},
+		{
+			name:    "By bill",
+			query:   SearchQuery{Limit: 2, Sort: SortByBill},
+			wantIds: []string{"b-0004", "a-0002", "b-0005", "a-0001", "b-0003"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSearchService()
+			var ids []string
+			query := tt.query
+			for pages := 0; ; pages++ {
+				if pages > len(tt.wantIds) {
+					t.Fatal("SearchTransactions() got too many pages")
+				}
+				got, err := s.SearchTransactions(1, query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got.Transactions) > query.Limit {
+					t.Fatalf("SearchTransactions() got %v transactions, limit %v", len(got.Transactions), query.Limit)
+				}
+				for _, t := range got.Transactions {
+					ids = append(ids, t.Id)
+				}
+				if got.NextCursor == "" {
+					break
+				}
+				query.Cursor = got.NextCursor
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("SearchTransactions() got = %v, want %v", ids, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestService_SearchTransactions_Total(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     SearchQuery
+		wantTotal int
+	}{
+		{name: "Without total", query: SearchQuery{Limit: 1}, wantTotal: 0},
+		{name: "By time", query: SearchQuery{Limit: 1, WithTotal: true}, wantTotal: 5},
+		{name: "By time descending", query: SearchQuery{Limit: 1, Desc: true, WithTotal: true}, wantTotal: 5},
+		{
+			name:      "By time with filters",
+			query:     SearchQuery{Limit: 1, MCC: []string{"5411"}, From: time.Unix(1599739200, 0), To: time.Unix(1599998400, 0), WithTotal: true},
+			wantTotal: 2,
+		},
+		{name: "By bill", query: SearchQuery{Limit: 1, Sort: SortByBill, Status: StatusDone, WithTotal: true}, wantTotal: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSearchService()
+			first, err := s.SearchTransactions(1, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Общее количество не зависит от страницы
+			query := tt.query
+			query.Cursor = first.NextCursor
+			second, err := s.SearchTransactions(1, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.Total != tt.wantTotal || second.Total != tt.wantTotal {
+				t.Errorf("SearchTransactions() total = %v, %v, want %v", first.Total, second.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestService_SearchTransactions_CursorMismatch(t *testing.T) {
+	s := newSearchService()
+	page, err := s.SearchTransactions(1, SearchQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []SearchQuery{
+		{Limit: 1, Cursor: page.NextCursor, Desc: true},
+		{Limit: 1, Cursor: page.NextCursor, Sort: SortByBill},
+	} {
+		if _, err := s.SearchTransactions(1, query); err != ErrInvalidCursor {
+			t.Errorf("SearchTransactions() error = %v, wantErr %v", err, ErrInvalidCursor)
+		}
+	}
+	if _, err := s.SearchTransactions(2, SearchQuery{}); err != ErrCardNotFound {
+		t.Errorf("SearchTransactions() error = %v, wantErr %v", err, ErrCardNotFound)
+	}
+}
+
+func TestCard_sortedIndex(t *testing.T) {
+	c := &Card{}
+	c.AddTransaction(Transaction{Id: "3", Time: 30})
+	c.AddTransaction(Transaction{Id: "1", Time: 10})
+	c.AddTransaction(Transaction{Id: "2b", Time: 20})
+	c.AddTransaction(Transaction{Id: "2a", Time: 20})
+	if want := []int{1, 3, 2, 0}; !reflect.DeepEqual(c.sortedIndex(), want) {
+		t.Errorf("sortedIndex() got = %v, want %v", c.sortedIndex(), want)
+	}
+
+	// Транзакции, добавленные в обход AddTransaction, попадают в индекс при следующем чтении
+	c.Transactions.Transactions = append(c.Transactions.Transactions, Transaction{Id: "0", Time: 0})
+	c.AddTransaction(Transaction{Id: "4", Time: 40})
+	if want := []int{4, 1, 3, 2, 0, 5}; !reflect.DeepEqual(c.sortedIndex(), want) {
+		t.Errorf("sortedIndex() got = %v, want %v", c.sortedIndex(), want)
+	}
+}
+
+func BenchmarkService_SearchTransactions_DeepPage(b *testing.B) {
+	s := New("Test Bank")
+	s.CardIssue(1, "User", "User", "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+	if err := s.MakeTransactions(1, 500_000); err != nil {
+		b.Fatal(err)
+	}
+	c, _ := s.cardById(1)
+	last := c.Transactions.Transactions[len(c.Transactions.Transactions)-1000]
+	cursor := encodeCursor(searchCursor{keyset: true, time: last.Time, id: last.Id})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		page, err := s.SearchTransactions(1, SearchQuery{Limit: 100, Cursor: cursor})
+		if err != nil || len(page.Transactions) != 100 {
+			b.Fatal(page, err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package card
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Выписка по карте за месяц. Суммы остатков и итогов в валюте карты
+type Statement struct {
+	CardId         CardId              `json:"card_id"`
+	Owner          string              `json:"owner"`
+	Number         string              `json:"number"`
+	Currency       string              `json:"currency"`
+	Period         string              `json:"period"` // Месяц в формате 2006-01
+	From           int64               `json:"from"`   // Начало месяца включительно
+	To             int64               `json:"to"`     // Начало следующего месяца
+	OpeningBalance int64               `json:"opening_balance"`
+	ClosingBalance int64               `json:"closing_balance"`
+	Debit          int64               `json:"debit"`  // Списания за месяц
+	Credit         int64               `json:"credit"` // Зачисления за месяц
+	Transactions   []Transaction       `json:"transactions"`
+	Subtotals      []StatementSubtotal `json:"subtotals"`
+}
+
+// Итог выписки по коду MCC
+type StatementSubtotal struct {
+	MCC      string `json:"mcc"`
+	Category string `json:"category"` // Название категории из TranslateMCC
+	Total    int64  `json:"total"`
+	Count    int    `json:"count"`
+}
+
+// Метод формирования выписки по карте за месяц, в который попадает month.
+// Остатки восстанавливаются от текущего баланса карты: к нему прибавляются все
+// проведенные после начала месяца операции. Отклоненные транзакции попадают в выписку, но не в итоги
+func (s *Service) Statement(id CardId, month time.Time) (Statement, error) {
+	return s.StatementContext(context.Background(), id, month)
+}
+
+// Метод формирования выписки с проверкой права ActionRead
+func (s *Service) StatementContext(ctx context.Context, id CardId, month time.Time) (Statement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.cardById(id)
+	if err != nil {
+		return Statement{}, err
+	}
+	if err = s.authorize(ctx, ActionRead, c); err != nil {
+		return Statement{}, err
+	}
+
+	month = month.UTC()
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	statement := Statement{
+		CardId:   c.Id,
+		Owner:    c.FirstName + " " + c.LastName,
+		Number:   c.Number,
+		Currency: c.Currency,
+		Period:   from.Format("2006-01"),
+		From:     from.Unix(),
+		To:       to.Unix(),
+	}
+
+	// Сумма списаний после конца месяца, чтобы восстановить остаток на его конец
+	var after int64
+	subtotals := make(map[string]*StatementSubtotal)
+	transactions := c.Transactions.Transactions
+	for _, i := range c.sortedIndex() {
+		t := transactions[i]
+		if t.Time < statement.From {
+			continue
+		}
+		if t.Time >= statement.To {
+			if t.Status != StatusDeclined {
+				bill, err := c.BillInCardCurrency(s.Rates, t)
+				if err != nil {
+					return Statement{}, err
+				}
+				after += bill
+			}
+			continue
+		}
+
+		statement.Transactions = append(statement.Transactions, t)
+		if t.Status == StatusDeclined {
+			continue
+		}
+		bill, err := c.BillInCardCurrency(s.Rates, t)
+		if err != nil {
+			return Statement{}, err
+		}
+		if bill > 0 {
+			statement.Debit += bill
+		} else {
+			statement.Credit += -bill
+		}
+
+		subtotal, ok := subtotals[t.MCC]
+		if !ok {
+			subtotal = &StatementSubtotal{MCC: t.MCC, Category: TranslateMCC(t.MCC)}
+			subtotals[t.MCC] = subtotal
+		}
+		subtotal.Total += bill
+		subtotal.Count++
+	}
+
+	statement.ClosingBalance = int64(c.Balance) + after
+	statement.OpeningBalance = statement.ClosingBalance + statement.Debit - statement.Credit
+
+	statement.Subtotals = make([]StatementSubtotal, 0, len(subtotals))
+	for _, subtotal := range subtotals {
+		statement.Subtotals = append(statement.Subtotals, *subtotal)
+	}
+	sort.Slice(statement.Subtotals, func(i, j int) bool {
+		return statement.Subtotals[i].MCC < statement.Subtotals[j].MCC
+	})
+	if statement.Transactions == nil {
+		statement.Transactions = []Transaction{}
+	}
+
+	return statement, nil
+}
+
+// Функция записи выписки в .csv: строки транзакций, итоги по категориям и остатки
+func WriteStatementCsv(w io.Writer, statement Statement) error {
+	writer := csv.NewWriter(w)
+
+	rows := [][]string{
+		{"Period", statement.Period},
+		{"Card", strconv.FormatInt(int64(statement.CardId), 10), statement.Number, statement.Currency},
+		{"OpeningBalance", strconv.FormatInt(statement.OpeningBalance, 10)},
+		{},
+		{"ID", "Bill", "Time", "MCC", "Status", "Type", "OriginalID", "Currency"},
+	}
+	for _, t := range statement.Transactions {
+		rows = append(rows, transactionToSlice(t))
+	}
+	rows = append(rows, []string{}, []string{"MCC", "Category", "Total", "Count"})
+	for _, s := range statement.Subtotals {
+		rows = append(rows, []string{s.MCC, s.Category, strconv.FormatInt(s.Total, 10), strconv.Itoa(s.Count)})
+	}
+	rows = append(rows,
+		[]string{},
+		[]string{"Debit", strconv.FormatInt(statement.Debit, 10)},
+		[]string{"Credit", strconv.FormatInt(statement.Credit, 10)},
+		[]string{"ClosingBalance", strconv.FormatInt(statement.ClosingBalance, 10)},
+	)
+
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Функция записи выписки в .json
+func WriteStatementJson(w io.Writer, statement Statement) error {
+	data, err := json.MarshalIndent(statement, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
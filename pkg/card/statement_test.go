@@ -0,0 +1,111 @@
+package card
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStatementService() *Service {
+	s := New("Test Bank")
+	c := s.issueCard(1, Owner{FirstName: "Ivan", LastName: "Ivanov"}, "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+	at := func(month time.Month, day int) int64 {
+		return time.Date(2020, month, day, 12, 0, 0, 0, time.UTC).Unix()
+	}
+	c.AddTransaction(Transaction{Id: "0001", Bill: 100_00, Time: at(8, 31), MCC: "5411", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "0002", Bill: 200_00, Time: at(9, 1), MCC: "5411", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "0003", Bill: 50_00, Time: at(9, 2), MCC: "5411", Status: StatusDeclined})
+	c.AddTransaction(Transaction{Id: "0005", Bill: 300_00, Time: at(9, 10), MCC: "5812", Status: StatusDone})
+	c.AddTransaction(Transaction{Id: "0004", Bill: -50_00, Time: at(9, 5), MCC: "5411", Status: StatusDone, Type: TypeRefund, OriginalId: "0002"})
+	c.AddTransaction(Transaction{Id: "0006", Bill: 100_00, Time: at(10, 1), MCC: "5411", Status: StatusDone})
+	return s
+}
+
+func TestService_Statement(t *testing.T) {
+	tests := []struct {
+		name        string
+		month       time.Time
+		wantOpening int64
+		wantClosing int64
+		wantIds     []string
+		wantTotals  []StatementSubtotal
+	}{
+		{
+			name:        "Month with refund",
+			month:       time.Date(2020, 9, 15, 0, 0, 0, 0, time.UTC),
+			wantOpening: 1550_00,
+			wantClosing: 1100_00,
+			wantIds:     []string{"0002", "0003", "0004", "0005"},
+			wantTotals: []StatementSubtotal{
+				{MCC: "5411", Category: "Супермаркеты", Total: 150_00, Count: 2},
+				{MCC: "5812", Category: "Рестораны", Total: 300_00, Count: 1},
+			},
+		},
+		{
+			name:        "Current month",
+			month:       time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC),
+			wantOpening: 1100_00,
+			wantClosing: 1000_00,
+			wantIds:     []string{"0006"},
+			wantTotals:  []StatementSubtotal{{MCC: "5411", Category: "Супермаркеты", Total: 100_00, Count: 1}},
+		},
+		{
+			name:        "Empty month",
+			month:       time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC),
+			wantOpening: 1000_00,
+			wantClosing: 1000_00,
+			wantTotals:  []StatementSubtotal{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newStatementService().Statement(1, tt.month)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.OpeningBalance != tt.wantOpening || got.ClosingBalance != tt.wantClosing {
+				t.Errorf("Statement() got balances %v..%v, want %v..%v", got.OpeningBalance, got.ClosingBalance, tt.wantOpening, tt.wantClosing)
+			}
+			var ids []string
+			for _, t := range got.Transactions {
+				ids = append(ids, t.Id)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("Statement() got transactions %v, want %v", ids, tt.wantIds)
+			}
+			if !reflect.DeepEqual(got.Subtotals, tt.wantTotals) {
+				t.Errorf("Statement() got subtotals %+v, want %+v", got.Subtotals, tt.wantTotals)
+			}
+		})
+	}
+
+	if _, err := newStatementService().Statement(2, time.Now()); err != ErrCardNotFound {
+		t.Errorf("Statement() error = %v, wantErr %v", err, ErrCardNotFound)
+	}
+}
+
+func TestWriteStatement(t *testing.T) {
+	statement, err := newStatementService().Statement(1, time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var csvOut, jsonOut bytes.Buffer
+	if err := WriteStatementCsv(&csvOut, statement); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteStatementJson(&jsonOut, statement); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"OpeningBalance,155000", "5812,Рестораны,30000,1", "ClosingBalance,110000"} {
+		if !strings.Contains(csvOut.String(), want) {
+			t.Errorf("WriteStatementCsv() got %v, want to contain %v", csvOut.String(), want)
+		}
+	}
+	if !strings.Contains(jsonOut.String(), `"closing_balance": 110000`) {
+		t.Errorf("WriteStatementJson() got %v", jsonOut.String())
+	}
+}
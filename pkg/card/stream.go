@@ -0,0 +1,143 @@
+package card
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+)
+
+// Потоковая запись транзакций. Позволяет выгружать данные, не помещающиеся в память.
+// Close дописывает окончание формата, но не закрывает исходный io.Writer
+type TransactionWriter interface {
+	WriteTransaction(transaction Transaction) error
+	Close() error
+}
+
+// Функция создания потоковой записи в формате .csv, совместимом с ImporterFromCsv
+func NewCsvTransactionWriter(w io.Writer) TransactionWriter {
+	return &csvTransactionWriter{writer: csv.NewWriter(w)}
+}
+
+type csvTransactionWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (w *csvTransactionWriter) WriteTransaction(transaction Transaction) error {
+	if !w.header {
+		w.header = true
+		err := w.writer.Write([]string{"ID", "Bill", "Time", "MCC", "Status", "Type", "OriginalID", "Currency"})
+		if err != nil {
+			return err
+		}
+	}
+	return w.writer.Write(transactionToSlice(transaction))
+}
+
+func (w *csvTransactionWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// Функция создания потоковой записи в формате .json, совместимом с ImporterFromJson
+func NewJsonTransactionWriter(w io.Writer) TransactionWriter {
+	return &jsonTransactionWriter{writer: bufio.NewWriter(w)}
+}
+
+type jsonTransactionWriter struct {
+	writer *bufio.Writer
+	count  int
+}
+
+func (w *jsonTransactionWriter) WriteTransaction(transaction Transaction) error {
+	prefix := ",\n  "
+	if w.count == 0 {
+		prefix = "{\n \"Transactions\": [\n  "
+	}
+	w.count++
+
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return err
+	}
+	if _, err = w.writer.WriteString(prefix); err != nil {
+		return err
+	}
+	_, err = w.writer.Write(data)
+	return err
+}
+
+func (w *jsonTransactionWriter) Close() error {
+	suffix := "\n ]\n}\n"
+	if w.count == 0 {
+		suffix = "{\n \"Transactions\": []\n}\n"
+	}
+	if _, err := w.writer.WriteString(suffix); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+// Функция создания потоковой записи в формате .xml, совпадающем с ExporterToXml
+func NewXmlTransactionWriter(w io.Writer) TransactionWriter {
+	return &xmlTransactionWriter{writer: bufio.NewWriter(w)}
+}
+
+type xmlTransactionWriter struct {
+	writer *bufio.Writer
+	opened bool
+}
+
+func (w *xmlTransactionWriter) open() error {
+	if w.opened {
+		return nil
+	}
+	w.opened = true
+	_, err := w.writer.WriteString(xml.Header + "<transactions>\n")
+	return err
+}
+
+func (w *xmlTransactionWriter) WriteTransaction(transaction Transaction) error {
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	data, err := xml.MarshalIndent(transaction, " ", " ")
+	if err != nil {
+		return err
+	}
+	if _, err = w.writer.Write(data); err != nil {
+		return err
+	}
+	return w.writer.WriteByte('\n')
+}
+
+func (w *xmlTransactionWriter) Close() error {
+	if err := w.open(); err != nil {
+		return err
+	}
+	if _, err := w.writer.WriteString("</transactions>\n"); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+// Функция создания записи транзакций прямо в карту
+func NewCardTransactionWriter(c *Card) TransactionWriter {
+	return cardTransactionWriter{card: c}
+}
+
+type cardTransactionWriter struct {
+	card *Card
+}
+
+func (w cardTransactionWriter) WriteTransaction(transaction Transaction) error {
+	w.card.AddTransaction(transaction)
+	return nil
+}
+
+func (w cardTransactionWriter) Close() error {
+	return nil
+}
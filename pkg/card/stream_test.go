@@ -0,0 +1,75 @@
+package card
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransactionWriter(t *testing.T) {
+	transactions := []Transaction{
+		{Id: "0001", Bill: 100_00, Time: 1606192422, MCC: "5411", Status: StatusDone, Type: TypePurchase},
+		{Id: "0002", Bill: -50_00, Time: 1606192432, MCC: "5411", Status: StatusDone, Type: TypeRefund, OriginalId: "0001", Currency: "USD"},
+	}
+	tests := []struct {
+		name   string
+		writer func(buf *bytes.Buffer) TransactionWriter
+		// Функция импорта записанного файла, nil - проверяется только содержимое
+		importer func(c *Card, fileName string) error
+		contains string
+	}{
+		{
+			name:     "CSV",
+			writer:   func(buf *bytes.Buffer) TransactionWriter { return NewCsvTransactionWriter(buf) },
+			importer: ImporterFromCsv,
+			contains: "0002,-5000,1606192432,5411,Done,refund,0001,USD",
+		},
+		{
+			name:     "JSON",
+			writer:   func(buf *bytes.Buffer) TransactionWriter { return NewJsonTransactionWriter(buf) },
+			importer: ImporterFromJson,
+			contains: `"original_id":"0001"`,
+		},
+		{
+			name:     "XML",
+			writer:   func(buf *bytes.Buffer) TransactionWriter { return NewXmlTransactionWriter(buf) },
+			importer: ImporterFromXml,
+			contains: "<original_id>0001</original_id>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := tt.writer(&buf)
+			for _, transaction := range transactions {
+				if err := w.WriteTransaction(transaction); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tt.contains) {
+				t.Errorf("WriteTransaction() got %v, want to contain %v", buf.String(), tt.contains)
+			}
+			if tt.importer == nil {
+				return
+			}
+
+			fileName := filepath.Join(t.TempDir(), "stream")
+			if err := ioutil.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			c := &Card{}
+			if err := tt.importer(c, fileName); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c.Transactions.Transactions, transactions) {
+				t.Errorf("import got = %+v, want %+v", c.Transactions.Transactions, transactions)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package card
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidTransfer   = errors.New("invalid transfer")
+)
+
+// Тип операции перевода между картами
+const TypeTransfer = "transfer"
+
+// Код категории для переводов
+const transferMCC = "4829"
+
+// Метод перевода между картами банка.
+// На карте отправителя сохраняется списание, на карте получателя - зачисление с отрицательной суммой
+func (s *Service) Transfer(from, to CardId, amount int64) (Transaction, error) {
+	return s.TransferContext(context.Background(), from, to, amount)
+}
+
+// Метод перевода между картами с проверкой права ActionTransfer на карту отправителя
+func (s *Service) TransferContext(ctx context.Context, from, to CardId, amount int64) (Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.transfer(ctx, from, to, amount)
+}
+
+// Метод перевода между картами. Вызывается под s.mu
+func (s *Service) transfer(ctx context.Context, from, to CardId, amount int64) (Transaction, error) {
+	if amount <= 0 {
+		return Transaction{}, ErrInvalidAmount
+	}
+	if from == to {
+		return Transaction{}, ErrInvalidTransfer
+	}
+
+	source, err := s.cardById(from)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if err = s.authorize(ctx, ActionTransfer, source); err != nil {
+		return Transaction{}, err
+	}
+	target, err := s.cardById(to)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if int64(source.Balance) < amount {
+		return Transaction{}, ErrInsufficientFunds
+	}
+
+	now := s.now()
+	credit, err := Convert(s.Rates, amount, source.Currency, target.Currency, now)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	outgoing := Transaction{
+		Id:     s.newTransactionId(),
+		Bill:   amount,
+		Time:   now.Unix(),
+		MCC:    transferMCC,
+		Status: StatusDone,
+		Type:   TypeTransfer,
+	}
+	incoming := Transaction{
+		Id:         s.newTransactionId(),
+		Bill:       -credit,
+		Time:       now.Unix(),
+		MCC:        transferMCC,
+		Status:     StatusDone,
+		Type:       TypeTransfer,
+		OriginalId: outgoing.Id,
+	}
+
+	s.checkFraud(source, outgoing)
+
+	source.Balance -= int(amount)
+	source.AddTransaction(outgoing)
+	target.Balance += int(credit)
+	target.AddTransaction(incoming)
+
+	return outgoing, nil
+}
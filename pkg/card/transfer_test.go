@@ -0,0 +1,64 @@
+package card
+
+import "testing"
+
+func TestService_Transfer(t *testing.T) {
+	type args struct {
+		from   CardId
+		to     CardId
+		amount int64
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantFrom int
+		wantTo   int
+		wantErr  error
+	}{
+		{
+			name:     "Valid transfer",
+			args:     args{from: 1, to: 2, amount: 300_00},
+			wantFrom: 700_00,
+			wantTo:   300_00,
+		},
+		{
+			name:     "Insufficient funds",
+			args:     args{from: 1, to: 2, amount: 1001_00},
+			wantFrom: 1000_00,
+			wantTo:   0,
+			wantErr:  ErrInsufficientFunds,
+		},
+		{
+			name:     "Same card",
+			args:     args{from: 1, to: 1, amount: 100_00},
+			wantFrom: 1000_00,
+			wantTo:   0,
+			wantErr:  ErrInvalidTransfer,
+		},
+		{
+			name:     "Unknown card",
+			args:     args{from: 1, to: 3, amount: 100_00},
+			wantFrom: 1000_00,
+			wantTo:   0,
+			wantErr:  ErrCardNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New("Test Bank")
+			s.CardIssue(1, "User", "User", "Visa", 1000_00, "RUB", "5106 2100 0000 0001")
+			s.CardIssue(2, "User", "User", "Visa", 0, "RUB", "5106 2100 0000 0002")
+
+			_, err := s.Transfer(tt.args.from, tt.args.to, tt.args.amount)
+			if err != tt.wantErr {
+				t.Errorf("Transfer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if s.cards[0].Balance != tt.wantFrom || s.cards[1].Balance != tt.wantTo {
+				t.Errorf("Transfer() balances = %v, %v, want %v, %v", s.cards[0].Balance, s.cards[1].Balance, tt.wantFrom, tt.wantTo)
+			}
+			if err == nil && s.cards[1].Transactions.Transactions[0].Bill != -tt.args.amount {
+				t.Errorf("Transfer() incoming = %+v", s.cards[1].Transactions.Transactions[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Package client содержит клиентов сервисов банка: текстового протокола cmd/tcpserver и HTTP cmd/webserver.
+//
+// Оба клиента реализуют интерфейс Bank. Идемпотентные вызовы (Balance, History, Export)
+// повторяются с экспоненциальной задержкой при сетевых ошибках, ошибках сервера 5xx и превышении частоты 429.
+// Transfer не повторяется, чтобы не провести перевод дважды.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"io"
+	"net"
+	"time"
+)
+
+var (
+	ErrBadRequest    = errors.New("bad request")
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrForbidden     = errors.New("forbidden")
+	ErrNotFound      = errors.New("not found")
+	ErrConflict      = errors.New("conflict")
+	ErrTooMany       = errors.New("too many requests")
+	ErrServer        = errors.New("server error")
+	ErrInvalidReply  = errors.New("invalid reply")
+	ErrInvalidFormat = errors.New("invalid export format")
+	ErrClosed        = errors.New("client closed")
+)
+
+// Формат выгрузки операций
+type Format string
+
+const (
+	FormatCsv  Format = "csv"
+	FormatJson Format = "json"
+	FormatXml  Format = "xml"
+)
+
+// Максимальная задержка между повторами
+const maxBackoff = 5 * time.Second
+
+// Баланс карты в копейках
+type Balance struct {
+	Amount   int64  `json:"balance"`
+	Currency string `json:"currency"`
+}
+
+// Операции с картами, доступные через любой транспорт.
+// Границы History передаются с точностью до дня по UTC, to не включается, нулевое время не ограничивает выборку
+type Bank interface {
+	Balance(ctx context.Context, id card.CardId) (Balance, error)
+	History(ctx context.Context, id card.CardId, from, to time.Time) ([]card.Transaction, error)
+	Transfer(ctx context.Context, from, to card.CardId, amount int64) (string, error)
+	Export(ctx context.Context, id card.CardId, format Format, w io.Writer) error
+	Close() error
+}
+
+// Настройки клиента. Нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	Timeout     time.Duration // Ограничение времени одной попытки вызова, по умолчанию 30 секунд, отрицательное отключает ограничение
+	DialTimeout time.Duration // Ограничение времени установки соединения, по умолчанию 5 секунд
+	MaxConns    int           // Максимум открытых соединений, по умолчанию 4
+	MaxRetries  int           // Количество повторов идемпотентных вызовов, по умолчанию 3, отрицательное отключает повторы
+	Backoff     time.Duration // Задержка перед первым повтором, удваивается с каждым повтором, по умолчанию 100 мс
+	// Функция установки соединения, например, с замедленной отправкой для проверки таймаутов сервера
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Настройки TLS. Клиент TCP без настроек работает без шифрования, клиент HTTP выбирает по схеме адреса
+	TLSConfig *tls.Config
+	// Токен API. Клиент TCP входит с ним командой AUTH на каждом соединении,
+	// клиент HTTP передает его в заголовке Authorization
+	Token string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout == 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = 5 * time.Second
+	}
+	if o.MaxConns <= 0 {
+		o.MaxConns = 4
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.Backoff == 0 {
+		o.Backoff = 100 * time.Millisecond
+	}
+	if o.Dial == nil {
+		dialer := &net.Dialer{Timeout: o.DialTimeout}
+		o.Dial = dialer.DialContext
+	}
+	return o
+}
+
+// Ошибка, которую вернул сервер: код статуса и текст
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// Метод сопоставления кода статуса с ошибками пакета для errors.Is
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.Code == 401:
+		return ErrUnauthorized
+	case e.Code == 403:
+		return ErrForbidden
+	case e.Code == 404:
+		return ErrNotFound
+	case e.Code == 409:
+		return ErrConflict
+	case e.Code == 429:
+		return ErrTooMany
+	case e.Code >= 400 && e.Code < 500:
+		return ErrBadRequest
+	}
+	return ErrServer
+}
+
+// Функция выполнения вызова: каждая попытка ограничена Timeout, идемпотентный вызов
+// повторяется до MaxRetries раз с удваивающейся задержкой
+func call(ctx context.Context, o Options, idempotent bool, attempt func(ctx context.Context) error) error {
+	delay := o.Backoff
+	for n := 0; ; n++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if o.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, o.Timeout)
+		}
+		err := attempt(attemptCtx)
+		cancel()
+		if err == nil || !idempotent || n >= o.MaxRetries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+	}
+}
+
+// Функция проверки, имеет ли смысл повторять вызов: ответы 4xx, кроме превышения частоты,
+// и ошибки разбора не изменятся от повтора
+func retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code >= 500 || status.Code == 429
+	}
+	return !errors.Is(err, ErrClosed) && !errors.Is(err, ErrInvalidReply)
+}
+
+// Функция записи операций в формате выгрузки
+func writeTransactions(w io.Writer, format Format, transactions []card.Transaction) error {
+	var writer card.TransactionWriter
+	switch format {
+	case FormatCsv:
+		writer = card.NewCsvTransactionWriter(w)
+	case FormatJson:
+		writer = card.NewJsonTransactionWriter(w)
+	case FormatXml:
+		writer = card.NewXmlTransactionWriter(w)
+	default:
+		return ErrInvalidFormat
+	}
+	for _, t := range transactions {
+		if err := writer.WriteTransaction(t); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
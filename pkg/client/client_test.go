@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStatusError_Is(t *testing.T) {
+	tests := []struct {
+		name string
+		code int
+		want error
+	}{
+		{name: "Bad request", code: 400, want: ErrBadRequest},
+		{name: "Method not allowed", code: 405, want: ErrBadRequest},
+		{name: "Not found", code: 404, want: ErrNotFound},
+		{name: "Conflict", code: 409, want: ErrConflict},
+		{name: "Too many requests", code: 429, want: ErrTooMany},
+		{name: "Internal error", code: 500, want: ErrServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := error(&StatusError{Code: tt.code, Message: "text"})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_call(t *testing.T) {
+	options := Options{MaxRetries: 2, Backoff: time.Millisecond}.withDefaults()
+
+	type args struct {
+		idempotent bool
+		errs       []error
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "Success",
+			args:      args{idempotent: true, errs: []error{nil}},
+			wantCalls: 1,
+		},
+		{
+			name:      "Retry network error",
+			args:      args{idempotent: true, errs: []error{io.EOF, io.EOF, nil}},
+			wantCalls: 3,
+		},
+		{
+			name:      "Retries exhausted",
+			args:      args{idempotent: true, errs: []error{io.EOF, io.EOF, io.EOF, nil}},
+			wantCalls: 3,
+			wantErr:   io.EOF,
+		},
+		{
+			name:      "Retry server error",
+			args:      args{idempotent: true, errs: []error{&StatusError{Code: 500}, nil}},
+			wantCalls: 2,
+		},
+		{
+			name:      "Retry too many requests",
+			args:      args{idempotent: true, errs: []error{&StatusError{Code: 429}, nil}},
+			wantCalls: 2,
+		},
+		{
+			name:      "No retry of client error",
+			args:      args{idempotent: true, errs: []error{&StatusError{Code: 404}, nil}},
+			wantCalls: 1,
+			wantErr:   ErrNotFound,
+		},
+		{
+			name:      "No retry of not idempotent call",
+			args:      args{idempotent: false, errs: []error{io.EOF, nil}},
+			wantCalls: 1,
+			wantErr:   io.EOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := call(context.Background(), options, tt.args.idempotent, func(ctx context.Context) error {
+				err := tt.args.errs[calls]
+				calls++
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("call() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("call() calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_call_Timeout(t *testing.T) {
+	options := Options{Timeout: 10 * time.Millisecond, MaxRetries: -1}.withDefaults()
+	err := call(context.Background(), options, true, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("call() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
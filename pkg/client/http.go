@@ -0,0 +1,245 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ограничение размера текста ошибки, который читается из ответа
+const maxErrorBody = 4096
+
+// Ссылка на следующую страницу в заголовке Link
+var nextLink = regexp.MustCompile(`<([^>]*)>\s*;\s*rel="?next"?`)
+
+// Клиент HTTP сервера cmd/webserver. Соединения переиспользуются транспортом net/http,
+// одновременно к серверу открыто не больше MaxConns соединений. Перед запросами к картам
+// нужно войти методом Login: cookie сессии сохраняется в клиенте. С токеном API в Options.Token вход не нужен
+type HTTPClient struct {
+	base    *url.URL
+	options Options
+	client  *http.Client
+}
+
+// Конструктор клиента HTTP, baseURL - адрес сервера, например, http://localhost:9999
+func NewHTTPClient(baseURL string, options Options) (*HTTPClient, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", base.Scheme)
+	}
+
+	options = options.withDefaults()
+	transport := &http.Transport{
+		DialContext:         options.Dial,
+		MaxIdleConnsPerHost: options.MaxConns,
+		MaxConnsPerHost:     options.MaxConns,
+		IdleConnTimeout:     90 * time.Second,
+		TLSClientConfig:     options.TLSConfig,
+		TLSHandshakeTimeout: options.DialTimeout,
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: transport,
+		Jar:       jar,
+		// Перенаправления сервера не выполняются: после входа он отправляет на главную страницу
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &HTTPClient{base: base, options: options, client: client}, nil
+}
+
+// Метод входа пользователя. Сервер отвечает на успешный вход перенаправлением с cookie сессии,
+// на неверный логин или пароль - статусом 401
+func (c *HTTPClient) Login(ctx context.Context, login, password string) error {
+	form := url.Values{"login": {login}, "password": {password}}
+	return call(ctx, c.options, true, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.resolve("/login", nil), strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer closeBody(resp)
+		// Страница входа с ошибкой не нужна в тексте ошибки
+		if resp.StatusCode == http.StatusUnauthorized {
+			return &StatusError{Code: resp.StatusCode, Message: "invalid login or password"}
+		}
+		if resp.StatusCode != http.StatusSeeOther {
+			return responseError(resp)
+		}
+		return nil
+	})
+}
+
+// Метод получения баланса карты
+func (c *HTTPClient) Balance(ctx context.Context, id card.CardId) (Balance, error) {
+	params := url.Values{"card": {strconv.FormatInt(int64(id), 10)}}
+
+	var balance Balance
+	err := call(ctx, c.options, true, func(ctx context.Context) error {
+		resp, err := c.do(ctx, http.MethodGet, c.resolve("/balance.json", params))
+		if err != nil {
+			return err
+		}
+		defer closeBody(resp)
+		if err = json.NewDecoder(resp.Body).Decode(&balance); err != nil {
+			return ErrInvalidReply
+		}
+		return nil
+	})
+	return balance, err
+}
+
+// Метод получения операций карты по возрастанию времени. Страницы /operations.json
+// запрашиваются по ссылкам Link с rel="next"
+func (c *HTTPClient) History(ctx context.Context, id card.CardId, from, to time.Time) ([]card.Transaction, error) {
+	params := url.Values{
+		"card":  {strconv.FormatInt(int64(id), 10)},
+		"limit": {strconv.Itoa(card.MaxSearchLimit)},
+	}
+	if !from.IsZero() {
+		params.Set("from", from.UTC().Format(dateLayout))
+	}
+	if !to.IsZero() {
+		params.Set("to", to.UTC().Format(dateLayout))
+	}
+
+	transactions := []card.Transaction{}
+	next := c.resolve("/operations.json", params)
+	for next != "" {
+		var page card.Transactions
+		var link string
+		err := call(ctx, c.options, true, func(ctx context.Context) error {
+			resp, err := c.do(ctx, http.MethodGet, next)
+			if err != nil {
+				return err
+			}
+			defer closeBody(resp)
+			page = card.Transactions{}
+			if err = json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				return ErrInvalidReply
+			}
+			link = resp.Header.Get("Link")
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, page.Transactions...)
+
+		next = ""
+		if match := nextLink.FindStringSubmatch(link); match != nil {
+			ref, err := url.Parse(match[1])
+			if err != nil {
+				return nil, ErrInvalidReply
+			}
+			next = c.base.ResolveReference(ref).String()
+		}
+	}
+	return transactions, nil
+}
+
+// Метод перевода между картами, возвращает идентификатор исходящей транзакции.
+// Перевод не повторяется: при ошибке сети его результат неизвестен
+func (c *HTTPClient) Transfer(ctx context.Context, from, to card.CardId, amount int64) (string, error) {
+	params := url.Values{
+		"from":   {strconv.FormatInt(int64(from), 10)},
+		"to":     {strconv.FormatInt(int64(to), 10)},
+		"amount": {strconv.FormatInt(amount, 10)},
+	}
+
+	var transaction card.Transaction
+	err := call(ctx, c.options, false, func(ctx context.Context) error {
+		resp, err := c.do(ctx, http.MethodPost, c.resolve("/transfer.json", params))
+		if err != nil {
+			return err
+		}
+		defer closeBody(resp)
+		if err = json.NewDecoder(resp.Body).Decode(&transaction); err != nil || transaction.Id == "" {
+			return ErrInvalidReply
+		}
+		return nil
+	})
+	return transaction.Id, err
+}
+
+// Метод выгрузки всех операций карты в формате format. Операции собираются со страниц /operations.json,
+// в которых есть все поля транзакций, и записываются одним документом: страницы /operations.csv
+// и /operations.xml - отдельные документы, склеить их нельзя
+func (c *HTTPClient) Export(ctx context.Context, id card.CardId, format Format, w io.Writer) error {
+	transactions, err := c.History(ctx, id, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	return writeTransactions(w, format, transactions)
+}
+
+// Метод закрытия простаивающих соединений
+func (c *HTTPClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *HTTPClient) resolve(path string, params url.Values) string {
+	return c.base.ResolveReference(&url.URL{Path: path, RawQuery: params.Encode()}).String()
+}
+
+// Метод выполнения запроса. Ответ со статусом не 2xx возвращается как *StatusError с текстом тела
+func (c *HTTPClient) do(ctx context.Context, method, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.options.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.options.Token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer closeBody(resp)
+	return nil, responseError(resp)
+}
+
+// Функция создания *StatusError по ответу сервера с текстом тела
+func responseError(resp *http.Response) error {
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return err
+	}
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return &StatusError{Code: resp.StatusCode, Message: message}
+}
+
+// Функция закрытия тела ответа с вычитыванием остатка, чтобы соединение вернулось в пул
+func closeBody(resp *http.Response) {
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
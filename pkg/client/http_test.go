@@ -0,0 +1,204 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestHTTPServer(t *testing.T) (*HTTPClient, *int32) {
+	var failures int32 = 1
+	mux := http.NewServeMux()
+	mux.HandleFunc("/balance.json", func(w http.ResponseWriter, r *http.Request) {
+		// Первый запрос завершается ошибкой сервера, чтобы проверить повтор
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Get("card") != "1" {
+			http.Error(w, "card not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"card_id":1,"balance":100000,"currency":"RUB"}`))
+	})
+	mux.HandleFunc("/operations.json", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if params.Get("limit") != "1000" {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if params.Get("card") != "1" {
+			http.Error(w, "card not found", http.StatusNotFound)
+			return
+		}
+		// Две страницы, ссылка на вторую передается в заголовке Link
+		if params.Get("cursor") == "" {
+			params.Set("cursor", "next")
+			w.Header().Set("Link", fmt.Sprintf(`</operations.json?%s>; rel="next"`, params.Encode()))
+			_, _ = w.Write([]byte(`{"Transactions":[{"id":"0001","bill":10000,"time":1599652800,"mcc":"5411","status":"Done"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Transactions":[{"id":"0002","bill":-5000,"time":1599739200,"mcc":"5411","status":"Done","type":"refund","original_id":"0001","currency":"USD"}]}`))
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("login") != "ivan" || r.PostFormValue("password") != "secret" {
+			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "token", Path: "/"})
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer bgo_token" {
+			_, _ = w.Write([]byte("index"))
+			return
+		}
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("index"))
+	})
+	mux.HandleFunc("/transfer.json", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Query().Get("amount") != "300" {
+			http.Error(w, "insufficient funds", http.StatusConflict)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(card.Transaction{Id: "tx-000000000001", Bill: 300})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := NewHTTPClient(server.URL, Options{Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client, &failures
+}
+
+func TestHTTPClient_Calls(t *testing.T) {
+	client, _ := newTestHTTPServer(t)
+	ctx := context.Background()
+
+	balance, err := client.Balance(ctx, 1)
+	if err != nil || balance != (Balance{Amount: 100000, Currency: "RUB"}) {
+		t.Errorf("Balance() got = %v, %v", balance, err)
+	}
+	if _, err = client.Balance(ctx, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Balance() error = %v, want %v", err, ErrNotFound)
+	}
+
+	from := time.Date(2020, 9, 9, 0, 0, 0, 0, time.UTC)
+	history, err := client.History(ctx, 1, from, time.Time{})
+	want := []card.Transaction{
+		{Id: "0001", Bill: 10000, Time: 1599652800, MCC: "5411", Status: "Done"},
+		{Id: "0002", Bill: -5000, Time: 1599739200, MCC: "5411", Status: "Done", Type: "refund", OriginalId: "0001", Currency: "USD"},
+	}
+	if err != nil || !reflect.DeepEqual(history, want) {
+		t.Errorf("History() got = %v, %v, want %v", history, err, want)
+	}
+	if _, err = client.History(ctx, 3, from, time.Time{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("History() error = %v, want %v", err, ErrNotFound)
+	}
+
+	id, err := client.Transfer(ctx, 1, 2, 300)
+	if err != nil || id != "tx-000000000001" {
+		t.Errorf("Transfer() got = %q, %v", id, err)
+	}
+	var status *StatusError
+	if _, err = client.Transfer(ctx, 2, 1, 100); !errors.As(err, &status) || status.Message != "insufficient funds" {
+		t.Errorf("Transfer() error = %v, want 409 insufficient funds", err)
+	}
+}
+
+func TestHTTPClient_Login(t *testing.T) {
+	client, _ := newTestHTTPServer(t)
+	ctx := context.Background()
+
+	if err := client.Login(ctx, "ivan", "wrong"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Login() error = %v, want %v", err, ErrUnauthorized)
+	}
+	if err := client.Login(ctx, "ivan", "secret"); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	// Cookie сессии отправляется в следующих запросах
+	resp, err := client.do(ctx, http.MethodGet, client.resolve("/", nil))
+	if err != nil {
+		t.Fatalf("request after Login() error = %v", err)
+	}
+	closeBody(resp)
+}
+
+func TestHTTPClient_Token(t *testing.T) {
+	client, _ := newTestHTTPServer(t)
+	ctx := context.Background()
+	if _, err := client.do(ctx, http.MethodGet, client.resolve("/", nil)); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("request without token error = %v, want %v", err, ErrUnauthorized)
+	}
+
+	client.options.Token = "bgo_token"
+	resp, err := client.do(ctx, http.MethodGet, client.resolve("/", nil))
+	if err != nil {
+		t.Fatalf("request with token error = %v", err)
+	}
+	closeBody(resp)
+}
+
+func TestHTTPClient_Export(t *testing.T) {
+	client, _ := newTestHTTPServer(t)
+	var out bytes.Buffer
+	if err := client.Export(context.Background(), 1, FormatJson, &out); err != nil {
+		t.Fatal(err)
+	}
+	var got card.Transactions
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil || len(got.Transactions) != 2 {
+		t.Fatalf("Export() got = %q, %v", out.String(), err)
+	}
+	// Страницы /operations.json содержат все поля, поэтому выгрузка через HTTP не теряет данных
+	if refund := got.Transactions[1]; refund.OriginalId != "0001" || refund.Currency != "USD" {
+		t.Errorf("Export() refund got = %+v", refund)
+	}
+
+	out.Reset()
+	if err := client.Export(context.Background(), 3, FormatJson, &out); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Export() error = %v, want %v", err, ErrNotFound)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Export() wrote %q after error", out.String())
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		wantErr bool
+	}{
+		{name: "Http", baseURL: "http://localhost:9999"},
+		{name: "Https", baseURL: "https://bank.example"},
+		{name: "Tcp", baseURL: "tcp://localhost:9999", wantErr: true},
+		{name: "Invalid", baseURL: "http://[::1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTTPClient(tt.baseURL, Options{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewHTTPClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Формат дат в аргументах HISTORY
+const dateLayout = "2006-01-02"
+
+// Клиент текстового протокола cmd/tcpserver с пулом соединений.
+// Каждое соединение выполняет одну команду за раз, одновременно открыто не больше MaxConns соединений
+type TCPClient struct {
+	addr    string
+	options Options
+	idle    chan *textConn
+	slots   chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Соединение пула
+type textConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Ответ сервера на команду
+type reply struct {
+	status int
+	text   string
+	lines  []string
+}
+
+// Конструктор клиента текстового протокола. Соединения устанавливаются при первых вызовах
+func NewTCPClient(addr string, options Options) *TCPClient {
+	options = options.withDefaults()
+	return &TCPClient{
+		addr:    addr,
+		options: options,
+		idle:    make(chan *textConn, options.MaxConns),
+		slots:   make(chan struct{}, options.MaxConns),
+	}
+}
+
+// Метод получения баланса карты
+func (c *TCPClient) Balance(ctx context.Context, id card.CardId) (Balance, error) {
+	var balance Balance
+	err := call(ctx, c.options, true, func(ctx context.Context) error {
+		r, err := c.do(ctx, fmt.Sprintf("BALANCE %d", id))
+		if err != nil {
+			return err
+		}
+		if len(r.lines) != 1 {
+			return ErrInvalidReply
+		}
+		fields := strings.Fields(r.lines[0])
+		if len(fields) != 2 {
+			return ErrInvalidReply
+		}
+		amount, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return ErrInvalidReply
+		}
+		balance = Balance{Amount: amount, Currency: fields[1]}
+		return nil
+	})
+	return balance, err
+}
+
+// Метод получения операций карты по возрастанию времени
+func (c *TCPClient) History(ctx context.Context, id card.CardId, from, to time.Time) ([]card.Transaction, error) {
+	command := fmt.Sprintf("HISTORY %d", id)
+	if !from.IsZero() || !to.IsZero() {
+		if from.IsZero() {
+			from = time.Unix(0, 0)
+		}
+		command += " " + from.UTC().Format(dateLayout)
+	}
+	if !to.IsZero() {
+		command += " " + to.UTC().Format(dateLayout)
+	}
+
+	var transactions []card.Transaction
+	err := call(ctx, c.options, true, func(ctx context.Context) error {
+		r, err := c.do(ctx, command)
+		if err != nil {
+			return err
+		}
+		transactions = make([]card.Transaction, 0, len(r.lines))
+		for _, line := range r.lines {
+			t, err := parseTransaction(line)
+			if err != nil {
+				return err
+			}
+			transactions = append(transactions, t)
+		}
+		return nil
+	})
+	return transactions, err
+}
+
+// Метод перевода между картами, возвращает идентификатор исходящей транзакции.
+// Перевод не повторяется: при ошибке сети его результат неизвестен
+func (c *TCPClient) Transfer(ctx context.Context, from, to card.CardId, amount int64) (string, error) {
+	var id string
+	err := call(ctx, c.options, false, func(ctx context.Context) error {
+		r, err := c.do(ctx, fmt.Sprintf("TRANSFER %d %d %d", from, to, amount))
+		if err != nil {
+			return err
+		}
+		if len(r.lines) != 1 || r.lines[0] == "" {
+			return ErrInvalidReply
+		}
+		id = r.lines[0]
+		return nil
+	})
+	return id, err
+}
+
+// Метод выгрузки всех операций карты в формате format
+func (c *TCPClient) Export(ctx context.Context, id card.CardId, format Format, w io.Writer) error {
+	transactions, err := c.History(ctx, id, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	return writeTransactions(w, format, transactions)
+}
+
+// Метод закрытия клиента и свободных соединений. Занятые соединения закрываются по завершении вызовов
+func (c *TCPClient) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	var err error
+	for {
+		select {
+		case tc := <-c.idle:
+			if cerr := tc.quit(); cerr != nil && err == nil {
+				err = cerr
+			}
+			<-c.slots
+		default:
+			return err
+		}
+	}
+}
+
+func (c *TCPClient) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// Метод выполнения команды на соединении из пула. Ответ со статусом ошибки возвращается как *StatusError
+func (c *TCPClient) do(ctx context.Context, command string) (reply, error) {
+	tc, err := c.acquire(ctx)
+	if err != nil {
+		return reply{}, err
+	}
+
+	r, err := tc.do(ctx, command)
+	c.release(tc, err == nil)
+	if err != nil {
+		return reply{}, err
+	}
+	if r.status >= 300 {
+		return reply{}, &StatusError{Code: r.status, Message: r.text}
+	}
+	return r, nil
+}
+
+// Метод получения свободного соединения или установки нового, если пул не заполнен
+func (c *TCPClient) acquire(ctx context.Context) (*textConn, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+
+	select {
+	case tc := <-c.idle:
+		return tc, nil
+	default:
+	}
+
+	select {
+	case tc := <-c.idle:
+		return tc, nil
+	case c.slots <- struct{}{}:
+		conn, err := c.dial(ctx)
+		if err != nil {
+			<-c.slots
+			return nil, err
+		}
+		tc := &textConn{conn: conn, reader: bufio.NewReader(conn)}
+		if err = c.auth(ctx, tc); err != nil {
+			_ = conn.Close()
+			<-c.slots
+			return nil, err
+		}
+		return tc, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Метод установки соединения, с настройками TLS - с рукопожатием в пределах срока ctx
+func (c *TCPClient) dial(ctx context.Context) (net.Conn, error) {
+	conn, err := c.options.Dial(ctx, "tcp", c.addr)
+	if err != nil || c.options.TLSConfig == nil {
+		return conn, err
+	}
+
+	config := c.options.TLSConfig.Clone()
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(c.addr)
+		if err != nil {
+			host = c.addr
+		}
+		config.ServerName = host
+	}
+	tlsConn := tls.Client(conn, config)
+
+	deadline, _ := ctx.Deadline()
+	if deadline.IsZero() {
+		deadline = time.Now().Add(c.options.DialTimeout)
+	}
+	if err = tlsConn.SetDeadline(deadline); err == nil {
+		err = tlsConn.Handshake()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// Метод входа на новом соединении командой AUTH, если задан токен API
+func (c *TCPClient) auth(ctx context.Context, tc *textConn) error {
+	if c.options.Token == "" {
+		return nil
+	}
+	r, err := tc.do(ctx, "AUTH "+c.options.Token)
+	if err != nil {
+		return err
+	}
+	if r.status != 200 {
+		return &StatusError{Code: r.status, Message: r.text}
+	}
+	return nil
+}
+
+// Метод возврата соединения в пул. Соединение после ошибки закрывается, потому что
+// в нем может остаться непрочитанный ответ
+func (c *TCPClient) release(tc *textConn, healthy bool) {
+	if healthy && !c.isClosed() {
+		c.idle <- tc
+		return
+	}
+	_ = tc.conn.Close()
+	<-c.slots
+}
+
+// Метод отправки команды и чтения ответа с учетом срока и отмены контекста
+func (tc *textConn) do(ctx context.Context, command string) (reply, error) {
+	deadline, _ := ctx.Deadline()
+	if err := tc.conn.SetDeadline(deadline); err != nil {
+		return reply{}, err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Прошедший срок прерывает операции чтения и записи
+			_ = tc.conn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	r, err := tc.exchange(command)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		// Срок соединения задается только контекстом, который вот-вот завершится
+		<-ctx.Done()
+	}
+	if err != nil && ctx.Err() != nil {
+		return reply{}, ctx.Err()
+	}
+	return r, err
+}
+
+func (tc *textConn) exchange(command string) (reply, error) {
+	if _, err := io.WriteString(tc.conn, command+"\r\n"); err != nil {
+		return reply{}, err
+	}
+
+	status, err := tc.reader.ReadString('\n')
+	if err != nil {
+		return reply{}, err
+	}
+	parts := strings.SplitN(strings.TrimRight(status, "\r\n"), " ", 2)
+	code, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) != 2 {
+		return reply{}, ErrInvalidReply
+	}
+
+	r := reply{status: code, text: parts[1]}
+	for {
+		line, err := tc.reader.ReadString('\n')
+		if err != nil {
+			return reply{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "." {
+			return r, nil
+		}
+		r.lines = append(r.lines, strings.TrimPrefix(line, "."))
+	}
+}
+
+// Метод завершения сеанса командой QUIT и закрытия соединения
+func (tc *textConn) quit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, _ = tc.do(ctx, "QUIT")
+	return tc.conn.Close()
+}
+
+// Функция разбора строки данных HISTORY "<id> <сумма> <время unix> <mcc> <статус> <тип> <валюта> <исходная>".
+// Строки серверов прежних версий без валюты и исходной транзакции тоже принимаются
+func parseTransaction(line string) (card.Transaction, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 6 {
+		fields = append(fields, "-", "-")
+	}
+	if len(fields) != 8 {
+		return card.Transaction{}, ErrInvalidReply
+	}
+	bill, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return card.Transaction{}, ErrInvalidReply
+	}
+	unix, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return card.Transaction{}, ErrInvalidReply
+	}
+	return card.Transaction{
+		Id:         fields[0],
+		Bill:       bill,
+		Time:       unix,
+		MCC:        fields[3],
+		Status:     fields[4],
+		Type:       dashToEmpty(fields[5]),
+		Currency:   dashToEmpty(fields[6]),
+		OriginalId: dashToEmpty(fields[7]),
+	}, nil
+}
+
+// Функция разбора пустого поля строки HISTORY, переданного как "-"
+func dashToEmpty(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
@@ -0,0 +1,321 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"github.com/ArtDark/bgo_network/pkg/tlsutil"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Сервер текстового протокола с заданными ответами на команды
+type testTCPServer struct {
+	listener net.Listener
+	replies  map[string]string
+	conns    int32
+	wg       sync.WaitGroup
+}
+
+func newTestTCPServer(t *testing.T, replies map[string]string) *testTCPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startTestTCPServer(t, listener, replies)
+}
+
+func startTestTCPServer(t *testing.T, listener net.Listener, replies map[string]string) *testTCPServer {
+	s := &testTCPServer{listener: listener, replies: replies}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *testTCPServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testTCPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.conns, 1)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				command := strings.TrimRight(line, "\r\n")
+				reply, ok := s.replies[command]
+				if !ok {
+					reply = "400 unknown command\r\n.\r\n"
+				}
+				if reply == "drop" {
+					return
+				}
+				if _, err = conn.Write([]byte(reply)); err != nil {
+					return
+				}
+				if command == "QUIT" {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func TestTCPClient_Calls(t *testing.T) {
+	server := newTestTCPServer(t, map[string]string{
+		"BALANCE 1":                       "200 OK\r\n100000 RUB\r\n.\r\n",
+		"BALANCE 3":                       "404 card not found\r\n.\r\n",
+		"HISTORY 1":                       "200 OK\r\n0001 10000 1599652800 5411 Done - - -\r\n..dot 1 2 3 Done purchase\r\n.\r\n",
+		"HISTORY 1 2020-09-10 2020-09-11": "200 OK\r\n.\r\n",
+		"HISTORY 1 1970-01-01 2020-09-11": "200 OK\r\n.\r\n",
+		"TRANSFER 1 2 300":                "200 OK\r\ntx-000000000001\r\n.\r\n",
+		"TRANSFER 2 1 300":                "409 insufficient funds\r\n.\r\n",
+		"QUIT":                            "221 BYE\r\n.\r\n",
+	})
+	client := NewTCPClient(server.addr(), Options{})
+	defer client.Close()
+	ctx := context.Background()
+
+	balance, err := client.Balance(ctx, 1)
+	if err != nil || balance != (Balance{Amount: 100000, Currency: "RUB"}) {
+		t.Errorf("Balance() got = %v, %v", balance, err)
+	}
+	if _, err = client.Balance(ctx, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Balance() error = %v, want %v", err, ErrNotFound)
+	}
+
+	history, err := client.History(ctx, 1, time.Time{}, time.Time{})
+	want := []card.Transaction{
+		{Id: "0001", Bill: 10000, Time: 1599652800, MCC: "5411", Status: "Done"},
+		{Id: ".dot", Bill: 1, Time: 2, MCC: "3", Status: "Done", Type: "purchase"},
+	}
+	if err != nil || !reflect.DeepEqual(history, want) {
+		t.Errorf("History() got = %v, %v, want %v", history, err, want)
+	}
+	from := time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	if history, err = client.History(ctx, 1, from, to); err != nil || len(history) != 0 {
+		t.Errorf("History() in range got = %v, %v", history, err)
+	}
+	if history, err = client.History(ctx, 1, time.Time{}, to); err != nil || len(history) != 0 {
+		t.Errorf("History() to got = %v, %v", history, err)
+	}
+
+	id, err := client.Transfer(ctx, 1, 2, 300)
+	if err != nil || id != "tx-000000000001" {
+		t.Errorf("Transfer() got = %q, %v", id, err)
+	}
+	if _, err = client.Transfer(ctx, 2, 1, 300); !errors.Is(err, ErrConflict) {
+		t.Errorf("Transfer() error = %v, want %v", err, ErrConflict)
+	}
+
+	var out bytes.Buffer
+	if err = client.Export(ctx, 1, FormatCsv, &out); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 3 {
+		t.Errorf("Export() got = %q", out.String())
+	}
+	if err = client.Export(ctx, 1, "pdf", &out); err != ErrInvalidFormat {
+		t.Errorf("Export() error = %v, want %v", err, ErrInvalidFormat)
+	}
+
+	// Все вызовы выполнялись по очереди, поэтому хватило одного соединения
+	if conns := atomic.LoadInt32(&server.conns); conns != 1 {
+		t.Errorf("connections got = %d, want 1", conns)
+	}
+
+	if err = client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Balance(ctx, 1); err != ErrClosed {
+		t.Errorf("Balance() after Close error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestTCPClient_Export(t *testing.T) {
+	server := newTestTCPServer(t, map[string]string{
+		"HISTORY 1": "200 OK\r\n0001 10000 1599652800 5411 Done purchase USD -\r\n0002 -5000 1599739200 5411 Done refund USD 0001\r\n.\r\n",
+		"QUIT":      "221 BYE\r\n.\r\n",
+	})
+	client := NewTCPClient(server.addr(), Options{})
+	defer client.Close()
+
+	var out bytes.Buffer
+	if err := client.Export(context.Background(), 1, FormatJson, &out); err != nil {
+		t.Fatal(err)
+	}
+	var got card.Transactions
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil || len(got.Transactions) != 2 {
+		t.Fatalf("Export() got = %q, %v", out.String(), err)
+	}
+	// Строки HISTORY содержат валюту и исходную транзакцию, поэтому выгрузка совпадает с HTTPClient
+	want := card.Transaction{Id: "0002", Bill: -5000, Time: 1599739200, MCC: "5411", Status: "Done", Type: "refund", OriginalId: "0001", Currency: "USD"}
+	if got.Transactions[1] != want {
+		t.Errorf("Export() refund got = %+v, want %+v", got.Transactions[1], want)
+	}
+}
+
+func TestTCPClient_Auth(t *testing.T) {
+	server := newTestTCPServer(t, map[string]string{
+		"AUTH good": "200 OK\r\nread:operations\r\n.\r\n",
+		"AUTH bad":  "401 invalid token\r\n.\r\n",
+		"BALANCE 1": "200 OK\r\n100000 RUB\r\n.\r\n",
+		"QUIT":      "221 BYE\r\n.\r\n",
+	})
+	ctx := context.Background()
+
+	client := NewTCPClient(server.addr(), Options{Token: "good"})
+	if balance, err := client.Balance(ctx, 1); err != nil || balance.Amount != 100000 {
+		t.Errorf("Balance() with token got = %v, %v", balance, err)
+	}
+	_ = client.Close()
+
+	// Неверный токен не повторяется и не оставляет соединение в пуле
+	client = NewTCPClient(server.addr(), Options{Token: "bad", Backoff: time.Millisecond})
+	defer client.Close()
+	if _, err := client.Balance(ctx, 1); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Balance() with invalid token error = %v, want %v", err, ErrUnauthorized)
+	}
+	if conns := atomic.LoadInt32(&server.conns); conns != 2 {
+		t.Errorf("connections got = %d, want 2", conns)
+	}
+}
+
+func TestTCPClient_Pool(t *testing.T) {
+	server := newTestTCPServer(t, map[string]string{
+		"BALANCE 1": "200 OK\r\n100 RUB\r\n.\r\n",
+		"QUIT":      "221 BYE\r\n.\r\n",
+	})
+	client := NewTCPClient(server.addr(), Options{MaxConns: 2})
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Balance(context.Background(), 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if conns := atomic.LoadInt32(&server.conns); conns > 2 {
+		t.Errorf("connections got = %d, want at most 2", conns)
+	}
+}
+
+func TestTCPClient_Retry(t *testing.T) {
+	// Сервер разрывает соединение на каждую команду
+	server := newTestTCPServer(t, map[string]string{
+		"BALANCE 1":        "drop",
+		"TRANSFER 1 2 300": "drop",
+	})
+	client := NewTCPClient(server.addr(), Options{MaxRetries: 2, Backoff: time.Millisecond})
+	defer client.Close()
+
+	if _, err := client.Balance(context.Background(), 1); err == nil {
+		t.Fatal("Balance() error = nil")
+	}
+	if conns := atomic.LoadInt32(&server.conns); conns != 3 {
+		t.Errorf("Balance() connections got = %d, want 3", conns)
+	}
+
+	if _, err := client.Transfer(context.Background(), 1, 2, 300); err == nil {
+		t.Fatal("Transfer() error = nil")
+	}
+	if conns := atomic.LoadInt32(&server.conns); conns != 4 {
+		t.Errorf("Transfer() connections got = %d, want 4", conns)
+	}
+}
+
+func TestTCPClient_Timeout(t *testing.T) {
+	// Сервер принимает соединение, но не отвечает
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			_, _ = bufio.NewReader(conn).ReadString(0)
+		}
+	}()
+
+	client := NewTCPClient(listener.Addr().String(), Options{Timeout: 50 * time.Millisecond, MaxRetries: -1})
+	defer client.Close()
+
+	start := time.Now()
+	if _, err = client.Balance(context.Background(), 1); err != context.DeadlineExceeded {
+		t.Errorf("Balance() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Balance() took %v", elapsed)
+	}
+}
+
+func TestTCPClient_TLS(t *testing.T) {
+	ca, err := tlsutil.GenerateCA("Test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ca.Issue("server", []string{"127.0.0.1"}, tlsutil.UsageServer, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate := tls.Certificate{Certificate: [][]byte{server.Cert.Raw}, PrivateKey: server.Key}
+	listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{certificate}})
+	addr := startTestTCPServer(t, listener, map[string]string{
+		"BALANCE 1": "200 OK\r\n100 RUB\r\n.\r\n",
+		"QUIT":      "221 BYE\r\n.\r\n",
+	}).addr()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	client := NewTCPClient(addr, Options{TLSConfig: &tls.Config{RootCAs: roots}})
+	defer client.Close()
+	if balance, err := client.Balance(context.Background(), 1); err != nil || balance.Amount != 100 {
+		t.Errorf("Balance() got = %v, %v", balance, err)
+	}
+
+	// Сертификат сервера не проверяется без удостоверяющего центра
+	untrusted := NewTCPClient(addr, Options{TLSConfig: &tls.Config{}, MaxRetries: -1})
+	defer untrusted.Close()
+	if _, err = untrusted.Balance(context.Background(), 1); err == nil {
+		t.Error("Balance() with untrusted certificate error = nil")
+	}
+}
@@ -0,0 +1,145 @@
+package frame
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	ErrClientClosed = errors.New("frame client closed")
+	ErrRemote       = errors.New("remote error")
+)
+
+// Клиент с мультиплексированием запросов по одному соединению.
+// Методы безопасны для вызова из нескольких горутин
+type Client struct {
+	conn io.ReadWriteCloser
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextId  uint32
+	pending map[uint32]chan Frame
+	err     error // Причина остановки чтения ответов
+	done    chan struct{}
+}
+
+// Конструктор клиента. Запускает чтение ответов из соединения
+func NewClient(conn io.ReadWriteCloser) *Client {
+	c := &Client{
+		conn:    conn,
+		pending: make(map[uint32]chan Frame),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Метод отправки запроса и ожидания ответа.
+// Ответ с типом TypeError возвращается вместе с ошибкой, оборачивающей ErrRemote
+func (c *Client) Do(ctx context.Context, payload []byte) ([]byte, error) {
+	ch := make(chan Frame, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.nextId++
+	id := c.nextId
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	err := Write(c.conn, Frame{Type: TypeRequest, RequestId: id, Payload: payload})
+	c.writeMu.Unlock()
+	if err != nil {
+		c.forget(id)
+		return nil, err
+	}
+
+	select {
+	case response := <-ch:
+		if response.Type == TypeError {
+			return nil, &RemoteError{Message: string(response.Payload)}
+		}
+		return response.Payload, nil
+	case <-c.done:
+		// Ответ мог прийти непосредственно перед остановкой чтения
+		select {
+		case response := <-ch:
+			if response.Type == TypeError {
+				return nil, &RemoteError{Message: string(response.Payload)}
+			}
+			return response.Payload, nil
+		default:
+		}
+		c.forget(id)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return nil, c.err
+	case <-ctx.Done():
+		// Поздний ответ будет отброшен циклом чтения
+		c.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) forget(id uint32) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// Метод закрытия соединения. Ожидающие запросы завершаются с ErrClientClosed
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrClientClosed
+	}
+	c.mu.Unlock()
+
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+
+	for {
+		response, err := Read(c.conn)
+		if err != nil {
+			c.mu.Lock()
+			if c.err == nil {
+				c.err = err
+			}
+			c.mu.Unlock()
+			return
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[response.RequestId]
+		delete(c.pending, response.RequestId)
+		c.mu.Unlock()
+		if ok {
+			ch <- response
+		}
+	}
+}
+
+// Ошибка, которую вернул сервер
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return "remote error: " + e.Message
+}
+
+func (e *RemoteError) Unwrap() error {
+	return ErrRemote
+}
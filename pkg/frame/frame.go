@@ -0,0 +1,98 @@
+// Package frame реализует двоичный протокол с кадрами фиксированного заголовка и длиной данных.
+//
+// Кадр (числа в порядке big endian):
+//
+//	magic      1 байт   0xFB, отличает кадры от текстовых команд
+//	version    1 байт   версия формата, сейчас 1
+//	type       1 байт   тип сообщения
+//	request id 4 байта  идентификатор запроса, ответ приходит с тем же идентификатором
+//	length     4 байта  длина данных
+//	payload    length байт
+//
+// По одному соединению может идти много запросов одновременно: ответы сопоставляются
+// с запросами по идентификатору и могут приходить в любом порядке.
+package frame
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	ErrInvalidMagic       = errors.New("invalid frame magic")
+	ErrUnsupportedVersion = errors.New("unsupported frame version")
+	ErrFrameTooLarge      = errors.New("frame payload too large")
+)
+
+const (
+	Magic      byte = 0xFB
+	Version    byte = 1
+	HeaderSize      = 11
+	MaxPayload      = 16 << 20 // Ограничение длины данных, чтобы ошибочный заголовок не занял всю память
+)
+
+// Тип сообщения
+type Type byte
+
+const (
+	TypeRequest  Type = 1 // Запрос к серверу
+	TypeResponse Type = 2 // Ответ на запрос
+	TypeError    Type = 3 // Ошибка обработки запроса, в данных - текст ошибки
+)
+
+// Кадр протокола
+type Frame struct {
+	Type      Type
+	RequestId uint32
+	Payload   []byte
+}
+
+// Функция записи кадра одним вызовом Write
+func Write(w io.Writer, f Frame) error {
+	if len(f.Payload) > MaxPayload {
+		return ErrFrameTooLarge
+	}
+
+	buf := make([]byte, HeaderSize+len(f.Payload))
+	buf[0] = Magic
+	buf[1] = Version
+	buf[2] = byte(f.Type)
+	binary.BigEndian.PutUint32(buf[3:7], f.RequestId)
+	binary.BigEndian.PutUint32(buf[7:11], uint32(len(f.Payload)))
+	copy(buf[HeaderSize:], f.Payload)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// Функция чтения кадра. Если соединение закрыто до начала кадра, возвращается io.EOF
+func Read(r io.Reader) (Frame, error) {
+	var header [HeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Frame{}, err
+	}
+	if header[0] != Magic {
+		return Frame{}, ErrInvalidMagic
+	}
+	if header[1] != Version {
+		return Frame{}, ErrUnsupportedVersion
+	}
+	length := binary.BigEndian.Uint32(header[7:11])
+	if length > MaxPayload {
+		return Frame{}, ErrFrameTooLarge
+	}
+
+	f := Frame{
+		Type:      Type(header[2]),
+		RequestId: binary.BigEndian.Uint32(header[3:7]),
+		Payload:   make([]byte, length),
+	}
+	if _, err := io.ReadFull(r, f.Payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+	return f, nil
+}
@@ -0,0 +1,263 @@
+package frame
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteRead(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+	}{
+		{name: "Text with newlines", frame: Frame{Type: TypeRequest, RequestId: 1, Payload: []byte("line 1\nline 2\n")}},
+		{name: "Binary", frame: Frame{Type: TypeResponse, RequestId: 0xFFFFFFFF, Payload: []byte{0, 0xFB, 0xFF, '\n', 0}}},
+		{name: "Empty payload", frame: Frame{Type: TypeError, RequestId: 7, Payload: []byte{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.frame); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() != HeaderSize+len(tt.frame.Payload) {
+				t.Errorf("Write() got %v bytes", buf.Len())
+			}
+			got, err := Read(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.frame) {
+				t.Errorf("Read() got = %+v, want %+v", got, tt.frame)
+			}
+		})
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	valid := func() []byte {
+		var buf bytes.Buffer
+		_ = Write(&buf, Frame{Type: TypeRequest, RequestId: 1, Payload: []byte("BALANCE 1")})
+		return buf.Bytes()
+	}
+	tests := []struct {
+		name    string
+		data    func() []byte
+		wantErr error
+	}{
+		{name: "Empty", data: func() []byte { return nil }, wantErr: io.EOF},
+		{name: "Text", data: func() []byte { return []byte("BALANCE 1\r\n") }, wantErr: ErrInvalidMagic},
+		{
+			name:    "Version",
+			data:    func() []byte { b := valid(); b[1] = 2; return b },
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:    "Too large",
+			data:    func() []byte { b := valid(); b[7] = 0xFF; return b },
+			wantErr: ErrFrameTooLarge,
+		},
+		{
+			name:    "Truncated payload",
+			data:    func() []byte { b := valid(); return b[:len(b)-1] },
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "Truncated header",
+			data:    func() []byte { return valid()[:5] },
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(bytes.NewReader(tt.data())); err != tt.wantErr {
+				t.Errorf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := Write(io.Discard, Frame{Payload: make([]byte, MaxPayload+1)}); err != ErrFrameTooLarge {
+		t.Errorf("Write() error = %v, wantErr %v", err, ErrFrameTooLarge)
+	}
+}
+
+// Функция запуска сервера, который отвечает на "sleep N" через N миллисекунд, а на "fail" - ошибкой
+func startServer(t *testing.T) *Client {
+	serverConn, clientConn := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(serverConn, serverConn, func(request Frame) (Type, []byte) {
+			var delay int
+			if _, err := fmt.Sscanf(string(request.Payload), "sleep %d", &delay); err == nil {
+				time.Sleep(time.Duration(delay) * time.Millisecond)
+			}
+			if string(request.Payload) == "fail" {
+				return TypeError, []byte("failed")
+			}
+			return TypeResponse, append([]byte("echo "), request.Payload...)
+		})
+		_ = serverConn.Close()
+	}()
+
+	client := NewClient(clientConn)
+	t.Cleanup(func() {
+		_ = client.Close()
+		<-done
+	})
+	return client
+}
+
+func TestClient_Multiplexing(t *testing.T) {
+	client := startServer(t)
+
+	// Первый запрос отвечает последним: ответы сопоставляются по идентификатору, а не по порядку
+	var wg sync.WaitGroup
+	order := make(chan string, 3)
+	for _, delay := range []int{150, 50, 0} {
+		wg.Add(1)
+		go func(delay int) {
+			defer wg.Done()
+			payload := fmt.Sprintf("sleep %d", delay)
+			got, err := client.Do(context.Background(), []byte(payload))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(got) != "echo "+payload {
+				t.Errorf("Do() got = %q, want %q", got, "echo "+payload)
+			}
+			order <- payload
+		}(delay)
+	}
+	wg.Wait()
+	close(order)
+
+	if first := <-order; first != "sleep 0" {
+		t.Errorf("first response got = %q, want the fastest request", first)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	client := startServer(t)
+
+	_, err := client.Do(context.Background(), []byte("fail"))
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Message != "failed" || !errors.Is(err, ErrRemote) {
+		t.Errorf("Do() error = %v, want remote error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Do(ctx, []byte("sleep 200")); err != context.DeadlineExceeded {
+		t.Errorf("Do() error = %v, wantErr %v", err, context.DeadlineExceeded)
+	}
+
+	// Поздний ответ на отмененный запрос не мешает следующим
+	got, err := client.Do(context.Background(), []byte("ping"))
+	if err != nil || string(got) != "echo ping" {
+		t.Errorf("Do() got = %q, %v", got, err)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(context.Background(), []byte("ping")); err != ErrClientClosed {
+		t.Errorf("Do() error = %v, wantErr %v", err, ErrClientClosed)
+	}
+}
+
+func TestServe_MaxInFlight(t *testing.T) {
+	r, w := io.Pipe()
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var active, peak int
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(r, io.Discard, func(request Frame) (Type, []byte) {
+			mu.Lock()
+			active++
+			if active > peak {
+				peak = active
+			}
+			mu.Unlock()
+
+			<-release
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return TypeResponse, nil
+		})
+	}()
+	go func() {
+		for i := 0; i < maxInFlight+5; i++ {
+			_ = Write(w, Frame{Type: TypeRequest, RequestId: uint32(i + 1)})
+		}
+		_ = w.Close()
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		got := active
+		mu.Unlock()
+		if got == maxInFlight {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("active handlers got = %v, want %v", got, maxInFlight)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Лишние запросы ждут, пока освободится место
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if err := <-done; err != io.EOF {
+		t.Errorf("Serve() error = %v, wantErr %v", err, io.EOF)
+	}
+	if peak != maxInFlight {
+		t.Errorf("peak handlers got = %v, want %v", peak, maxInFlight)
+	}
+}
+
+// Запись, которая всегда завершается ошибкой
+type failingWriter struct{}
+
+var errWriteFailed = errors.New("write failed")
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+func TestServe_WriteError(t *testing.T) {
+	r, w := io.Pipe()
+	t.Cleanup(func() { _ = w.Close() })
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(r, failingWriter{}, func(request Frame) (Type, []byte) {
+			return TypeResponse, request.Payload
+		})
+	}()
+	if err := Write(w, Frame{Type: TypeRequest, RequestId: 1, Payload: []byte("ping")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Serve завершается сразу, не дожидаясь следующего запроса
+	select {
+	case err := <-done:
+		if err != errWriteFailed {
+			t.Errorf("Serve() error = %v, wantErr %v", err, errWriteFailed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve() did not stop after write error")
+	}
+}
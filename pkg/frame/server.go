@@ -0,0 +1,84 @@
+package frame
+
+import (
+	"io"
+	"sync"
+)
+
+// Сколько запросов одного соединения обрабатываются одновременно. Следующие запросы
+// не читаются, пока не освободится место, поэтому клиент не может занять сервер без ограничений
+const maxInFlight = 16
+
+// Обработчик запроса. Возвращает тип и данные ответа
+type Handler func(request Frame) (Type, []byte)
+
+// Функция обслуживания соединения: каждый запрос обрабатывается в отдельной горутине,
+// но не больше maxInFlight одновременно, ответы пишутся по мере готовности.
+// Возвращает ошибку чтения или первую ошибку записи после завершения всех обработчиков,
+// io.EOF означает штатное закрытие соединения клиентом. После ошибки записи новые запросы
+// не обрабатываются, а прерванное чтение завершится, когда вызывающий закроет соединение
+func Serve(r io.Reader, w io.Writer, handler Handler) error {
+	var mu sync.Mutex // Кадры ответов не должны перемешиваться
+	var writeErr error
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Чтение вынесено в горутину, чтобы ошибка записи останавливала Serve, не дожидаясь следующего запроса
+	requests := make(chan Frame)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			request, err := Read(r)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case requests <- request:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	failed := make(chan error, 1)
+	slots := make(chan struct{}, maxInFlight)
+	for {
+		var request Frame
+		select {
+		case err := <-readErr:
+			return err
+		case err := <-failed:
+			return err
+		case request = <-requests:
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case err := <-failed:
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			typ, payload := TypeError, []byte("unexpected frame type")
+			if request.Type == TypeRequest {
+				typ, payload = handler(request)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if writeErr != nil {
+				return
+			}
+			if writeErr = Write(w, Frame{Type: typ, RequestId: request.RequestId, Payload: payload}); writeErr != nil {
+				failed <- writeErr
+			}
+		}()
+	}
+}
@@ -0,0 +1,254 @@
+// Package netutil содержит общий для серверов цикл приема соединений
+// с ограничением их числа и задержкой после временных ошибок Accept.
+package netutil
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTooManyConnections       = errors.New("too many connections")
+	ErrTooManyConnectionsFromIP = errors.New("too many connections from address")
+)
+
+// Задержки после временной ошибки Accept: начальная и максимальная
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// Максимум одновременно выполняемых обработчиков отказа. Сверх него соединение закрывается без ответа
+const maxRejecting = 64
+
+// Ограничения соединений. Нулевое значение поля снимает ограничение
+type Limits struct {
+	MaxConns      int           // Максимум одновременно обслуживаемых соединений
+	MaxConnsPerIP int           // Максимум одновременно обслуживаемых и ожидающих соединений с одного адреса
+	QueueTimeout  time.Duration // Сколько соединение ждет свободного места, прежде чем получить отказ
+	MaxQueued     int           // Максимум соединений, ожидающих места, 0 - не больше MaxConns
+	IdleTimeout   time.Duration // Сколько можно ждать данных от клиента, прежде чем чтение завершится ошибкой
+}
+
+// Обработчик принятого соединения, закрывает соединение сам
+type Handler func(conn net.Conn)
+
+// Обработчик отказа: сообщает клиенту причину err. Соединение закрывается после его завершения
+type RejectHandler func(conn net.Conn, err error)
+
+// Учет обслуживаемых соединений
+type limiter struct {
+	limits    Limits
+	slots     chan struct{}
+	rejecting chan struct{}
+
+	mu     sync.Mutex
+	perIP  map[string]int
+	queued int
+}
+
+func newLimiter(limits Limits) *limiter {
+	l := &limiter{limits: limits, perIP: make(map[string]int), rejecting: make(chan struct{}, maxRejecting)}
+	if limits.MaxConns > 0 {
+		l.slots = make(chan struct{}, limits.MaxConns)
+	}
+	return l
+}
+
+// Функция приема соединений из listener. Каждое соединение обслуживается handle в отдельной горутине.
+// Соединения сверх MaxConnsPerIP получают отказ через reject сразу. Если все места заняты, соединение
+// ждет освобождения до QueueTimeout в отдельной горутине, не задерживая прием следующих, затем получает
+// отказ. Когда ожидающих уже MaxQueued, отказ тоже приходит сразу. С IdleTimeout чтение из соединения
+// завершается ошибкой, если клиент столько времени ничего не присылает. После временной ошибки Accept
+// цикл делает паузу, удваивая ее до секунды при повторных ошибках. Функция завершается при постоянной
+// ошибке Accept, например, после закрытия listener
+func Serve(listener net.Listener, limits Limits, handle Handler, reject RejectHandler) error {
+	l := newLimiter(limits)
+
+	var delay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				delay *= 2
+				if delay == 0 {
+					delay = minAcceptDelay
+				}
+				if delay > maxAcceptDelay {
+					delay = maxAcceptDelay
+				}
+				log.Printf("accept error: %v; retrying in %v", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		l.serve(conn, handle, reject)
+	}
+}
+
+// Метод распределения принятого соединения: обслуживание, ожидание места или отказ. Не блокируется
+func (l *limiter) serve(conn net.Conn, handle Handler, reject RejectHandler) {
+	ip := RemoteIP(conn)
+	if !l.acquireIP(ip) {
+		l.reject(conn, reject, ErrTooManyConnectionsFromIP)
+		return
+	}
+
+	if l.tryAcquire() {
+		go l.handle(conn, ip, handle)
+		return
+	}
+	if !l.enqueue() {
+		l.releaseIP(ip)
+		l.reject(conn, reject, ErrTooManyConnections)
+		return
+	}
+	go func() {
+		err := l.wait()
+		l.dequeue()
+		if err != nil {
+			l.releaseIP(ip)
+			l.reject(conn, reject, err)
+			return
+		}
+		l.handle(conn, ip, handle)
+	}()
+}
+
+// Метод обслуживания соединения на занятом месте, освобождает место после завершения handle
+func (l *limiter) handle(conn net.Conn, ip string, handle Handler) {
+	defer func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+		l.releaseIP(ip)
+	}()
+	if l.limits.IdleTimeout > 0 {
+		conn = &idleConn{Conn: conn, timeout: l.limits.IdleTimeout}
+	}
+	handle(conn)
+}
+
+// Метод отказа в отдельной горутине. Число одновременных отказов ограничено maxRejecting
+func (l *limiter) reject(conn net.Conn, reject RejectHandler, cause error) {
+	select {
+	case l.rejecting <- struct{}{}:
+	default:
+		closeConn(conn)
+		return
+	}
+	go func() {
+		defer func() { <-l.rejecting }()
+		defer closeConn(conn)
+		reject(conn, cause)
+	}()
+}
+
+// Метод учета соединения с адреса ip, false - адрес исчерпал MaxConnsPerIP
+func (l *limiter) acquireIP(ip string) bool {
+	if l.limits.MaxConnsPerIP <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perIP[ip] >= l.limits.MaxConnsPerIP {
+		return false
+	}
+	l.perIP[ip]++
+	return true
+}
+
+// Метод занятия свободного места без ожидания
+func (l *limiter) tryAcquire() bool {
+	if l.slots == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Метод постановки соединения в очередь ожидания, false - очередь заполнена или отключена
+func (l *limiter) enqueue() bool {
+	if l.limits.QueueTimeout <= 0 {
+		return false
+	}
+	max := l.limits.MaxQueued
+	if max <= 0 {
+		max = l.limits.MaxConns
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.queued >= max {
+		return false
+	}
+	l.queued++
+	return true
+}
+
+func (l *limiter) dequeue() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queued--
+}
+
+// Метод ожидания свободного места не дольше QueueTimeout
+func (l *limiter) wait() error {
+	timer := time.NewTimer(l.limits.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrTooManyConnections
+	}
+}
+
+func (l *limiter) releaseIP(ip string) {
+	if l.limits.MaxConnsPerIP <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+// Соединение, чтение из которого ждет данных не дольше timeout
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+func closeConn(conn net.Conn) {
+	if err := conn.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+// Функция получения адреса клиента без порта
+func RemoteIP(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
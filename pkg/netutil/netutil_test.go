@@ -0,0 +1,284 @@
+package netutil
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// Слушатель, который возвращает заданные ошибки, затем соединения из канала
+type testListener struct {
+	errs  []error
+	conns chan net.Conn
+}
+
+func (l *testListener) Accept() (net.Conn, error) {
+	if len(l.errs) != 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+		return nil, err
+	}
+	conn, ok := <-l.conns
+	if !ok {
+		return nil, errClosed
+	}
+	return conn, nil
+}
+
+func (l *testListener) Close() error   { return nil }
+func (l *testListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+var errClosed = errors.New("use of closed network connection")
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// Соединение с заданным адресом клиента
+type testConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c testConn) RemoteAddr() net.Addr { return c.remote }
+
+func newTestConn(t *testing.T, ip string) net.Conn {
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+	return testConn{Conn: server, remote: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}}
+}
+
+// Результат обслуживания соединения: nil - принято, иначе причина отказа
+type outcome struct {
+	conn net.Conn
+	err  error
+}
+
+func serve(limits Limits, listener *testListener) (chan outcome, chan struct{}, chan error) {
+	outcomes := make(chan outcome, 16)
+	releases := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(listener, limits, func(conn net.Conn) {
+			outcomes <- outcome{conn: conn}
+			<-releases
+		}, func(conn net.Conn, err error) {
+			outcomes <- outcome{conn: conn, err: err}
+		})
+	}()
+	return outcomes, releases, done
+}
+
+func TestServe_Limits(t *testing.T) {
+	type args struct {
+		limits Limits
+		ips    []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []error
+	}{
+		{
+			name: "Without limits",
+			args: args{ips: []string{"10.0.0.1", "10.0.0.1", "10.0.0.2"}},
+			want: []error{nil, nil, nil},
+		},
+		{
+			name: "Max connections",
+			args: args{limits: Limits{MaxConns: 2}, ips: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+			want: []error{nil, nil, ErrTooManyConnections},
+		},
+		{
+			name: "Max connections per ip",
+			args: args{limits: Limits{MaxConnsPerIP: 1}, ips: []string{"10.0.0.1", "10.0.0.1", "10.0.0.2"}},
+			want: []error{nil, ErrTooManyConnectionsFromIP, nil},
+		},
+		{
+			name: "Queue timeout",
+			args: args{limits: Limits{MaxConns: 1, QueueTimeout: 10 * time.Millisecond}, ips: []string{"10.0.0.1", "10.0.0.2"}},
+			want: []error{nil, ErrTooManyConnections},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := &testListener{conns: make(chan net.Conn)}
+			outcomes, releases, done := serve(tt.args.limits, listener)
+
+			for i, ip := range tt.args.ips {
+				listener.conns <- newTestConn(t, ip)
+				if got := <-outcomes; got.err != tt.want[i] {
+					t.Errorf("connection %d got = %v, want %v", i, got.err, tt.want[i])
+				}
+			}
+
+			close(listener.conns)
+			close(releases)
+			if err := <-done; err != errClosed {
+				t.Errorf("Serve() error = %v, want %v", err, errClosed)
+			}
+		})
+	}
+}
+
+func TestServe_Queue(t *testing.T) {
+	listener := &testListener{conns: make(chan net.Conn)}
+	outcomes, releases, done := serve(Limits{MaxConns: 1, MaxConnsPerIP: 1, QueueTimeout: time.Minute}, listener)
+
+	listener.conns <- newTestConn(t, "10.0.0.1")
+	if got := <-outcomes; got.err != nil {
+		t.Fatalf("first connection got = %v", got.err)
+	}
+
+	// Второе соединение ждет, пока первое не освободит место
+	listener.conns <- newTestConn(t, "10.0.0.2")
+	select {
+	case got := <-outcomes:
+		t.Fatalf("second connection served before release: %v", got.err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	releases <- struct{}{}
+	if got := <-outcomes; got.err != nil {
+		t.Errorf("second connection got = %v", got.err)
+	}
+
+	// Место адреса первого соединения освобождено
+	releases <- struct{}{}
+	listener.conns <- newTestConn(t, "10.0.0.1")
+	if got := <-outcomes; got.err != nil {
+		t.Errorf("third connection got = %v", got.err)
+	}
+
+	close(listener.conns)
+	close(releases)
+	<-done
+}
+
+func TestServe_AcceptBackoff(t *testing.T) {
+	listener := &testListener{
+		errs:  []error{temporaryError{}, temporaryError{}, temporaryError{}},
+		conns: make(chan net.Conn),
+	}
+	close(listener.conns)
+
+	start := time.Now()
+	err := Serve(listener, Limits{}, func(net.Conn) {}, func(net.Conn, error) {})
+	if err != errClosed {
+		t.Errorf("Serve() error = %v, want %v", err, errClosed)
+	}
+	// 5 + 10 + 20 мс
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("Serve() returned after %v, want backoff at least 35ms", elapsed)
+	}
+}
+
+func TestServe_QueueLimit(t *testing.T) {
+	listener := &testListener{conns: make(chan net.Conn)}
+	outcomes, releases, done := serve(Limits{MaxConns: 1, MaxConnsPerIP: 1, QueueTimeout: time.Minute, MaxQueued: 1}, listener)
+
+	listener.conns <- newTestConn(t, "10.0.0.1")
+	if got := <-outcomes; got.err != nil {
+		t.Fatalf("first connection got = %v", got.err)
+	}
+	// Второе соединение ждет места, но не задерживает прием следующих
+	listener.conns <- newTestConn(t, "10.0.0.2")
+
+	tests := []struct {
+		name string
+		ip   string
+		want error
+	}{
+		{name: "Queue is full", ip: "10.0.0.3", want: ErrTooManyConnections},
+		{name: "Address of queued connection", ip: "10.0.0.2", want: ErrTooManyConnectionsFromIP},
+	}
+	for _, tt := range tests {
+		listener.conns <- newTestConn(t, tt.ip)
+		select {
+		case got := <-outcomes:
+			if got.err != tt.want {
+				t.Errorf("%s: got = %v, want %v", tt.name, got.err, tt.want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: no immediate reject", tt.name)
+		}
+	}
+
+	releases <- struct{}{}
+	if got := <-outcomes; got.err != nil {
+		t.Errorf("queued connection got = %v", got.err)
+	}
+
+	close(listener.conns)
+	close(releases)
+	<-done
+}
+
+func TestServe_RejectLimit(t *testing.T) {
+	listener := &testListener{conns: make(chan net.Conn)}
+	rejected := make(chan struct{}, maxRejecting+1)
+	unblock := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(listener, Limits{MaxConnsPerIP: 1}, func(net.Conn) { <-unblock }, func(net.Conn, error) {
+			rejected <- struct{}{}
+			<-unblock
+		})
+	}()
+
+	// Первое соединение занимает место адреса, следующие получают отказ
+	server, client := net.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	listener.conns <- testConn{Conn: server, remote: &net.TCPAddr{IP: net.ParseIP("10.0.0.1")}}
+	for i := 0; i < maxRejecting; i++ {
+		listener.conns <- newTestConn(t, "10.0.0.1")
+		<-rejected
+	}
+
+	// Сверх maxRejecting соединение закрывается без вызова reject
+	server, client = net.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	listener.conns <- testConn{Conn: server, remote: &net.TCPAddr{IP: net.ParseIP("10.0.0.1")}}
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("connection over reject limit is not closed")
+	}
+	select {
+	case <-rejected:
+		t.Error("reject called over limit")
+	default:
+	}
+
+	close(unblock)
+	close(listener.conns)
+	<-done
+}
+
+func TestServe_IdleTimeout(t *testing.T) {
+	listener := &testListener{conns: make(chan net.Conn)}
+	errs := make(chan error, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(listener, Limits{IdleTimeout: 20 * time.Millisecond}, func(conn net.Conn) {
+			_, err := conn.Read(make([]byte, 1))
+			errs <- err
+		}, func(net.Conn, error) {})
+	}()
+
+	listener.conns <- newTestConn(t, "10.0.0.1")
+	select {
+	case err := <-errs:
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Errorf("Read() error = %v, want timeout", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Read() of idle connection is not interrupted")
+	}
+
+	close(listener.conns)
+	<-done
+}
@@ -0,0 +1,141 @@
+// Package ratelimit ограничивает частоту запросов клиентов алгоритмом token bucket.
+//
+// У каждой пары (маршрут, ключ клиента) своя корзина: она вмещает Burst токенов и пополняется
+// со скоростью Rate токенов в секунду, каждый запрос забирает один токен. Ключом клиента служит
+// его адрес или имя пользователя, маршрутом - путь HTTP или команда протокола TCP.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Как часто удаляются корзины, которые успели наполниться и не отличаются от новых
+const sweepInterval = time.Minute
+
+// Источник текущего времени, например, card.SystemClock
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Ограничение маршрута. Нулевое Rate снимает ограничение
+type Limit struct {
+	Rate  float64 // Токенов в секунду
+	Burst int     // Вместимость корзины, не меньше 1
+}
+
+// Ограничитель частоты запросов. Методы безопасны для вызова из нескольких горутин
+type Limiter struct {
+	Clock Clock
+
+	limits   map[string]Limit
+	fallback Limit
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	route string
+	key   string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Конструктор ограничителя с ограничениями маршрутов limits.
+// Для маршрутов, которых нет в limits, действует fallback
+func New(limits map[string]Limit, fallback Limit) *Limiter {
+	copied := make(map[string]Limit, len(limits))
+	for route, limit := range limits {
+		copied[route] = limit
+	}
+	return &Limiter{
+		Clock:    systemClock{},
+		limits:   copied,
+		fallback: fallback,
+		buckets:  make(map[bucketKey]*bucket),
+	}
+}
+
+// Метод проверки запроса клиента key к маршруту route. Если запрос разрешен, забирает токен.
+// Если нет, возвращает время, через которое появится следующий токен
+func (l *Limiter) Allow(route, key string) (bool, time.Duration) {
+	limit := l.limit(route)
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+	burst := limit.burst()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Clock.Now()
+	l.sweep(now)
+
+	k := bucketKey{route: route, key: key}
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[k] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*limit.Rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+func (limit Limit) burst() float64 {
+	if limit.Burst < 1 {
+		return 1
+	}
+	return float64(limit.Burst)
+}
+
+func (l *Limiter) limit(route string) Limit {
+	if limit, ok := l.limits[route]; ok {
+		return limit
+	}
+	return l.fallback
+}
+
+// Метод удаления корзин, которые наполнились бы к моменту now
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for k, b := range l.buckets {
+		limit := l.limit(k.route)
+		if b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= limit.burst() {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// Функция округления задержки до целых секунд вверх для заголовка Retry-After
+func RetryAfterSeconds(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
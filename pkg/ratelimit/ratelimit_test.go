@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"github.com/ArtDark/bgo_network/pkg/card"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	type step struct {
+		advance   time.Duration
+		route     string
+		key       string
+		want      bool
+		wantRetry time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "Burst then refill",
+			steps: []step{
+				{route: "/operations", key: "a", want: true},
+				{route: "/operations", key: "a", want: true},
+				{route: "/operations", key: "a", want: false, wantRetry: 2 * time.Second},
+				{advance: time.Second, route: "/operations", key: "a", want: false, wantRetry: time.Second},
+				{advance: time.Second, route: "/operations", key: "a", want: true},
+				{route: "/operations", key: "a", want: false, wantRetry: 2 * time.Second},
+			},
+		},
+		{
+			name: "Keys are independent",
+			steps: []step{
+				{route: "/operations", key: "a", want: true},
+				{route: "/operations", key: "a", want: true},
+				{route: "/operations", key: "a", want: false, wantRetry: 2 * time.Second},
+				{route: "/operations", key: "b", want: true},
+			},
+		},
+		{
+			name: "Routes are independent",
+			steps: []step{
+				{route: "/operations", key: "a", want: true},
+				{route: "/operations", key: "a", want: true},
+				{route: "/", key: "a", want: true},
+				{route: "/", key: "a", want: false, wantRetry: 100 * time.Millisecond},
+			},
+		},
+		{
+			name: "Unlimited route",
+			steps: []step{
+				{route: "/free", key: "a", want: true},
+				{route: "/free", key: "a", want: true},
+				{route: "/free", key: "a", want: true},
+			},
+		},
+		{
+			name: "Refill does not exceed burst",
+			steps: []step{
+				{advance: time.Hour, route: "/operations", key: "a", want: true},
+				{route: "/operations", key: "a", want: true},
+				{route: "/operations", key: "a", want: false, wantRetry: 2 * time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := card.NewManualClock(time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC))
+			l := New(map[string]Limit{
+				"/operations": {Rate: 0.5, Burst: 2},
+				"/free":       {},
+			}, Limit{Rate: 10, Burst: 1})
+			l.Clock = clock
+
+			for i, s := range tt.steps {
+				clock.Advance(s.advance)
+				got, retry := l.Allow(s.route, s.key)
+				if got != s.want || retry != s.wantRetry {
+					t.Errorf("step %d Allow() got = %v, %v, want %v, %v", i, got, retry, s.want, s.wantRetry)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiter_sweep(t *testing.T) {
+	clock := card.NewManualClock(time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC))
+	l := New(nil, Limit{Rate: 1, Burst: 5})
+	l.Clock = clock
+
+	for i := 0; i < 100; i++ {
+		l.Allow("/", string(rune('a'+i%26))+string(rune('a'+i/26)))
+	}
+	clock.Advance(2 * sweepInterval)
+	l.Allow("/", "last")
+
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets after sweep got = %d, want 1", len(l.buckets))
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		wait time.Duration
+		want int
+	}{
+		{wait: 0, want: 1},
+		{wait: 100 * time.Millisecond, want: 1},
+		{wait: time.Second, want: 1},
+		{wait: 1500 * time.Millisecond, want: 2},
+	}
+	for _, tt := range tests {
+		if got := RetryAfterSeconds(tt.wait); got != tt.want {
+			t.Errorf("RetryAfterSeconds(%v) = %d, want %d", tt.wait, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"time"
+)
+
+// Сертификат с закрытым ключом
+type Pair struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// Назначение выпускаемого сертификата
+type Usage int
+
+const (
+	UsageServer Usage = iota
+	UsageClient
+)
+
+// Функция создания самоподписанного удостоверяющего центра для разработки
+func GenerateCA(commonName string, validFor time.Duration) (*Pair, error) {
+	template, key, err := newTemplate(commonName, validFor)
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	return sign(template, template, key, key)
+}
+
+// Метод выпуска сертификата сервера или клиента, подписанного удостоверяющим центром.
+// hosts - имена и IP адреса сервера, для сертификата клиента не нужны
+func (ca *Pair) Issue(commonName string, hosts []string, usage Usage, validFor time.Duration) (*Pair, error) {
+	template, key, err := newTemplate(commonName, validFor)
+	if err != nil {
+		return nil, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	if usage == UsageClient {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	return sign(template, ca.Cert, key, ca.Key)
+}
+
+// Метод получения сертификата в формате .pem
+func (p *Pair) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.Cert.Raw})
+}
+
+// Метод получения закрытого ключа в формате .pem
+func (p *Pair) KeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(p.Key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// Метод записи сертификата и ключа в файлы. Ключ доступен только владельцу
+func (p *Pair) WriteFiles(certFile, keyFile string) error {
+	if err := ioutil.WriteFile(certFile, p.CertPEM(), 0644); err != nil {
+		return err
+	}
+	key, err := p.KeyPEM()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyFile, key, 0600)
+}
+
+func newTemplate(commonName string, validFor time.Duration) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Bank dev"}},
+		NotBefore:    now.Add(-time.Hour), // Запас на расхождение часов
+		NotAfter:     now.Add(validFor),
+	}, key, nil
+}
+
+func sign(template, parent *x509.Certificate, key, parentKey crypto.Signer) (*Pair, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Pair{Cert: cert, Key: key}, nil
+}
@@ -0,0 +1,94 @@
+package tlsutil
+
+import (
+	"crypto/x509"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPair_Issue(t *testing.T) {
+	ca, err := GenerateCA("Test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.Cert.IsCA {
+		t.Error("GenerateCA() certificate is not CA")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+
+	type args struct {
+		hosts []string
+		usage Usage
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantDNS   []string
+		wantIPs   int
+		wantUsage x509.ExtKeyUsage
+	}{
+		{
+			name:      "Server",
+			args:      args{hosts: []string{"localhost", "127.0.0.1", "::1"}, usage: UsageServer},
+			wantDNS:   []string{"localhost"},
+			wantIPs:   2,
+			wantUsage: x509.ExtKeyUsageServerAuth,
+		},
+		{
+			name:      "Client",
+			args:      args{usage: UsageClient},
+			wantUsage: x509.ExtKeyUsageClientAuth,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := ca.Issue(tt.name, tt.args.hosts, tt.args.usage, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pair.Cert.DNSNames, tt.wantDNS) || len(pair.Cert.IPAddresses) != tt.wantIPs {
+				t.Errorf("Issue() hosts got = %v %v", pair.Cert.DNSNames, pair.Cert.IPAddresses)
+			}
+			_, err = pair.Cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{tt.wantUsage}})
+			if err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+			if pair.Cert.IsCA {
+				t.Error("Issue() certificate is CA")
+			}
+		})
+	}
+}
+
+func TestPair_WriteFiles(t *testing.T) {
+	ca, err := GenerateCA("Test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err = ca.WriteFiles(dir+"/ca.pem", dir+"/ca-key.pem"); err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err := LoadCertPool(dir + "/ca.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ca.Issue("server", []string{"127.0.0.1"}, UsageServer, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = server.Cert.Verify(x509.VerifyOptions{Roots: pool})
+	if err != nil {
+		t.Errorf("Verify() with loaded pool error = %v", err)
+	}
+	if ip := server.Cert.IPAddresses[0]; !ip.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("IPAddresses got = %v", ip)
+	}
+	if _, err = LoadCertPool(dir + "/ca-key.pem"); err != ErrNoCertificates {
+		t.Errorf("LoadCertPool() of key error = %v, want %v", err, ErrNoCertificates)
+	}
+}
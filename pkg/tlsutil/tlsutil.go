@@ -0,0 +1,153 @@
+// Package tlsutil содержит настройку TLS для серверов: сертификаты, которые перечитываются
+// с диска без перезапуска, и выпуск самоподписанных сертификатов для разработки.
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+var (
+	ErrNoCertificates = errors.New("no certificates in file")
+	ErrInvalidFiles   = errors.New("tls certificate and key files must be set together, client ca requires them")
+)
+
+// Файлы сертификатов сервера. Пустой ClientCAFile отключает проверку сертификатов клиентов
+type Files struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // Сертификаты удостоверяющих центров, которыми подписаны сертификаты клиентов
+}
+
+// Метод проверки, что файлы заданы согласованно. Пустые Files означают работу без TLS
+func (f Files) Validate() error {
+	if (f.CertFile == "") != (f.KeyFile == "") || f.ClientCAFile != "" && f.CertFile == "" {
+		return ErrInvalidFiles
+	}
+	return nil
+}
+
+// Источник настроек TLS, которые перечитываются из файлов методом Reload.
+// Новые настройки применяются к следующим соединениям, установленные соединения не разрываются
+type Reloader struct {
+	files Files
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// Конструктор источника настроек, сразу читает файлы
+func NewReloader(files Files) (*Reloader, error) {
+	r := &Reloader{files: files}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Метод чтения сертификатов из файлов. При ошибке остаются прежние сертификаты
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if r.files.ClientCAFile != "" {
+		clientCAs, err = LoadCertPool(r.files.ClientCAFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	return nil
+}
+
+// Метод получения настроек сервера. Сертификат и удостоверяющие центры клиентов
+// выбираются при каждом рукопожатии, поэтому после Reload действуют новые
+func (r *Reloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+			}
+			if r.clientCAs != nil {
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				config.ClientCAs = r.clientCAs
+			}
+			return config, nil
+		},
+	}
+}
+
+// Метод перечитывания сертификатов при получении сигналов signals, например, syscall.SIGHUP.
+// Работает до отмены ctx
+func (r *Reloader) ReloadOnSignal(ctx context.Context, signals ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-ch:
+			if err := r.Reload(); err != nil {
+				log.Printf("%v: reload certificates: %v", sig, err)
+				continue
+			}
+			log.Printf("%v: certificates reloaded", sig)
+		}
+	}
+}
+
+// Функция чтения сертификатов удостоверяющих центров из файла .pem
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, ErrNoCertificates
+	}
+	return pool, nil
+}
+
+// Функция создания настроек клиента: caFile - удостоверяющие центры сервера, пустой - системные,
+// certFile и keyFile - сертификат клиента для взаимной проверки, пустые - без сертификата
+func ClientConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,155 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Файлы удостоверяющего центра, сертификатов сервера и клиента во временном каталоге
+type testFiles struct {
+	dir string
+	ca  *Pair
+}
+
+func newTestFiles(t *testing.T) *testFiles {
+	ca, err := GenerateCA("Test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &testFiles{dir: t.TempDir(), ca: ca}
+	if err = ca.WriteFiles(f.path("ca.pem"), f.path("ca-key.pem")); err != nil {
+		t.Fatal(err)
+	}
+	f.issue(t, "server", UsageServer)
+	f.issue(t, "client", UsageClient)
+	return f
+}
+
+func (f *testFiles) path(name string) string {
+	return filepath.Join(f.dir, name)
+}
+
+// Метод выпуска сертификата name с записью в name.pem и name-key.pem
+func (f *testFiles) issue(t *testing.T, name string, usage Usage) {
+	pair, err := f.ca.Issue(name, []string{"localhost"}, usage, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = pair.WriteFiles(f.path(name+".pem"), f.path(name+"-key.pem")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Функция рукопожатия по net.Pipe, возвращает имя из сертификата сервера
+func handshake(t *testing.T, server, client *tls.Config) (string, error) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		conn := tls.Server(serverConn, server)
+		err := conn.Handshake()
+		if err == nil {
+			// Ошибку проверки сертификата клиента в TLS 1.3 клиент узнает при чтении
+			_, err = conn.Write([]byte{1})
+		}
+		_ = serverConn.Close()
+		done <- err
+	}()
+
+	client.ServerName = "localhost"
+	conn := tls.Client(clientConn, client)
+	err := conn.Handshake()
+	if err == nil {
+		_, err = conn.Read(make([]byte, 1))
+	}
+	_ = clientConn.Close()
+	<-done
+	if err != nil {
+		return "", err
+	}
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestReloader(t *testing.T) {
+	f := newTestFiles(t)
+	reloader, err := NewReloader(Files{CertFile: f.path("server.pem"), KeyFile: f.path("server-key.pem")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := ClientConfig(f.path("ca.pem"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name, err := handshake(t, reloader.Config(), client); err != nil || name != "server" {
+		t.Fatalf("handshake() got = %q, %v", name, err)
+	}
+
+	// Новый сертификат действует после Reload
+	pair, err := f.ca.Issue("renewed", []string{"localhost"}, UsageServer, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = pair.WriteFiles(f.path("server.pem"), f.path("server-key.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := handshake(t, reloader.Config(), client); name != "server" {
+		t.Errorf("handshake() before Reload got = %q, want server", name)
+	}
+	if err = reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := handshake(t, reloader.Config(), client); err != nil || name != "renewed" {
+		t.Errorf("handshake() after Reload got = %q, %v", name, err)
+	}
+
+	// Испорченный файл не заменяет действующий сертификат
+	if err = ioutil.WriteFile(f.path("server-key.pem"), []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = reloader.Reload(); err == nil {
+		t.Error("Reload() of broken key error = nil")
+	}
+	if name, err := handshake(t, reloader.Config(), client); err != nil || name != "renewed" {
+		t.Errorf("handshake() after failed Reload got = %q, %v", name, err)
+	}
+}
+
+func TestReloader_ClientCA(t *testing.T) {
+	f := newTestFiles(t)
+	reloader, err := NewReloader(Files{
+		CertFile:     f.path("server.pem"),
+		KeyFile:      f.path("server-key.pem"),
+		ClientCAFile: f.path("ca.pem"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantErr  bool
+	}{
+		{name: "With client certificate", certFile: f.path("client.pem"), keyFile: f.path("client-key.pem")},
+		{name: "Without client certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := ClientConfig(f.path("ca.pem"), tt.certFile, tt.keyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = handshake(t, reloader.Config(), client); (err != nil) != tt.wantErr {
+				t.Errorf("handshake() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}